@@ -0,0 +1,13 @@
+package broker
+
+import "context"
+
+// Handler receives every payload published on a topic, including the ones
+// published by the same node.
+type Handler func(payload []byte)
+
+type Broker interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	Subscribe(topic string, handler Handler) error
+	Close() error
+}
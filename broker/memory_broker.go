@@ -0,0 +1,39 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBroker delivers in-process only, it is enough for a single node.
+type MemoryBroker struct {
+	mutex    sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: make(map[string][]Handler)}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	b.mutex.RLock()
+	handlers := b.handlers[topic]
+	b.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(payload)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(topic string, handler Handler) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.handlers[topic] = append(b.handlers[topic], handler)
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
@@ -0,0 +1,205 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+	"real-time-chat-app/config/logger"
+	"real-time-chat-app/entity"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// NOTIFY payloads are capped at 8000 bytes, bigger ones are stored in
+	// t_broker_payload and only their id goes through the channel
+	maxNotifyPayload   = 7900
+	spillPrefix        = "@"
+	topicSeparator     = "|"
+	spillRetention     = 5 * time.Minute
+	maxReconnectDelay  = 30 * time.Second
+	spillCleanupPeriod = time.Minute
+)
+
+// PostgresBroker fans out through LISTEN/NOTIFY on a single channel so every
+// node connected to the same database sees every publish.
+type PostgresBroker struct {
+	db       *gorm.DB
+	log      *logger.AppLogger
+	channel  string
+	mutex    sync.RWMutex
+	handlers map[string][]Handler
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewPostgresBroker(db *gorm.DB, log *logger.AppLogger, channel string) *PostgresBroker {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &PostgresBroker{
+		db:       db,
+		log:      log,
+		channel:  channel,
+		handlers: make(map[string][]Handler),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	go b.listen()
+	go b.cleanupSpilledPayloads()
+
+	log.WS.Info.Info().
+		Str("channel", channel).
+		Msg("Postgres broker initialized")
+
+	return b
+}
+
+func (b *PostgresBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	body := topic + topicSeparator + string(payload)
+
+	if len(body) > maxNotifyPayload {
+		spilled := entity.BrokerPayload{Payload: body}
+		if err := b.db.WithContext(ctx).Create(&spilled).Error; err != nil {
+			return fmt.Errorf("failed to spill broker payload: %w", err)
+		}
+		body = spillPrefix + spilled.ID
+	}
+
+	return b.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", b.channel, body).Error
+}
+
+func (b *PostgresBroker) Subscribe(topic string, handler Handler) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.handlers[topic] = append(b.handlers[topic], handler)
+	return nil
+}
+
+func (b *PostgresBroker) Close() error {
+	b.cancel()
+	return nil
+}
+
+func (b *PostgresBroker) listen() {
+	delay := time.Second
+
+	for {
+		err := b.listenOnce()
+		if b.ctx.Err() != nil {
+			return
+		}
+
+		b.log.WS.Error.Error().
+			Err(err).
+			Str("channel", b.channel).
+			Dur("retryIn", delay).
+			Msg("Postgres broker listener stopped, reconnecting")
+
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// listenOnce holds one pool connection for as long as it stays healthy.
+func (b *PostgresBroker) listenOnce() error {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(b.ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("postgres broker requires the pgx driver")
+		}
+		pgxConn := stdlibConn.Conn()
+
+		if _, err := pgxConn.Exec(b.ctx, "LISTEN "+quoteIdentifier(b.channel)); err != nil {
+			return err
+		}
+
+		b.log.WS.Info.Info().
+			Str("channel", b.channel).
+			Msg("Postgres broker listening")
+
+		for {
+			notification, err := pgxConn.WaitForNotification(b.ctx)
+			if err != nil {
+				return err
+			}
+			b.dispatch(notification.Payload)
+		}
+	})
+}
+
+func (b *PostgresBroker) dispatch(body string) {
+	if strings.HasPrefix(body, spillPrefix) {
+		var spilled entity.BrokerPayload
+		if err := b.db.Where("id = ?", strings.TrimPrefix(body, spillPrefix)).First(&spilled).Error; err != nil {
+			b.log.WS.Error.Error().
+				Err(err).
+				Str("payloadId", strings.TrimPrefix(body, spillPrefix)).
+				Msg("Failed to load spilled broker payload")
+			return
+		}
+		body = spilled.Payload
+	}
+
+	topic, payload, ok := strings.Cut(body, topicSeparator)
+	if !ok {
+		b.log.WS.Warning.Warn().
+			Str("channel", b.channel).
+			Msg("Ignoring malformed broker notification")
+		return
+	}
+
+	b.mutex.RLock()
+	handlers := b.handlers[topic]
+	b.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler([]byte(payload))
+	}
+}
+
+func (b *PostgresBroker) cleanupSpilledPayloads() {
+	ticker := time.NewTicker(spillCleanupPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.db.Unscoped().
+				Where("created_at < ?", time.Now().Add(-spillRetention)).
+				Delete(&entity.BrokerPayload{}).Error; err != nil {
+				b.log.WS.Warning.Warn().
+					Err(err).
+					Msg("Failed to clean up spilled broker payloads")
+			}
+		}
+	}
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
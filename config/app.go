@@ -4,6 +4,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"real-time-chat-app/broker"
 	"real-time-chat-app/config/common"
 	"real-time-chat-app/config/logger"
 	"real-time-chat-app/handler"
@@ -11,16 +12,20 @@ import (
 	"real-time-chat-app/repository"
 	"real-time-chat-app/routes"
 	"real-time-chat-app/security"
+	"real-time-chat-app/storage"
 	"real-time-chat-app/usecase"
 )
 
 type AppConfig struct {
+	*common.Config
 	*fiber.App
 	*validator.Validate
 	*logger.AppLogger
 	*DBConfig
 	*security.JWT
 	*middleware.Middleware
+	Broker    broker.Broker
+	BlobStore storage.BlobStore
 }
 
 func RunServer() {
@@ -29,24 +34,31 @@ func RunServer() {
 	log := logger.NewLogger()
 	newDB := NewDB(newConfig, log)
 	newValidator := NewValidator()
-	newJWT := security.NewJWT(newConfig)
-	newMiddleware := middleware.NewMiddleware(newConfig, log)
+	newJWT := NewJWT(newConfig, log)
+	newMiddleware := middleware.NewMiddleware(newConfig, newJWT, log)
+	newBroker := NewBroker(newConfig, newDB, log)
+	newBlobStore := NewBlobStore(newConfig, log)
 
 	// middleware CORS
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "http://localhost:8080",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
+		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
 		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		// lets browser clients read the backoff of throttled logins
+		ExposeHeaders: "Retry-After",
 		//AllowCredentials: true, // FIX: Enable credentials untuk WebSocket
 	}))
 
 	App(&AppConfig{
+		Config:     newConfig,
 		App:        app,
 		Validate:   newValidator,
 		AppLogger:  log,
 		DBConfig:   newDB,
 		JWT:        newJWT,
 		Middleware: newMiddleware,
+		Broker:     newBroker,
+		BlobStore:  newBlobStore,
 	})
 
 	if err := app.Listen(":7720"); err != nil {
@@ -58,17 +70,37 @@ func App(aC *AppConfig) {
 	newAuthRepository := repository.NewAuthRepository()
 	newUserRepository := repository.NewUserRepository()
 	newChatRepository := repository.NewChatRepository()
+	newChatEventRepository := repository.NewChatEventRepository()
+	newMessageRepository := repository.NewMessageRepository()
+	newReactionRepository := repository.NewReactionRepository()
+	newAttachmentRepository := repository.NewAttachmentRepository()
+	newTokenRepository := repository.NewTokenRepository()
+	newSessionRepository := repository.NewSessionRepository()
+	newLoginAttemptRepository := repository.NewLoginAttemptRepository()
+	newRecoveryCodeRepository := repository.NewRecoveryCodeRepository()
 
-	newAuthUsecase := usecase.NewAuthUsecase(newAuthRepository, aC.Validate, aC.GetDB(), aC.AppLogger, aC.JWT)
 	newAuthCase := usecase.NewUserUsecase(newUserRepository, aC.Validate, aC.GetDB(), aC.AppLogger, aC.JWT)
-	newChatUsecase := usecase.NewChatUsecase(newChatRepository, aC.AppLogger, aC.GetDB(), aC.JWT)
-	newMessageUsecase := usecase.NewMessageUsecase(aC.DB, newChatUsecase, aC.AppLogger)
+	newChatUsecase := usecase.NewChatUsecase(newChatRepository, newChatEventRepository, newReactionRepository, aC.Validate, aC.AppLogger, aC.GetDB(), aC.JWT)
+	newMessageUsecase := usecase.NewMessageUsecase(aC.DB, newChatUsecase, newChatRepository, newChatEventRepository, newMessageRepository, newReactionRepository, newAttachmentRepository, aC.BlobStore, aC.GetMessageConfig(), aC.AppLogger)
 
-	newAuthHandler := handler.NewAuthHandler(newAuthUsecase, aC.AppLogger)
-	newUserHandler := handler.NewUserHandler(newAuthCase, aC.AppLogger)
-	newChatHandler := handler.NewChatHandler(newChatUsecase, newMessageUsecase, aC.AppLogger, aC.JWT)
+	newPresenceTracker := usecase.NewPresenceTracker(aC.GetDB(), newUserRepository, newChatRepository, aC.Broker, aC.GetPresenceConfig(), aC.AppLogger)
+	wsHandler := handler.NewWebSocketHandler(aC.GetDB(), aC.AppLogger, newChatUsecase, newMessageUsecase, aC.JWT, aC.Broker, newPresenceTracker, aC.GetWebSocketConfig())
+	newPresenceTracker.Start(wsHandler)
+
+	// revoking a login session closes its websocket connections
+	newAuthUsecase := usecase.NewAuthUsecase(newAuthRepository, newTokenRepository, newSessionRepository, newLoginAttemptRepository, newRecoveryCodeRepository, wsHandler, aC.Validate, aC.GetDB(), aC.AppLogger, aC.JWT, NewTOTPSecretBox(aC.Config, aC.AppLogger), aC.GetTokenConfig(), aC.GetLoginConfig(), aC.GetTwoFactorConfig())
+	// access tokens are checked against the denylist kept by the auth usecase
+	aC.JWT.UseDenylist(newAuthUsecase)
 
-	wsHandler := handler.NewWebSocketHandler(aC.GetDB(), aC.AppLogger, newChatUsecase, newMessageUsecase)
+	// image previews are announced through the websocket rooms
+	newMediaProcessor := usecase.NewMediaProcessor(aC.GetDB(), newAttachmentRepository, newChatEventRepository, aC.BlobStore, wsHandler, aC.GetMediaConfig(), aC.AppLogger)
+	newMediaProcessor.Start()
+	newAttachmentUsecase := usecase.NewAttachmentUsecase(aC.GetDB(), newChatRepository, newAttachmentRepository, aC.BlobStore, newMediaProcessor, aC.GetAttachmentConfig(), aC.AppLogger)
+
+	newAuthHandler := handler.NewAuthHandler(newAuthUsecase, aC.AppLogger)
+	newUserHandler := handler.NewUserHandler(newAuthCase, newPresenceTracker, aC.AppLogger)
+	newChatHandler := handler.NewChatHandler(newChatUsecase, newMessageUsecase, newAttachmentUsecase, aC.AppLogger, aC.JWT, wsHandler)
+	newKeyHandler := handler.NewKeyHandler(aC.JWT, aC.GetJwtKeyConfig(), aC.AppLogger)
 
 	route := routes.ConfigRoute{
 		App:         aC.App,
@@ -76,7 +108,9 @@ func App(aC *AppConfig) {
 		AuthHandler: newAuthHandler,
 		UserHandler: newUserHandler,
 		ChatHandler: newChatHandler,
+		KeyHandler:  newKeyHandler,
 	}
 	route.GetRoute()
 	route.GetWebSocketRoute(wsHandler)
+	go serveInternal(aC.Config, wsHandler, aC.AppLogger)
 }
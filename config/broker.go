@@ -0,0 +1,19 @@
+package config
+
+import (
+	"real-time-chat-app/broker"
+	"real-time-chat-app/config/common"
+	"real-time-chat-app/config/logger"
+)
+
+func NewBroker(cfg *common.Config, db *DBConfig, log *logger.AppLogger) broker.Broker {
+	driver, channel := cfg.GetBrokerConfig()
+
+	switch driver {
+	case "postgres":
+		return broker.NewPostgresBroker(db.GetDB(), log, channel)
+	default:
+		log.WS.Info.Info().Msg("In-memory broker initialized")
+		return broker.NewMemoryBroker()
+	}
+}
@@ -3,12 +3,80 @@ package common
 import (
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/spf13/viper"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	Viper *viper.Viper
 }
 
+type WebSocketConfig struct {
+	AuthTimeout    time.Duration
+	SendQueueSize  int
+	WriteTimeout   time.Duration
+	OverflowPolicy string
+	PingInterval   time.Duration
+	IdleTimeout    time.Duration
+	ReapInterval   time.Duration
+}
+
+type MessageConfig struct {
+	EditWindow time.Duration
+}
+
+type MediaConfig struct {
+	Workers       int
+	QueueSize     int
+	ThumbnailSize int
+	PreviewSize   int
+	MaxPixels     int
+}
+
+type TokenConfig struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+type JwtKeyConfig struct {
+	Algorithm        string
+	KeyDir           string
+	ActivationDelay  time.Duration
+	RotationInterval time.Duration
+	ReloadInterval   time.Duration
+	Retention        time.Duration
+}
+
+type LoginConfig struct {
+	FreeAttempts     int
+	IPFreeAttempts   int
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+	Window           time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+}
+
+type TwoFactorConfig struct {
+	Issuer            string
+	EncryptionKey     string
+	ChallengeTTL      time.Duration
+	RecoveryCodeCount int
+}
+
+type PresenceConfig struct {
+	Debounce          time.Duration
+	HeartbeatInterval time.Duration
+	TTL               time.Duration
+}
+
+type AttachmentConfig struct {
+	MaxSize       int64
+	AllowedTypes  []string
+	UnsentTTL     time.Duration
+	SweepInterval time.Duration
+}
+
 func NewViper() *Config {
 	config := viper.New()
 	config.SetConfigFile(".env")
@@ -26,6 +94,16 @@ func (c *Config) GetAppConfig() (appName string) {
 	return c.Viper.GetString("APP_NAME")
 }
 
+// GetInternalAddr is where the operator endpoints listen, loopback by default
+// so exposing them to an internal network is an explicit choice.
+func (c *Config) GetInternalAddr() string {
+	addr := c.Viper.GetString("INTERNAL_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:7721"
+	}
+	return addr
+}
+
 func (c *Config) GetDatabaseConfig() (dbHost, dbUser, dbPassword, dbName, dbPort string) {
 	dbHost = c.Viper.GetString("DB_HOSTNAME")
 	dbUser = c.Viper.GetString("DB_USER")
@@ -40,3 +118,338 @@ func (c *Config) GetJwtConfig() []byte {
 	jwtSecret := c.Viper.GetString("JWT_SECRET")
 	return []byte(jwtSecret)
 }
+
+func (c *Config) GetJwtKeyConfig() JwtKeyConfig {
+	// HS512 keeps signing with JWT_SECRET, RS256 and EdDSA sign with the PEM keys of KeyDir
+	var algorithm string
+	switch strings.ToUpper(c.Viper.GetString("JWT_SIGNING_ALG")) {
+	case "RS256":
+		algorithm = "RS256"
+	case "EDDSA", "ED25519":
+		algorithm = "EdDSA"
+	default:
+		algorithm = "HS512"
+	}
+
+	// shared by every node, a key only one node knows breaks verification on the others
+	keyDir := c.Viper.GetString("JWT_KEY_DIR")
+	if keyDir == "" {
+		keyDir = "./keys"
+	}
+
+	// a new key is published in the JWKS this long before it signs anything,
+	// so verifiers caching the key set pick it up first
+	activationDelay := c.Viper.GetDuration("JWT_KEY_ACTIVATION_DELAY")
+	if activationDelay < 0 {
+		activationDelay = 0
+	} else if !c.Viper.IsSet("JWT_KEY_ACTIVATION_DELAY") {
+		activationDelay = 10 * time.Minute
+	}
+
+	// zero leaves rotation to whoever drops new key files into KeyDir
+	rotationInterval := c.Viper.GetDuration("JWT_KEY_ROTATION_INTERVAL")
+	if rotationInterval < 0 {
+		rotationInterval = 0
+	}
+
+	reloadInterval := c.Viper.GetDuration("JWT_KEY_RELOAD_INTERVAL")
+	if reloadInterval <= 0 {
+		reloadInterval = time.Minute
+	}
+
+	return JwtKeyConfig{
+		Algorithm:        algorithm,
+		KeyDir:           keyDir,
+		ActivationDelay:  activationDelay,
+		RotationInterval: rotationInterval,
+		ReloadInterval:   reloadInterval,
+		// a replaced key must outlive every access token it signed
+		Retention: c.GetTokenConfig().AccessTTL,
+	}
+}
+
+func (c *Config) GetTokenConfig() TokenConfig {
+	accessTTL := c.Viper.GetDuration("JWT_ACCESS_TTL")
+	if accessTTL <= 0 {
+		accessTTL = time.Hour
+	}
+
+	// a refresh token that is not used within this window forces a new login
+	refreshTTL := c.Viper.GetDuration("JWT_REFRESH_TTL")
+	if refreshTTL <= accessTTL {
+		refreshTTL = 30 * 24 * time.Hour
+	}
+
+	return TokenConfig{
+		AccessTTL:  accessTTL,
+		RefreshTTL: refreshTTL,
+	}
+}
+
+func (c *Config) GetLoginConfig() LoginConfig {
+	// failures allowed before every further one doubles the wait, an IP gets
+	// more since many users can share one
+	freeAttempts := c.Viper.GetInt("LOGIN_FREE_ATTEMPTS")
+	if freeAttempts <= 0 {
+		freeAttempts = 3
+	}
+
+	ipFreeAttempts := c.Viper.GetInt("LOGIN_IP_FREE_ATTEMPTS")
+	if ipFreeAttempts <= 0 {
+		ipFreeAttempts = 20
+	}
+
+	backoffBase := c.Viper.GetDuration("LOGIN_BACKOFF_BASE")
+	if backoffBase <= 0 {
+		backoffBase = time.Second
+	}
+
+	backoffMax := c.Viper.GetDuration("LOGIN_BACKOFF_MAX")
+	if backoffMax < backoffBase {
+		backoffMax = 15 * time.Minute
+	}
+
+	// failures older than this are forgotten
+	window := c.Viper.GetDuration("LOGIN_ATTEMPT_WINDOW")
+	if window <= 0 {
+		window = 15 * time.Minute
+	}
+
+	// failures of one username that lock the account
+	lockoutThreshold := c.Viper.GetInt("LOGIN_LOCKOUT_THRESHOLD")
+	if lockoutThreshold <= 0 {
+		lockoutThreshold = 10
+	}
+
+	lockoutDuration := c.Viper.GetDuration("LOGIN_LOCKOUT_DURATION")
+	if lockoutDuration <= 0 {
+		lockoutDuration = 15 * time.Minute
+	}
+
+	return LoginConfig{
+		FreeAttempts:     freeAttempts,
+		IPFreeAttempts:   ipFreeAttempts,
+		BackoffBase:      backoffBase,
+		BackoffMax:       backoffMax,
+		Window:           window,
+		LockoutThreshold: lockoutThreshold,
+		LockoutDuration:  lockoutDuration,
+	}
+}
+
+func (c *Config) GetTwoFactorConfig() TwoFactorConfig {
+	// the name authenticator apps list the account under
+	issuer := c.Viper.GetString("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = c.GetAppConfig()
+	}
+	if issuer == "" {
+		issuer = "real-time-chat-app"
+	}
+
+	// TOTP secrets are stored encrypted with it, enrollment is refused without one
+	encryptionKey := c.Viper.GetString("TOTP_ENCRYPTION_KEY")
+
+	// time between the password step and the code step of a login
+	challengeTTL := c.Viper.GetDuration("TOTP_CHALLENGE_TTL")
+	if challengeTTL <= 0 {
+		challengeTTL = 5 * time.Minute
+	}
+
+	recoveryCodeCount := c.Viper.GetInt("TOTP_RECOVERY_CODES")
+	if recoveryCodeCount <= 0 {
+		recoveryCodeCount = 10
+	}
+
+	return TwoFactorConfig{
+		Issuer:            issuer,
+		EncryptionKey:     encryptionKey,
+		ChallengeTTL:      challengeTTL,
+		RecoveryCodeCount: recoveryCodeCount,
+	}
+}
+
+func (c *Config) GetBrokerConfig() (driver, channel string) {
+	driver = c.Viper.GetString("BROKER_DRIVER")
+	if driver == "" {
+		driver = "memory"
+	}
+
+	channel = c.Viper.GetString("BROKER_CHANNEL")
+	if channel == "" {
+		channel = "realtime_events"
+	}
+
+	return driver, channel
+}
+
+func (c *Config) GetStorageConfig() (driver, root string) {
+	driver = c.Viper.GetString("STORAGE_DRIVER")
+	if driver == "" {
+		driver = "local"
+	}
+
+	root = c.Viper.GetString("STORAGE_LOCAL_ROOT")
+	if root == "" {
+		root = "./uploads"
+	}
+
+	return driver, root
+}
+
+func (c *Config) GetAttachmentConfig() AttachmentConfig {
+	maxSize := c.Viper.GetInt64("ATTACHMENT_MAX_SIZE")
+	if maxSize <= 0 {
+		maxSize = 10 << 20
+	}
+
+	// sniffed MIME types, "image/*" allows a whole family
+	allowedTypes := c.Viper.GetStringSlice("ATTACHMENT_ALLOWED_TYPES")
+	if len(allowedTypes) == 1 && strings.Contains(allowedTypes[0], ",") {
+		allowedTypes = strings.Split(allowedTypes[0], ",")
+	}
+	if len(allowedTypes) == 0 {
+		allowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf", "text/plain", "video/mp4", "audio/mpeg"}
+	}
+
+	// uploads no message claimed within this long are deleted with their blobs
+	unsentTTL := c.Viper.GetDuration("ATTACHMENT_UNSENT_TTL")
+	if unsentTTL <= 0 {
+		unsentTTL = 24 * time.Hour
+	}
+
+	sweepInterval := c.Viper.GetDuration("ATTACHMENT_SWEEP_INTERVAL")
+	if sweepInterval <= 0 {
+		sweepInterval = time.Hour
+	}
+
+	return AttachmentConfig{
+		MaxSize:       maxSize,
+		AllowedTypes:  allowedTypes,
+		UnsentTTL:     unsentTTL,
+		SweepInterval: sweepInterval,
+	}
+}
+
+func (c *Config) GetMediaConfig() MediaConfig {
+	workers := c.Viper.GetInt("MEDIA_WORKERS")
+	if workers <= 0 {
+		workers = 2
+	}
+
+	queueSize := c.Viper.GetInt("MEDIA_QUEUE_SIZE")
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+
+	// longest side in pixels of each generated variant
+	thumbnailSize := c.Viper.GetInt("MEDIA_THUMBNAIL_SIZE")
+	if thumbnailSize <= 0 {
+		thumbnailSize = 320
+	}
+
+	previewSize := c.Viper.GetInt("MEDIA_PREVIEW_SIZE")
+	if previewSize <= 0 {
+		previewSize = 1280
+	}
+
+	// larger images are not decoded at all
+	maxPixels := c.Viper.GetInt("MEDIA_MAX_PIXELS")
+	if maxPixels <= 0 {
+		maxPixels = 40_000_000
+	}
+
+	return MediaConfig{
+		Workers:       workers,
+		QueueSize:     queueSize,
+		ThumbnailSize: thumbnailSize,
+		PreviewSize:   previewSize,
+		MaxPixels:     maxPixels,
+	}
+}
+
+func (c *Config) GetMessageConfig() MessageConfig {
+	// how long after sending a message its sender may still edit it
+	editWindow := c.Viper.GetDuration("MESSAGE_EDIT_WINDOW")
+	if editWindow <= 0 {
+		editWindow = 15 * time.Minute
+	}
+
+	return MessageConfig{
+		EditWindow: editWindow,
+	}
+}
+
+func (c *Config) GetPresenceConfig() PresenceConfig {
+	// going away or offline is only announced once it lasted this long
+	debounce := c.Viper.GetDuration("PRESENCE_DEBOUNCE")
+	if debounce <= 0 {
+		debounce = 5 * time.Second
+	}
+
+	heartbeatInterval := c.Viper.GetDuration("PRESENCE_HEARTBEAT_INTERVAL")
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = 15 * time.Second
+	}
+
+	// sessions of a node that stopped sending heartbeats are dropped after this
+	ttl := c.Viper.GetDuration("PRESENCE_TTL")
+	if ttl <= heartbeatInterval {
+		ttl = 3 * heartbeatInterval
+	}
+
+	return PresenceConfig{
+		Debounce:          debounce,
+		HeartbeatInterval: heartbeatInterval,
+		TTL:               ttl,
+	}
+}
+
+func (c *Config) GetWebSocketConfig() WebSocketConfig {
+	authTimeout := c.Viper.GetDuration("WS_AUTH_TIMEOUT")
+	if authTimeout <= 0 {
+		authTimeout = 10 * time.Second
+	}
+
+	sendQueueSize := c.Viper.GetInt("WS_SEND_QUEUE_SIZE")
+	if sendQueueSize <= 0 {
+		sendQueueSize = 256
+	}
+
+	writeTimeout := c.Viper.GetDuration("WS_WRITE_TIMEOUT")
+	if writeTimeout <= 0 {
+		writeTimeout = 10 * time.Second
+	}
+
+	// "disconnect" closes slow consumers, "drop" discards messages that do not fit
+	overflowPolicy := c.Viper.GetString("WS_OVERFLOW_POLICY")
+	if overflowPolicy != "drop" {
+		overflowPolicy = "disconnect"
+	}
+
+	pingInterval := c.Viper.GetDuration("WS_PING_INTERVAL")
+	if pingInterval <= 0 {
+		pingInterval = 30 * time.Second
+	}
+
+	// a connection has to answer at least one ping within the idle timeout
+	idleTimeout := c.Viper.GetDuration("WS_IDLE_TIMEOUT")
+	if idleTimeout <= pingInterval {
+		idleTimeout = 2 * pingInterval
+	}
+
+	reapInterval := c.Viper.GetDuration("WS_REAP_INTERVAL")
+	if reapInterval <= 0 {
+		reapInterval = pingInterval
+	}
+
+	return WebSocketConfig{
+		AuthTimeout:    authTimeout,
+		SendQueueSize:  sendQueueSize,
+		WriteTimeout:   writeTimeout,
+		OverflowPolicy: overflowPolicy,
+		PingInterval:   pingInterval,
+		IdleTimeout:    idleTimeout,
+		ReapInterval:   reapInterval,
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"real-time-chat-app/config/common"
 	"real-time-chat-app/config/logger"
 	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
 	"time"
 )
 
@@ -53,12 +54,138 @@ func initDatabase(cfg *common.Config, log *logger.AppLogger) *gorm.DB {
 	var chatParticipant entity.ChatParticipant
 	var messages entity.Messages
 	var messageStatus entity.MessageStatus
-	if err := db.AutoMigrate(&auth, &user, &chat, &chatParticipant, &messages, &messageStatus); err != nil {
+	var brokerPayload entity.BrokerPayload
+	var chatEvent entity.ChatEvent
+	var messageEdit entity.MessageEdit
+	var hiddenMessage entity.HiddenMessage
+	var messageReaction entity.MessageReaction
+	var attachment entity.Attachment
+	var refreshToken entity.RefreshToken
+	var revokedToken entity.RevokedToken
+	var authSession entity.AuthSession
+	var loginAttempt entity.LoginAttempt
+	var failedLogin entity.FailedLogin
+	var recoveryCode entity.RecoveryCode
+	if err := db.AutoMigrate(&auth, &user, &chat, &chatParticipant, &messages, &messageStatus, &brokerPayload, &chatEvent, &messageEdit, &hiddenMessage, &messageReaction, &attachment, &refreshToken, &revokedToken, &authSession, &loginAttempt, &failedLogin, &recoveryCode); err != nil {
 		panic("failed run migration")
 	}
 
+	// keyset pagination walks (created_at, id) within a chat in both directions
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_messages_chat_created_id ON t_messages (chat_id, created_at DESC, id DESC)").Error; err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to create message pagination index")
+	}
+
+	// the writing transaction orders events for sync, only postgres can fill it
+	if err := db.Exec("ALTER TABLE t_chat_event ADD COLUMN IF NOT EXISTS tx_id bigint NOT NULL DEFAULT (pg_current_xact_id()::text::bigint)").Error; err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to add chat event transaction column")
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_chat_event_tx ON t_chat_event (tx_id, id)").Error; err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to create chat event transaction index")
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_chat_event_removed_users ON t_chat_event USING GIN ((payload->'userIds')) WHERE type = 'member_removed'").Error; err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to create chat event removal index")
+	}
+
+	// the search vector is generated by postgres, so it is kept out of the entity
+	if err := db.Exec("ALTER TABLE t_messages ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', coalesce(content, ''))) STORED").Error; err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to add message search column")
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON t_messages USING GIN (search_vector)").Error; err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to create message search index")
+	}
+
+	if err := backfillSequences(db); err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to backfill message sequences")
+	}
+
+	if err := backfillParticipants(db); err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to backfill participant roles")
+	}
+
+	if err := backfillReceipts(db); err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to backfill message receipts")
+	}
+
+	if err := backfillReadPositions(db); err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to backfill read positions")
+	}
+
 	conn.SetMaxIdleConns(10)
 	conn.SetMaxOpenConns(100)
 	conn.SetConnMaxLifetime(time.Second * time.Duration(300))
 	return db
 }
+
+// backfillReceipts carries read flags written before receipts had a status
+// over to it and to the aggregate status of their messages.
+func backfillReceipts(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			UPDATE t_message_status SET status = ?, delivered_at = COALESCE(delivered_at, read_at)
+			WHERE is_read = true AND status <> ?`, enum.MessageStatusRead, enum.MessageStatusRead).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			UPDATE t_messages m SET status = ?
+			WHERE m.status = ? AND EXISTS (SELECT 1 FROM t_message_status s WHERE s.message_id = m.id)
+				AND NOT EXISTS (SELECT 1 FROM t_message_status s WHERE s.message_id = m.id AND s.status <> ?)`,
+			enum.MessageStatusRead, enum.MessageStatusSent, enum.MessageStatusRead).Error
+	})
+}
+
+// backfillReadPositions moves participants' read watermark up to the newest
+// message they had flagged as read before watermarks existed.
+func backfillReadPositions(db *gorm.DB) error {
+	return db.Exec(`
+		UPDATE t_chat_participant p SET last_read_seq = r.seq, last_read_message_id = r.message_id, last_read_at = r.read_at
+		FROM (
+			SELECT DISTINCT ON (m.chat_id, s.user_id) m.chat_id, s.user_id, m.seq, m.id AS message_id, s.read_at
+			FROM t_message_status s
+			JOIN t_messages m ON m.id = s.message_id
+			WHERE s.is_read = true
+			ORDER BY m.chat_id, s.user_id, m.seq DESC
+		) r
+		WHERE p.chat_id = r.chat_id AND p.user_id = r.user_id AND p.last_read_seq < r.seq`).Error
+}
+
+// backfillParticipants fills join times and gives every ownerless group an owner.
+func backfillParticipants(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			UPDATE t_chat_participant p SET joined_at = c.created_at
+			FROM t_chat c
+			WHERE c.id = p.chat_id AND p.joined_at IS NULL`).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			UPDATE t_chat_participant SET role = 'owner'
+			WHERE id IN (
+				SELECT DISTINCT ON (p.chat_id) p.id
+				FROM t_chat_participant p
+				JOIN t_chat c ON c.id = p.chat_id
+				WHERE c.chat_type = ? AND NOT EXISTS (
+					SELECT 1 FROM t_chat_participant o WHERE o.chat_id = p.chat_id AND o.role = 'owner'
+				)
+				ORDER BY p.chat_id, p.joined_at, p.id
+			)`, enum.GROUP).Error
+	})
+}
+
+// backfillSequences numbers messages created before per-chat sequences existed.
+func backfillSequences(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			UPDATE t_messages m SET seq = s.rn
+			FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY chat_id ORDER BY created_at, id) AS rn FROM t_messages) s
+			WHERE m.id = s.id AND m.seq = 0`).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			UPDATE t_chat c SET last_seq = s.max_seq
+			FROM (SELECT chat_id, MAX(seq) AS max_seq FROM t_messages GROUP BY chat_id) s
+			WHERE c.id = s.chat_id AND c.last_seq < s.max_seq`).Error
+	})
+}
@@ -7,10 +7,13 @@ import (
 
 func NewFiber(cfg *common.Config) *fiber.App {
 	appName := cfg.GetAppConfig()
+	attachmentConfig := cfg.GetAttachmentConfig()
 	return fiber.New(fiber.Config{
 		Prefork:       false,
 		CaseSensitive: true,
 		StrictRouting: true,
 		AppName:       appName,
+		// leave room for the multipart envelope around the largest attachment
+		BodyLimit: int(attachmentConfig.MaxSize) + 1<<20,
 	})
 }
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"real-time-chat-app/config/common"
+	"real-time-chat-app/config/logger"
+	"real-time-chat-app/handler"
+	"real-time-chat-app/routes"
+)
+
+// serveInternal runs the operator endpoints, such as the websocket metrics, on
+// their own listener instead of the public one.
+func serveInternal(cfg *common.Config, wsHandler *handler.WebSocketHandler, log *logger.AppLogger) {
+	app := fiber.New(fiber.Config{
+		AppName:               cfg.GetAppConfig(),
+		DisableStartupMessage: true,
+	})
+	routes.GetInternalRoute(app, wsHandler)
+
+	addr := cfg.GetInternalAddr()
+	log.Http.Info.Info().Str("addr", addr).Msg("Internal server listening")
+	if err := app.Listen(addr); err != nil {
+		log.Http.Error.Error().Err(err).Str("addr", addr).Msg("Failed to start internal server")
+	}
+}
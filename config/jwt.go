@@ -0,0 +1,54 @@
+package config
+
+import (
+	"real-time-chat-app/config/common"
+	"real-time-chat-app/config/logger"
+	"real-time-chat-app/security"
+	"time"
+)
+
+func NewJWT(cfg *common.Config, log *logger.AppLogger) *security.JWT {
+	keyConfig := cfg.GetJwtKeyConfig()
+	if keyConfig.Algorithm != security.AlgorithmRS256 && keyConfig.Algorithm != security.AlgorithmEdDSA {
+		log.Http.Info.Info().Str("algorithm", keyConfig.Algorithm).Msg("JWT signing with shared secret")
+		return security.NewJWT(cfg, nil)
+	}
+
+	keys, err := security.NewKeySet(keyConfig)
+	if err != nil {
+		log.Http.Error.Error().Err(err).Str("keyDir", keyConfig.KeyDir).Msg("failed to load JWT signing keys")
+		panic("failed to load JWT signing keys")
+	}
+	log.Http.Info.Info().
+		Str("algorithm", keyConfig.Algorithm).
+		Str("kid", keys.Signing().KID).
+		Int("keyCount", len(keys.Keys())).
+		Msg("JWT key set initialized")
+
+	go rotateJwtKeys(keys, keyConfig.ReloadInterval, log)
+
+	return security.NewJWT(cfg, keys)
+}
+
+// rotateJwtKeys reloads the key directory so keys added, generated or removed
+// on any node are picked up without a restart.
+func rotateJwtKeys(keys *security.KeySet, interval time.Duration, log *logger.AppLogger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		previous := keys.Signing().KID
+		if err := keys.Rotate(now); err != nil {
+			log.Http.Error.Error().Err(err).Msg("Failed to rotate JWT signing keys")
+			continue
+		}
+
+		if current := keys.Signing().KID; current != previous {
+			log.Http.Info.Info().
+				Str("previousKid", previous).
+				Str("kid", current).
+				Int("keyCount", len(keys.Keys())).
+				Msg("JWT signing key rotated")
+		}
+	}
+}
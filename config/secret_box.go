@@ -0,0 +1,25 @@
+package config
+
+import (
+	"real-time-chat-app/config/common"
+	"real-time-chat-app/config/logger"
+	"real-time-chat-app/security"
+)
+
+// NewTOTPSecretBox returns nil without TOTP_ENCRYPTION_KEY, two-factor
+// enrollment is refused until one is configured.
+func NewTOTPSecretBox(cfg *common.Config, log *logger.AppLogger) *security.SecretBox {
+	key := cfg.GetTwoFactorConfig().EncryptionKey
+	if key == "" {
+		log.Http.Warning.Warn().Msg("TOTP_ENCRYPTION_KEY not set, two-factor authentication disabled")
+		return nil
+	}
+
+	box, err := security.NewSecretBox(key)
+	if err != nil {
+		log.Http.Error.Error().Err(err).Msg("failed to prepare TOTP secret box")
+		panic("failed to prepare TOTP secret box")
+	}
+	log.Http.Info.Info().Msg("TOTP secret box initialized")
+	return box
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"real-time-chat-app/config/common"
+	"real-time-chat-app/config/logger"
+	"real-time-chat-app/storage"
+)
+
+func NewBlobStore(cfg *common.Config, log *logger.AppLogger) storage.BlobStore {
+	driver, root := cfg.GetStorageConfig()
+
+	switch driver {
+	case "local":
+		store, err := storage.NewLocalStore(root)
+		if err != nil {
+			log.Http.Error.Error().Err(err).Str("root", root).Msg("failed to prepare local blob store")
+			panic("failed to prepare blob store")
+		}
+		log.Http.Info.Info().Str("root", root).Msg("Local blob store initialized")
+		return store
+	default:
+		panic("unknown storage driver: " + driver)
+	}
+}
@@ -0,0 +1,19 @@
+package dto
+
+// Attachment describes a file carried by a message.
+type Attachment struct {
+	AttachmentID string `json:"attachmentId"`
+	FileName     string `json:"fileName"`
+	MimeType     string `json:"mimeType"`
+	Size         int64  `json:"size"`
+	Checksum     string `json:"checksum"`
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+	URL          string `json:"url"`
+
+	// image previews, the URLs appear once MediaStatus is "ready"
+	MediaStatus  string `json:"mediaStatus,omitempty"`
+	BlurHash     string `json:"blurHash,omitempty"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"`
+	PreviewURL   string `json:"previewUrl,omitempty"`
+}
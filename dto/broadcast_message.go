@@ -9,4 +9,9 @@ type BroadcastMessage struct {
 	Content      string `json:"content"`
 	CreatedAt    string `json:"createdAt"`
 	Status       string `json:"status"`
+	Type         string `json:"type"`
+	Seq          int64  `json:"seq"`
+
+	ReplyTo     *QuotedMessage `json:"replyTo,omitempty"`
+	Attachments []Attachment   `json:"attachments,omitempty"`
 }
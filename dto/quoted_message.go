@@ -0,0 +1,10 @@
+package dto
+
+// QuotedMessage is the snippet of a message shown above a reply.
+type QuotedMessage struct {
+	MessageID  string `json:"messageId"`
+	SenderID   string `json:"senderId"`
+	SenderName string `json:"senderName"`
+	Content    string `json:"content"`
+	IsDeleted  bool   `json:"isDeleted"`
+}
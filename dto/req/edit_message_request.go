@@ -0,0 +1,5 @@
+package req
+
+type EditMessageRequest struct {
+	Content string `json:"content"`
+}
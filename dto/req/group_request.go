@@ -0,0 +1,22 @@
+package req
+
+type CreateGroupRequest struct {
+	Name      string   `json:"name" validate:"required,max=50"`
+	MemberIDs []string `json:"memberIds" validate:"required,min=1,dive,required"`
+}
+
+type UpdateGroupRequest struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin member"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID string `json:"userId" validate:"required"`
+}
+
+type ParticipantsRequest struct {
+	UserIDs []string `json:"userIds" validate:"required,min=1,dive,required"`
+}
@@ -1,6 +1,11 @@
 package req
 
 type LoginRequest struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required"`
+	Username   string `json:"username" validate:"required,max=50"`
+	Password   string `json:"password" validate:"required"`
+	DeviceName string `json:"deviceName" validate:"max=100"`
+
+	// filled from the request by the handler
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
 }
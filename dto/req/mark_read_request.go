@@ -0,0 +1,5 @@
+package req
+
+type MarkReadRequest struct {
+	MessageID string `json:"messageId"` // optional, defaults to the latest message
+}
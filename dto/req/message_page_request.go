@@ -0,0 +1,8 @@
+package req
+
+type MessagePageRequest struct {
+	Before    string `query:"before"`
+	After     string `query:"after"`
+	Limit     int    `query:"limit"`
+	Direction string `query:"direction"` // "backward" (older, default) | "forward" (newer)
+}
@@ -1,9 +1,11 @@
 package req
 
 type MessageRequest struct {
-	ChatID     string `json:"chatId"`
-	SenderID   string `json:"senderId"`
-	ReceiverID string `json:"receiverId,omitempty"`
-	Content    string `json:"content"`
-	ChatType   string `json:"type,omitempty"` // optional, "personal" | "group"
+	ChatID        string   `json:"chatId"`
+	SenderID      string   `json:"senderId"`
+	ReceiverID    string   `json:"receiverId,omitempty"`
+	Content       string   `json:"content"`
+	ReplyToID     string   `json:"replyToId,omitempty"`
+	AttachmentIDs []string `json:"attachmentIds,omitempty"`
+	ChatType      string   `json:"type,omitempty"` // optional, "personal" | "group"
 }
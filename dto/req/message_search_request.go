@@ -0,0 +1,11 @@
+package req
+
+type MessageSearchRequest struct {
+	Query    string `query:"q"`
+	ChatID   string `query:"chatId"`
+	SenderID string `query:"senderId"`
+	From     string `query:"from"` // "2006-01-02" or RFC 3339
+	To       string `query:"to"`   // inclusive when given as a date
+	Cursor   string `query:"cursor"`
+	Limit    int    `query:"limit"`
+}
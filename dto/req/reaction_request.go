@@ -0,0 +1,5 @@
+package req
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
@@ -0,0 +1,15 @@
+package req
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+
+	// filled from the request by the handler
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
+
+// LogoutRequest may name the refresh token to revoke, otherwise the family of
+// the access token used for the call is revoked.
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
@@ -0,0 +1,7 @@
+package req
+
+type SyncRequest struct {
+	Cursors   map[string]int64 `json:"cursors,omitempty"`   // chatId -> last seen seq
+	GlobalSeq int64            `json:"globalSeq,omitempty"` // globalSeq of the last response, covers chats missing from Cursors
+	Limit     int              `json:"limit,omitempty"`
+}
@@ -0,0 +1,27 @@
+package req
+
+// TwoFactorLoginRequest completes a login that answered with a challenge, Code
+// is either a TOTP code or an unused recovery code.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required,max=32"`
+	DeviceName     string `json:"deviceName" validate:"max=100"`
+
+	// filled from the request by the handler
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
+
+type TwoFactorConfirmRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// PasswordConfirmRequest re-confirms the password before 2FA is turned off or
+// its recovery codes are replaced.
+type PasswordConfirmRequest struct {
+	Password string `json:"password" validate:"required"`
+
+	// filled from the request by the handler
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
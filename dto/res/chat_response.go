@@ -6,4 +6,5 @@ type ChatResponse struct {
 	LastMessage     string `json:"lastMessage"`
 	UnreadCount     uint   `json:"unreadCount"`
 	LastMessageTime string `json:"lastMessageTime"`
+	LastSeq         int64  `json:"lastSeq"`
 }
@@ -0,0 +1,17 @@
+package res
+
+type GroupChatResponse struct {
+	ChatID       string                `json:"chatId"`
+	GroupName    string                `json:"groupName"`
+	ChatType     string                `json:"chatType"`
+	Participants []ParticipantResponse `json:"participants"`
+	CreatedAt    string                `json:"createdAt"`
+}
+
+type ParticipantResponse struct {
+	UserID   string `json:"userId"`
+	Name     string `json:"name"`
+	Avatar   string `json:"avatar,omitempty"`
+	Role     string `json:"role"`
+	JoinedAt string `json:"joinedAt"`
+}
@@ -1,5 +1,15 @@
 package res
 
+// LoginResponse carries the tokens of a session. With 2FA enabled the password
+// step only returns a challenge, the tokens come from the code step.
 type LoginResponse struct {
-	Token string `json:"token"`
+	SessionID        string `json:"sessionId,omitempty"`
+	Token            string `json:"token,omitempty"`
+	ExpiresAt        string `json:"expiresAt,omitempty"`
+	RefreshToken     string `json:"refreshToken,omitempty"`
+	RefreshExpiresAt string `json:"refreshExpiresAt,omitempty"`
+
+	TwoFactorRequired  bool   `json:"twoFactorRequired,omitempty"`
+	ChallengeToken     string `json:"challengeToken,omitempty"`
+	ChallengeExpiresAt string `json:"challengeExpiresAt,omitempty"`
 }
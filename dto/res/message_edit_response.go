@@ -0,0 +1,7 @@
+package res
+
+type MessageEditResponse struct {
+	PreviousContent string `json:"previousContent"`
+	EditedBy        string `json:"editedBy"`
+	EditedAt        string `json:"editedAt"`
+}
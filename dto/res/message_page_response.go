@@ -0,0 +1,16 @@
+package res
+
+type MessagePageResponse struct {
+	ChatID   string            `json:"chatId"`
+	Messages []MessageResponse `json:"messages"`
+	Next     string            `json:"next,omitempty"` // pass as "after" to load newer messages
+	Prev     string            `json:"prev,omitempty"` // pass as "before" to load older messages
+}
+
+type ThreadResponse struct {
+	ChatID  string            `json:"chatId"`
+	Parent  MessageResponse   `json:"parent"`
+	Replies []MessageResponse `json:"replies"`
+	Next    string            `json:"next,omitempty"`
+	Prev    string            `json:"prev,omitempty"`
+}
@@ -0,0 +1,17 @@
+package res
+
+type MessageReceiptsResponse struct {
+	MessageID string                   `json:"messageId"`
+	Status    string                   `json:"status"`
+	Delivered int64                    `json:"deliveredCount"`
+	Read      int64                    `json:"readCount"`
+	Total     int64                    `json:"recipientCount"`
+	Receipts  []MessageReceiptResponse `json:"receipts"`
+}
+
+type MessageReceiptResponse struct {
+	UserID      string `json:"userId"`
+	Status      string `json:"status"`
+	DeliveredAt string `json:"deliveredAt,omitempty"`
+	ReadAt      string `json:"readAt,omitempty"`
+}
@@ -1,5 +1,7 @@
 package res
 
+import "real-time-chat-app/dto"
+
 type MessageResponse struct {
 	MessageId  string `json:"messageId"`
 	Content    string `json:"content"`
@@ -7,5 +9,16 @@ type MessageResponse struct {
 	SenderName string `json:"senderName"`
 	CreatedAt  string `json:"createdAt"`
 	Status     string `json:"status"`
+	Type       string `json:"type"`
 	IsRead     bool   `json:"isRead"`
+	Seq        int64  `json:"seq"`
+	IsEdited   bool   `json:"isEdited"`
+	EditedAt   string `json:"editedAt,omitempty"`
+	IsDeleted  bool   `json:"isDeleted"`
+
+	ReplyTo    *dto.QuotedMessage `json:"replyTo,omitempty"`
+	ReplyCount int64              `json:"replyCount"`
+
+	Reactions   []ReactionResponse `json:"reactions"`
+	Attachments []dto.Attachment   `json:"attachments"`
 }
@@ -0,0 +1,18 @@
+package res
+
+type MessageSearchResponse struct {
+	Results []MessageSearchResult `json:"results"`
+	Next    string                `json:"next,omitempty"`
+}
+
+type MessageSearchResult struct {
+	MessageId  string `json:"messageId"`
+	ChatId     string `json:"chatId"`
+	ChatType   string `json:"chatType"`
+	GroupName  string `json:"groupName,omitempty"`
+	SenderId   string `json:"senderId"`
+	SenderName string `json:"senderName"`
+	Highlight  string `json:"highlight"` // HTML escaped message text, matches wrapped in <mark>
+	Seq        int64  `json:"seq"`
+	CreatedAt  string `json:"createdAt"`
+}
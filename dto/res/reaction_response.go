@@ -0,0 +1,7 @@
+package res
+
+type ReactionResponse struct {
+	Emoji       string `json:"emoji"`
+	Count       int64  `json:"count"`
+	ReactedByMe bool   `json:"reactedByMe"`
+}
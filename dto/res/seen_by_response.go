@@ -0,0 +1,14 @@
+package res
+
+type SeenByResponse struct {
+	MessageID string        `json:"messageId"`
+	SeenBy    []SeenByEntry `json:"seenBy"`
+	Pending   []string      `json:"pending"` // recipients who have not read it yet
+}
+
+type SeenByEntry struct {
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar,omitempty"`
+	ReadAt string `json:"readAt"` // when their read position last moved, at or after reading this message
+}
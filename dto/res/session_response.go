@@ -0,0 +1,11 @@
+package res
+
+type SessionResponse struct {
+	ID         string `json:"id"`
+	DeviceName string `json:"deviceName"`
+	UserAgent  string `json:"userAgent"`
+	IP         string `json:"ip"`
+	CreatedAt  string `json:"createdAt"`
+	LastUsedAt string `json:"lastUsedAt"`
+	Current    bool   `json:"current"` // the session of the token used for the call
+}
@@ -0,0 +1,18 @@
+package res
+
+import "encoding/json"
+
+type SyncResponse struct {
+	Events    []SyncEvent      `json:"events"`
+	Cursors   map[string]int64 `json:"cursors"`
+	GlobalSeq int64            `json:"globalSeq"` // transaction watermark to resume uncovered chats from
+	HasMore   bool             `json:"hasMore"`
+}
+
+type SyncEvent struct {
+	ChatID    string          `json:"chatId"`
+	Seq       int64           `json:"seq"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt string          `json:"createdAt"`
+}
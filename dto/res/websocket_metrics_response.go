@@ -0,0 +1,22 @@
+package res
+
+type WebSocketMetricsResponse struct {
+	ActiveSessions          int                       `json:"activeSessions"`
+	QueueCapacity           int                       `json:"queueCapacity"`
+	OverflowPolicy          string                    `json:"overflowPolicy"`
+	TotalQueued             int                       `json:"totalQueued"`
+	MaxQueueDepth           int                       `json:"maxQueueDepth"`
+	Enqueued                uint64                    `json:"enqueued"`
+	Dropped                 uint64                    `json:"dropped"`
+	DroppedTyping           uint64                    `json:"droppedTyping"`
+	SlowConsumerDisconnects uint64                    `json:"slowConsumerDisconnects"`
+	BusiestSessions         []WebSocketSessionMetrics `json:"busiestSessions"`
+}
+
+// WebSocketSessionMetrics carries no user, session or device identifiers, any
+// signed in user can read the metrics.
+type WebSocketSessionMetrics struct {
+	QueueDepth int    `json:"queueDepth"`
+	HighWater  int    `json:"highWater"`
+	Dropped    uint64 `json:"dropped"`
+}
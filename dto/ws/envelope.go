@@ -0,0 +1,30 @@
+package ws
+
+import "encoding/json"
+
+// Subprotocols negotiated through Sec-WebSocket-Protocol. Clients that do not
+// ask for one are served the legacy flat frames of ProtocolV1.
+const (
+	ProtocolV1 = "chat.v1"
+	ProtocolV2 = "chat.v2"
+)
+
+const (
+	Version1 = 1
+	Version2 = 2
+)
+
+// Envelope is the wire format of ProtocolV2 frames in both directions.
+type Envelope struct {
+	V       int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Event is an outbound frame before it is encoded for the session's protocol.
+type Event struct {
+	Type    string
+	ID      string
+	Payload interface{}
+}
@@ -0,0 +1,49 @@
+package ws
+
+// Client -> server commands.
+const (
+	TypeAuth           = "auth"
+	TypePing           = "ping"
+	TypeJoinRoom       = "join_room"
+	TypeLeaveRoom      = "leave_room"
+	TypeSendMessage    = "send_message"
+	TypeTyping         = "typing"
+	TypeStopTyping     = "stop_typing"
+	TypeSync           = "sync"
+	TypeEditMessage    = "edit_message"
+	TypeDeleteMessage  = "delete_message"
+	TypeAddReaction    = "add_reaction"
+	TypeRemoveReaction = "remove_reaction"
+	TypeMessageAck     = "ack" // confirms new_message events reached the client
+	TypeMarkRead       = "mark_read"
+
+	TypeSetPresence         = "set_presence"
+	TypeSubscribePresence   = "subscribe_presence"
+	TypeUnsubscribePresence = "unsubscribe_presence"
+)
+
+// Server -> client events.
+const (
+	TypeConnected       = "connected"
+	TypeAuthenticated   = "authenticated"
+	TypePong            = "pong"
+	TypeJoinedRoom      = "joined_room"
+	TypeNewMessage      = "new_message"
+	TypeNewChat         = "new_chat"
+	TypeChatUpdate      = "chat_update"
+	TypeChatCreated     = "chat_created"
+	TypeMemberAdded     = "member_added"
+	TypeMemberRemoved   = "member_removed"
+	TypeGroupRenamed    = "group_renamed"
+	TypeRoleChanged     = "role_changed"
+	TypeGroupDeleted    = "group_deleted"
+	TypeMessageEdited   = "message_edited"
+	TypeMessageDeleted  = "message_deleted"
+	TypeReactionUpdated = "reaction_updated"
+	TypeImageReady      = "image_ready"
+	TypeMessageStatus   = "message_status"
+	TypeReadReceipt     = "read_receipt"
+	TypePresence        = "presence"
+	TypeAck             = "ack"
+	TypeError           = "error"
+)
@@ -0,0 +1,237 @@
+package ws
+
+import "real-time-chat-app/dto"
+
+type AuthPayload struct {
+	Token string `json:"token"`
+}
+
+type JoinRoomPayload struct {
+	ChatID     string `json:"chatId"`
+	ReceiverID string `json:"receiverId,omitempty"`
+}
+
+type LeaveRoomPayload struct {
+	ChatID string `json:"chatId"`
+}
+
+type SendMessagePayload struct {
+	ChatID        string   `json:"chatId"`
+	ReceiverID    string   `json:"receiverId,omitempty"`
+	Content       string   `json:"content"`
+	ReplyToID     string   `json:"replyToId,omitempty"`
+	AttachmentIDs []string `json:"attachmentIds,omitempty"`
+}
+
+type EditMessagePayload struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId"`
+	Content   string `json:"content"`
+}
+
+type DeleteMessagePayload struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId"`
+	Scope     string `json:"scope"` // "me" | "everyone"
+}
+
+type ReactionPayload struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId"`
+	Emoji     string `json:"emoji"`
+}
+
+type MessageAckPayload struct {
+	MessageIDs []string `json:"messageIds"`
+}
+
+// MarkReadPayload marks everything up to MessageID as read, the latest
+// message when it is empty.
+type MarkReadPayload struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId,omitempty"`
+}
+
+// SetPresencePayload reports the client's own state, "online" or "away".
+type SetPresencePayload struct {
+	Status string `json:"status"`
+}
+
+// PresenceSubscriptionPayload names the users to watch, every contact when empty.
+type PresenceSubscriptionPayload struct {
+	UserIDs []string `json:"userIds,omitempty"`
+}
+
+type TypingPayload struct {
+	ChatID string `json:"chatId"`
+}
+
+type ConnectedPayload struct {
+	UserID    string `json:"userId"`
+	SessionID string `json:"sessionId"`
+	Device    string `json:"device"`
+	Protocol  string `json:"protocol"`
+}
+
+type AuthenticatedPayload struct {
+	UserID    string `json:"userId"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+type PongPayload struct {
+	ServerTime int64 `json:"serverTime"`
+}
+
+type JoinedRoomPayload struct {
+	ChatID string `json:"chatId"`
+}
+
+type NewChatPayload struct {
+	ChatID       string `json:"chatId"`
+	ChatUsername string `json:"chatUsername"`
+	ChatAvatar   string `json:"chatAvatar"`
+	ChatType     string `json:"chatType"`
+	LastMessage  string `json:"lastMessage"`
+	UnreadCount  int64  `json:"unreadCount"`
+}
+
+type ChatUpdatePayload struct {
+	ChatID          string `json:"chatId"`
+	ChatUsername    string `json:"chatUsername"`
+	ChatAvatar      string `json:"chatAvatar"`
+	LastMessage     string `json:"lastMessage"`
+	LastMessageTime string `json:"lastMessageTime"`
+	UnreadCount     int64  `json:"unreadCount"`
+}
+
+type ChatCreatedPayload struct {
+	ChatID       string   `json:"chatId"`
+	ChatType     string   `json:"chatType"`
+	GroupName    string   `json:"groupName,omitempty"`
+	Participants []string `json:"participants"`
+}
+
+// ReadReceiptPayload moves a participant's read position up to MessageID.
+type ReadReceiptPayload struct {
+	ChatID    string `json:"chatId"`
+	UserID    string `json:"userId"`
+	MessageID string `json:"messageId"`
+	ReadSeq   int64  `json:"readSeq"` // chat seq of MessageID
+	ReadAt    string `json:"readAt"`
+	Seq       int64  `json:"seq,omitempty"` // zero when the position did not move
+}
+
+type MemberRole struct {
+	UserID string `json:"userId"`
+	Role   string `json:"role"`
+}
+
+type MemberAddedPayload struct {
+	ChatID    string       `json:"chatId"`
+	GroupName string       `json:"groupName"`
+	ActorID   string       `json:"actorId"`
+	UserIDs   []string     `json:"userIds"`
+	Members   []MemberRole `json:"members"`
+	Seq       int64        `json:"seq"`
+}
+
+type MemberRemovedPayload struct {
+	ChatID      string       `json:"chatId"`
+	ActorID     string       `json:"actorId"`
+	UserIDs     []string     `json:"userIds"`
+	Members     []MemberRole `json:"members"` // removed users with the role they held
+	Left        bool         `json:"left"`
+	RoleChanges []MemberRole `json:"roleChanges,omitempty"` // ownership handed over when the owner leaves
+	Seq         int64        `json:"seq"`
+}
+
+type RoleChangedPayload struct {
+	ChatID  string       `json:"chatId"`
+	ActorID string       `json:"actorId"`
+	Changes []MemberRole `json:"changes"`
+	Seq     int64        `json:"seq"`
+}
+
+type GroupDeletedPayload struct {
+	ChatID  string `json:"chatId"`
+	ActorID string `json:"actorId"`
+	Seq     int64  `json:"seq"`
+}
+
+type GroupRenamedPayload struct {
+	ChatID    string `json:"chatId"`
+	ActorID   string `json:"actorId"`
+	GroupName string `json:"groupName"`
+	Seq       int64  `json:"seq"`
+}
+
+type MessageEditedPayload struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId"`
+	Content   string `json:"content"`
+	EditedBy  string `json:"editedBy"`
+	EditedAt  string `json:"editedAt"`
+	Seq       int64  `json:"seq"`
+}
+
+type MessageDeletedPayload struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId"`
+	Scope     string `json:"scope"`
+	DeletedBy string `json:"deletedBy"`
+	DeletedAt string `json:"deletedAt"`
+	Seq       int64  `json:"seq,omitempty"` // only delete-for-everyone enters the chat log
+}
+
+type ReactionUpdatedPayload struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId"`
+	UserID    string `json:"userId"`
+	Emoji     string `json:"emoji"`
+	Added     bool   `json:"added"`
+	Count     int64  `json:"count"`         // reactions with this emoji after the change
+	Seq       int64  `json:"seq,omitempty"` // zero when the reaction was already in that state
+}
+
+// ImageReadyPayload reports the end of image processing, the attachment's
+// mediaStatus tells whether previews were generated.
+type ImageReadyPayload struct {
+	ChatID     string         `json:"chatId"`
+	MessageID  string         `json:"messageId"`
+	Attachment dto.Attachment `json:"attachment"`
+	Seq        int64          `json:"seq"`
+}
+
+// MessageStatusPayload tells a sender how far one of their messages got, Status
+// is the aggregate over all recipients.
+type MessageStatusPayload struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId"`
+	Status    string `json:"status"`
+	UserID    string `json:"userId"`         // the recipient whose receipt changed
+	Delivered int64  `json:"deliveredCount"` // includes recipients who read it
+	Read      int64  `json:"readCount"`
+	Total     int64  `json:"recipientCount"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type PresencePayload struct {
+	UserID     string `json:"userId"`
+	Status     string `json:"status"`
+	LastSeenAt string `json:"lastSeenAt,omitempty"` // only set while offline
+}
+
+type TypingEventPayload struct {
+	ChatID   string `json:"chatId"`
+	UserID   string `json:"userId"`
+	IsTyping bool   `json:"isTyping"`
+}
+
+type AckPayload struct {
+	Type   string      `json:"type"`
+	Result interface{} `json:"result,omitempty"`
+}
+
+type ErrorPayload struct {
+	Error string `json:"error"`
+}
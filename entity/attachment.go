@@ -0,0 +1,30 @@
+package entity
+
+import (
+	"real-time-chat-app/enum"
+	"time"
+)
+
+// Attachment is an uploaded file, it belongs to a chat right away and to a
+// message once that message is sent.
+type Attachment struct {
+	BaseEntity
+	ChatID     string  `json:"chatId" gorm:"type:varchar(255);not null;index"`
+	UploaderID string  `json:"uploaderId" gorm:"type:varchar(255);not null"`
+	MessageID  *string `json:"messageId,omitempty" gorm:"type:varchar(255);index"`
+	FileName   string  `json:"fileName" gorm:"type:varchar(255);not null"`
+	MimeType   string  `json:"mimeType" gorm:"type:varchar(127);not null"`
+	Size       int64   `json:"size" gorm:"not null"`
+	Checksum   string  `json:"checksum" gorm:"type:char(64);not null"` // hex sha256
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	StorageKey string  `json:"-" gorm:"type:varchar(512);not null"`
+
+	// filled in by the media worker for images
+	MediaStatus  enum.MediaStatus `json:"mediaStatus" gorm:"type:varchar(20);not null;default:'none'"`
+	ThumbnailKey string           `json:"-" gorm:"type:varchar(512)"`
+	PreviewKey   string           `json:"-" gorm:"type:varchar(512)"`
+	BlurHash     string           `json:"blurHash,omitempty" gorm:"type:varchar(64)"`
+	// set by the node rendering the image so other nodes leave it alone
+	MediaClaimedAt *time.Time `json:"-"`
+}
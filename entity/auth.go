@@ -1,8 +1,19 @@
 package entity
 
+import "time"
+
 type Account struct {
 	BaseEntity
 	UserName string `json:"userName" gorm:"unique;type:varchar(50)"`
 	Password string `json:"password" gorm:"type:varchar(255)"`
-	User     User   `gorm:"foreignKey:AuthId;references:ID"`
+	// set after repeated failed logins, no password is checked until it passes
+	LockedUntil *time.Time `json:"lockedUntil,omitempty" gorm:"null"`
+
+	// encrypted TOTP secret, pending until the first code confirms enrollment
+	TOTPSecret  string `json:"-" gorm:"column:totp_secret;type:text"`
+	TOTPEnabled bool   `json:"totpEnabled" gorm:"column:totp_enabled;not null;default:false"`
+	// the last time step a code was accepted for, older codes cannot be replayed
+	TOTPLastStep int64 `json:"-" gorm:"column:totp_last_step;not null;default:0"`
+
+	User User `gorm:"foreignKey:AuthId;references:ID"`
 }
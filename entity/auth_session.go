@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// AuthSession is one login on one device. Its ID is the family of the refresh
+// tokens rotated from that login and the sid claim of its access tokens.
+type AuthSession struct {
+	BaseEntity
+	UserID     string     `json:"userId" gorm:"type:varchar(255);not null;index"`
+	DeviceName string     `json:"deviceName" gorm:"type:varchar(100)"`
+	UserAgent  string     `json:"userAgent" gorm:"type:varchar(512)"`
+	IP         string     `json:"ip" gorm:"type:varchar(64)"`
+	LastUsedAt time.Time  `json:"lastUsedAt" gorm:"not null"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty" gorm:"null"`
+
+	User User `json:"-" gorm:"foreignKey:UserID;references:ID"`
+}
@@ -0,0 +1,7 @@
+package entity
+
+// BrokerPayload holds pub/sub payloads too large for a Postgres NOTIFY.
+type BrokerPayload struct {
+	BaseEntity
+	Payload string `json:"payload" gorm:"type:TEXT"`
+}
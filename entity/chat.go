@@ -1,20 +1,31 @@
 package entity
 
-import "real-time-chat-app/enum"
+import (
+	"real-time-chat-app/enum"
+	"time"
+)
 
 type Chat struct {
 	BaseEntity
 	ChatType  enum.ChatType `json:"chatType" gorm:"type:varchar(7)"`
 	GroupName string        `json:"groupName" gorm:"type:varchar(50);null"`
+	LastSeq   int64         `json:"lastSeq" gorm:"not null;default:0"`
 
 	Participants []ChatParticipant `json:"participants" gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE;"`
 	Messages     []Messages        `json:"messages" gorm:"foreignKey:ChatId;constraint:OnDelete:CASCADE;"`
 }
 
 type ChatParticipant struct {
-	ID     string `gorm:"primaryKey;type:varchar(255);default:gen_random_uuid()"`
-	ChatID string `gorm:"type:varchar(255);not null"`
-	UserID string `gorm:"type:varchar(255);not null"`
+	ID       string               `gorm:"primaryKey;type:varchar(255);default:gen_random_uuid()"`
+	ChatID   string               `gorm:"type:varchar(255);not null"`
+	UserID   string               `gorm:"type:varchar(255);not null"`
+	Role     enum.ParticipantRole `gorm:"type:varchar(10);not null;default:'member'"`
+	JoinedAt time.Time            `gorm:"autoCreateTime"`
+
+	// read watermark, every message up to this chat seq counts as read
+	LastReadSeq       int64      `gorm:"not null;default:0"`
+	LastReadMessageID *string    `gorm:"type:varchar(255)"`
+	LastReadAt        *time.Time `gorm:"null"`
 
 	Chat Chat `gorm:"foreignKey:ChatID;references:ID;constraint:OnDelete:CASCADE;"`
 	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;"`
@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// ChatEvent is the append-only log replayed to reconnecting clients. Seq is
+// strictly increasing per chat and is allocated from Chat.LastSeq. TxID is the
+// writing transaction, filled by the column default added in the migration, and
+// orders events across chats since ids can commit out of order.
+type ChatEvent struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	ChatID    string    `json:"chatId" gorm:"type:varchar(255);not null;uniqueIndex:idx_chat_event_chat_seq"`
+	Seq       int64     `json:"seq" gorm:"not null;uniqueIndex:idx_chat_event_chat_seq"`
+	Type      string    `json:"type" gorm:"type:varchar(50);not null"`
+	Payload   string    `json:"payload" gorm:"type:jsonb"`
+	TxID      int64     `json:"-" gorm:"column:tx_id;->;-:migration"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "real-time-chat-app/enum"
+
+// FailedLogin is the audit record of one rejected login. UserID is empty when
+// the username matched no account.
+type FailedLogin struct {
+	BaseEntity
+	Username  string                  `json:"username" gorm:"type:varchar(50);not null;index"`
+	UserID    *string                 `json:"userId,omitempty" gorm:"type:varchar(255);index"`
+	IP        string                  `json:"ip" gorm:"type:varchar(64);index"`
+	UserAgent string                  `json:"userAgent" gorm:"type:varchar(512)"`
+	Reason    enum.LoginFailureReason `json:"reason" gorm:"type:varchar(20);not null"`
+}
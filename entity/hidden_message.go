@@ -0,0 +1,8 @@
+package entity
+
+// HiddenMessage marks a message a user deleted for themselves only.
+type HiddenMessage struct {
+	BaseEntity
+	MessageID string `json:"messageId" gorm:"type:varchar(255);not null;uniqueIndex:idx_hidden_message_user"`
+	UserID    string `json:"userId" gorm:"type:varchar(255);not null;uniqueIndex:idx_hidden_message_user"`
+}
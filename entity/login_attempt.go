@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"real-time-chat-app/enum"
+	"time"
+)
+
+// LoginAttempt counts the recent failed logins of one username or one IP.
+// Locked marks BlockedUntil as an account lockout rather than a backoff.
+type LoginAttempt struct {
+	BaseEntity
+	Scope        enum.LoginAttemptScope `json:"scope" gorm:"type:varchar(10);not null;uniqueIndex:idx_login_attempt_subject"`
+	Subject      string                 `json:"subject" gorm:"type:varchar(255);not null;uniqueIndex:idx_login_attempt_subject"`
+	Failures     int                    `json:"failures" gorm:"not null;default:0"`
+	LastFailedAt time.Time              `json:"lastFailedAt" gorm:"not null;index"`
+	BlockedUntil *time.Time             `json:"blockedUntil,omitempty" gorm:"null"`
+	Locked       bool                   `json:"locked" gorm:"not null;default:false"`
+}
@@ -0,0 +1,9 @@
+package entity
+
+// MessageEdit keeps the content a message had before one of its edits.
+type MessageEdit struct {
+	BaseEntity
+	MessageID       string `json:"messageId" gorm:"type:varchar(255);not null;index"`
+	PreviousContent string `json:"previousContent" gorm:"type:TEXT"`
+	EditedBy        string `json:"editedBy" gorm:"type:varchar(255);not null"`
+}
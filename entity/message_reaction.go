@@ -0,0 +1,9 @@
+package entity
+
+// MessageReaction is one emoji a user put on a message.
+type MessageReaction struct {
+	BaseEntity
+	MessageID string `json:"messageId" gorm:"type:varchar(255);not null;uniqueIndex:idx_message_reaction_user_emoji"`
+	UserID    string `json:"userId" gorm:"type:varchar(255);not null;uniqueIndex:idx_message_reaction_user_emoji"`
+	Emoji     string `json:"emoji" gorm:"type:varchar(64);not null;uniqueIndex:idx_message_reaction_user_emoji"`
+}
@@ -1,11 +1,18 @@
 package entity
 
-import "time"
+import (
+	"real-time-chat-app/enum"
+	"time"
+)
 
+// MessageStatus is the receipt of one recipient, it moves from sent to
+// delivered to read and never back.
 type MessageStatus struct {
 	BaseEntity
-	IsRead    bool      `json:"isRead" gorm:"default:false"`
-	ReadAt    time.Time `json:"readAt" gorm:"null"`
-	MessageID string    `json:"messageID" gorm:"foreignKey"`
-	UserID    string    `json:"userID" gorm:"foreignKey"`
+	IsRead      bool               `json:"isRead" gorm:"default:false"`
+	ReadAt      time.Time          `json:"readAt" gorm:"null"`
+	MessageID   string             `json:"messageID" gorm:"foreignKey;index:idx_message_status_message_user"`
+	UserID      string             `json:"userID" gorm:"foreignKey;index:idx_message_status_message_user"`
+	Status      enum.MessageStatus `json:"status" gorm:"type:varchar(20);not null;default:'sent'"`
+	DeliveredAt *time.Time         `json:"deliveredAt,omitempty" gorm:"null"`
 }
@@ -1,6 +1,9 @@
 package entity
 
-import "real-time-chat-app/enum"
+import (
+	"real-time-chat-app/enum"
+	"time"
+)
 
 type Messages struct {
 	BaseEntity
@@ -8,7 +11,21 @@ type Messages struct {
 	ChatId   string             `json:"chatId" gorm:"foreignKey"`
 	SenderId string             `json:"senderId" gorm:"foreignKey"`
 	Status   enum.MessageStatus ` json:"status" gorm:"type:varchar(20);default:'sent'"`
+	Type     enum.MessageType   `json:"type" gorm:"type:varchar(20);not null;default:'text'"`
+	Seq      int64              `json:"seq" gorm:"not null;default:0;index"`
+	IsEdited bool               `json:"isEdited" gorm:"not null;default:false"`
+	EditedAt *time.Time         `json:"editedAt,omitempty" gorm:"null"`
 
-	Chat   Chat `json:"-" gorm:"foreignKey:ChatId;references:ID"`
-	Sender User `json:"-" gorm:"foreignKey:SenderId;references:ID"`
+	// delete-for-everyone keeps the row as a tombstone so history stays ordered
+	IsDeleted            bool       `json:"isDeleted" gorm:"not null;default:false"`
+	DeletedForEveryoneAt *time.Time `json:"deletedForEveryoneAt,omitempty" gorm:"null"`
+
+	ReplyToID  *string `json:"replyToId,omitempty" gorm:"type:varchar(255);index"`
+	ReplyCount int64   `json:"replyCount" gorm:"not null;default:0"`
+
+	Chat    Chat      `json:"-" gorm:"foreignKey:ChatId;references:ID"`
+	Sender  User      `json:"-" gorm:"foreignKey:SenderId;references:ID"`
+	ReplyTo *Messages `json:"-" gorm:"foreignKey:ReplyToID;references:ID"`
+
+	Attachments []Attachment `json:"-" gorm:"foreignKey:MessageID;references:ID"`
 }
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// RecoveryCode is a one-time code that stands in for a TOTP code, only its hash is kept.
+type RecoveryCode struct {
+	BaseEntity
+	AccountID string     `json:"accountId" gorm:"type:varchar(255);not null;index"`
+	CodeHash  string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" gorm:"null"`
+
+	Account Account `json:"-" gorm:"foreignKey:AccountID;references:ID"`
+}
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// RefreshToken is one link of a rotation chain. Every token issued from the same
+// login shares a FamilyID, presenting a used token again revokes the family.
+type RefreshToken struct {
+	BaseEntity
+	UserID    string    `json:"userId" gorm:"type:varchar(255);not null;index"`
+	FamilyID  string    `json:"familyId" gorm:"type:varchar(255);not null;index"`
+	TokenHash string    `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"not null"`
+
+	// the access token issued together with this refresh token, denylisted when the family is revoked
+	AccessJTI       string    `json:"-" gorm:"type:varchar(255);index"`
+	AccessExpiresAt time.Time `json:"-"`
+
+	UsedAt       *time.Time `json:"usedAt,omitempty" gorm:"null"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty" gorm:"null"`
+	ReplacedByID *string    `json:"replacedById,omitempty" gorm:"type:varchar(255)"`
+
+	User User `json:"-" gorm:"foreignKey:UserID;references:ID"`
+}
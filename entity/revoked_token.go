@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// RevokedToken denylists an access token by its jti until it would have expired anyway.
+type RevokedToken struct {
+	BaseEntity
+	JTI       string    `json:"jti" gorm:"type:varchar(255);not null;uniqueIndex"`
+	UserID    string    `json:"userId" gorm:"type:varchar(255);not null"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"not null;index"`
+}
@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 type User struct {
 	BaseEntity
 	Name        string `json:"name" gorm:"type:varchar(255)"`
@@ -8,6 +10,9 @@ type User struct {
 	PhoneNumber string `json:"phoneNumber" gorm:"unique;type:varchar(20)"`
 	AuthId      string `json:"authId" gorm:"type:varchar(255);unique"`
 
+	// when the user's last connection closed
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty" gorm:"null"`
+
 	Messages      []Messages        `json:"-" gorm:"foreignKey:SenderId"`
 	Participating []ChatParticipant `json:"-" gorm:"foreignKey:UserID"`
 }
@@ -0,0 +1,8 @@
+package enum
+
+type DeleteScope string
+
+const (
+	DeleteScopeMe       DeleteScope = "me"
+	DeleteScopeEveryone DeleteScope = "everyone"
+)
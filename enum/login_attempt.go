@@ -0,0 +1,20 @@
+package enum
+
+// LoginAttemptScope is what failed logins are counted against.
+type LoginAttemptScope string
+
+const (
+	LoginScopeUsername LoginAttemptScope = "username"
+	LoginScopeIP       LoginAttemptScope = "ip"
+)
+
+// LoginFailureReason is why a login was rejected, kept in the audit log.
+type LoginFailureReason string
+
+const (
+	LoginUnknownUser LoginFailureReason = "unknown_user"
+	LoginBadPassword LoginFailureReason = "bad_password"
+	LoginBadCode     LoginFailureReason = "bad_code"
+	LoginThrottled   LoginFailureReason = "throttled"
+	LoginLocked      LoginFailureReason = "locked"
+)
@@ -0,0 +1,19 @@
+package enum
+
+// MediaStatus tracks the background processing of an uploaded image.
+type MediaStatus string
+
+const (
+	MediaStatusNone       MediaStatus = "none"
+	MediaStatusProcessing MediaStatus = "processing"
+	MediaStatusReady      MediaStatus = "ready"
+	MediaStatusFailed     MediaStatus = "failed"
+)
+
+type MediaVariant string
+
+const (
+	MediaVariantOriginal  MediaVariant = ""
+	MediaVariantThumbnail MediaVariant = "thumbnail"
+	MediaVariantPreview   MediaVariant = "preview"
+)
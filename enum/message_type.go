@@ -0,0 +1,9 @@
+package enum
+
+type MessageType string
+
+const (
+	MessageTypeText  MessageType = "text"
+	MessageTypeImage MessageType = "image"
+	MessageTypeFile  MessageType = "file"
+)
@@ -0,0 +1,9 @@
+package enum
+
+type ParticipantRole string
+
+const (
+	RoleOwner  ParticipantRole = "owner"
+	RoleAdmin  ParticipantRole = "admin"
+	RoleMember ParticipantRole = "member"
+)
@@ -0,0 +1,10 @@
+package enum
+
+// PresenceStatus is a user's availability over all of their connections.
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceAway    PresenceStatus = "away"
+	PresenceOffline PresenceStatus = "offline"
+)
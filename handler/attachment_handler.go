@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"mime"
+	"real-time-chat-app/dto"
+	"real-time-chat-app/dto/res"
+	"real-time-chat-app/enum"
+	"strconv"
+	"strings"
+)
+
+func (handler *ChatHandler) UploadAttachment(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Upload attachment")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return handler.badRequest(c, "file is required", err)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return handler.badRequest(c, "failed to read uploaded file", err)
+	}
+	defer file.Close()
+
+	attachment, err := handler.AttachmentUsecase.UploadAttachment(c.Context(), userID, chatId, fileHeader.Filename, file)
+	if err != nil {
+		return handler.chatError(c, "Failed to upload attachment", err)
+	}
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusCreated).
+		Str("chatId", chatId).
+		Str("attachmentId", attachment.AttachmentID).
+		Int64("size", attachment.Size).
+		Msg("Response: Attachment uploaded")
+
+	return c.Status(fiber.StatusCreated).JSON(res.CommonResponse[dto.Attachment]{
+		Message:    "Successfully to Upload Attachment",
+		StatusCode: fiber.StatusCreated,
+		Data:       attachment,
+	})
+}
+
+func (handler *ChatHandler) DownloadAttachment(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+	attachmentId := c.Params("attachmentId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("attachmentId", attachmentId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Download attachment")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	variant := enum.MediaVariant(c.Query("variant"))
+	content, err := handler.AttachmentUsecase.OpenAttachment(c.Context(), userID, chatId, attachmentId, variant)
+	if err != nil {
+		return handler.chatError(c, "Failed to download attachment", err)
+	}
+
+	// images render in place, everything else is saved as a file
+	disposition := "attachment"
+	if strings.HasPrefix(content.MimeType, "image/") {
+		disposition = "inline"
+	}
+	if formatted := mime.FormatMediaType(disposition, map[string]string{"filename": content.FileName}); formatted != "" {
+		disposition = formatted
+	}
+
+	c.Set(fiber.HeaderContentType, content.MimeType)
+	c.Set(fiber.HeaderContentDisposition, disposition)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=86400")
+	c.Set(fiber.HeaderETag, strconv.Quote(content.ETag))
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Str("attachmentId", attachmentId).
+		Str("variant", string(variant)).
+		Int64("size", content.Size).
+		Msg("Response: Streaming attachment")
+
+	// the stream is closed by fasthttp once the body is written
+	return c.SendStream(content.Body, int(content.Size))
+}
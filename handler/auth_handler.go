@@ -1,11 +1,16 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	"real-time-chat-app/config/logger"
 	"real-time-chat-app/dto/req"
 	"real-time-chat-app/dto/res"
+	"real-time-chat-app/security"
 	"real-time-chat-app/usecase"
+	"strconv"
+	"time"
 )
 
 type AuthHandler struct {
@@ -120,6 +125,9 @@ func (handler *AuthHandler) LoginUser(ctx *fiber.Ctx) error {
 		})
 	}
 
+	payload.UserAgent = ctx.Get("User-Agent")
+	payload.IP = ctx.IP()
+
 	handler.AppLogger.Http.Info.Info().
 		Str("username", payload.Username).
 		Msg("Processing login request")
@@ -132,21 +140,31 @@ func (handler *AuthHandler) LoginUser(ctx *fiber.Ctx) error {
 			Str("username", payload.Username).
 			Msg("Login failed")
 
+		// throttled and locked logins tell the client when to come back
+		statusCode := fiber.StatusUnauthorized
+		if setRetryAfter(ctx, err) {
+			statusCode = authErrorStatus(err)
+		}
+
 		// 📤 STREAM: Log error response
 		handler.AppLogger.Http.Stream.Error().
 			Err(err).
-			Int("statusCode", fiber.StatusUnauthorized).
+			Int("statusCode", statusCode).
 			Str("username", payload.Username).
 			Msg("Response: Login failed")
 
-		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		return ctx.Status(statusCode).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
 	// Success response
+	message := "Successfully to login"
+	if loginResponse.TwoFactorRequired {
+		message = "Two-factor code required"
+	}
 	response := res.CommonResponse[res.LoginResponse]{
-		Message:    "Successfully to login",
+		Message:    message,
 		StatusCode: fiber.StatusOK,
 		Data:       loginResponse,
 	}
@@ -164,3 +182,274 @@ func (handler *AuthHandler) LoginUser(ctx *fiber.Ctx) error {
 
 	return ctx.Status(fiber.StatusOK).JSON(response)
 }
+
+func (handler *AuthHandler) RefreshToken(ctx *fiber.Ctx) error {
+	handler.AppLogger.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("ip", ctx.IP()).
+		Str("userAgent", ctx.Get("User-Agent")).
+		Msg("Incoming refresh token request")
+
+	payload := new(req.RefreshRequest)
+	if err := ctx.BodyParser(payload); err != nil {
+		handler.AppLogger.Http.Error.Error().
+			Err(err).
+			Str("path", ctx.Path()).
+			Msg("Failed to parse refresh request body")
+
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", fiber.StatusBadRequest).
+			Msg("Response: Bad request - invalid body")
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	payload.UserAgent = ctx.Get("User-Agent")
+	payload.IP = ctx.IP()
+
+	refreshResponse, err := handler.AuthUsecase.RefreshToken(ctx.Context(), payload)
+	if err != nil {
+		statusCode := authErrorStatus(err)
+
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", statusCode).
+			Msg("Response: Refresh failed")
+
+		return ctx.Status(statusCode).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	handler.AppLogger.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Msg("Response: Token refreshed")
+
+	return ctx.Status(fiber.StatusOK).JSON(res.CommonResponse[res.LoginResponse]{
+		Message:    "Successfully to refresh token",
+		StatusCode: fiber.StatusOK,
+		Data:       refreshResponse,
+	})
+}
+
+func (handler *AuthHandler) Logout(ctx *fiber.Ctx) error {
+	handler.AppLogger.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("ip", ctx.IP()).
+		Str("userAgent", ctx.Get("User-Agent")).
+		Msg("Incoming logout request")
+
+	userID, access, ok := accessTokenOf(ctx)
+	if !ok {
+		handler.AppLogger.Http.Stream.Error().
+			Int("statusCode", fiber.StatusUnauthorized).
+			Msg("Response: Unauthorized - invalid token")
+
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token",
+		})
+	}
+
+	// the body is optional, without a refresh token the caller's own session is logged out
+	payload := new(req.LogoutRequest)
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(payload); err != nil {
+			handler.AppLogger.Http.Stream.Error().
+				Err(err).
+				Int("statusCode", fiber.StatusBadRequest).
+				Msg("Response: Bad request - invalid body")
+
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	if err := handler.AuthUsecase.Logout(ctx.Context(), userID, access, payload); err != nil {
+		statusCode := authErrorStatus(err)
+
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", statusCode).
+			Str("userId", userID).
+			Msg("Response: Logout failed")
+
+		return ctx.Status(statusCode).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	handler.AppLogger.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("userId", userID).
+		Msg("Response: Logged out")
+
+	return ctx.Status(fiber.StatusOK).JSON(res.CommonResponse[any]{
+		Message:    "Successfully to logout",
+		StatusCode: fiber.StatusOK,
+	})
+}
+
+func (handler *AuthHandler) GetSessions(ctx *fiber.Ctx) error {
+	handler.AppLogger.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("ip", ctx.IP()).
+		Msg("Incoming request: Get sessions")
+
+	userID, access, ok := accessTokenOf(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token",
+		})
+	}
+
+	sessions, err := handler.AuthUsecase.ListSessions(ctx.Context(), userID, access.SessionID)
+	if err != nil {
+		statusCode := authErrorStatus(err)
+
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", statusCode).
+			Str("userId", userID).
+			Msg("Response: Failed to get sessions")
+
+		return ctx.Status(statusCode).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	handler.AppLogger.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("userId", userID).
+		Int("sessionCount", len(sessions)).
+		Msg("Response: Successfully retrieved sessions")
+
+	return ctx.Status(fiber.StatusOK).JSON(res.CommonResponse[[]res.SessionResponse]{
+		Message:    "Successfully to get sessions",
+		StatusCode: fiber.StatusOK,
+		Data:       sessions,
+	})
+}
+
+func (handler *AuthHandler) RevokeSession(ctx *fiber.Ctx) error {
+	sessionID := ctx.Params("id")
+
+	handler.AppLogger.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("sessionId", sessionID).
+		Str("ip", ctx.IP()).
+		Msg("Incoming request: Revoke session")
+
+	userID, _, ok := accessTokenOf(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token",
+		})
+	}
+
+	if err := handler.AuthUsecase.RevokeSession(ctx.Context(), userID, sessionID); err != nil {
+		statusCode := authErrorStatus(err)
+
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", statusCode).
+			Str("userId", userID).
+			Str("sessionId", sessionID).
+			Msg("Response: Failed to revoke session")
+
+		return ctx.Status(statusCode).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	handler.AppLogger.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("userId", userID).
+		Str("sessionId", sessionID).
+		Msg("Response: Session revoked")
+
+	return ctx.Status(fiber.StatusOK).JSON(res.CommonResponse[any]{
+		Message:    "Successfully to revoke session",
+		StatusCode: fiber.StatusOK,
+	})
+}
+
+// accessTokenOf reads the token JWTProtected already verified.
+func accessTokenOf(ctx *fiber.Ctx) (string, security.AccessToken, bool) {
+	token, ok := ctx.Locals("jwt").(*jwt.Token)
+	if !ok {
+		return "", security.AccessToken{}, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", security.AccessToken{}, false
+	}
+
+	userID, _ := claims["user_id"].(string)
+	if userID == "" {
+		return "", security.AccessToken{}, false
+	}
+
+	access := security.AccessToken{Token: token.Raw}
+	access.JTI, _ = claims["jti"].(string)
+	access.SessionID, _ = claims["sid"].(string)
+	if expiresAt, err := claims.GetExpirationTime(); err == nil && expiresAt != nil {
+		access.ExpiresAt = expiresAt.Time
+	}
+	return userID, access, true
+}
+
+// authErrorStatus maps token errors to HTTP status codes.
+func authErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidRefreshToken),
+		errors.Is(err, usecase.ErrRefreshTokenReused):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, usecase.ErrSessionNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, usecase.ErrInvalidChallenge),
+		errors.Is(err, usecase.ErrInvalidTwoFactorCode):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, usecase.ErrInvalidRequest):
+		return fiber.StatusBadRequest
+	case errors.Is(err, usecase.ErrInvalidPassword):
+		return fiber.StatusForbidden
+	case errors.Is(err, usecase.ErrUserNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, usecase.ErrTwoFactorEnabled),
+		errors.Is(err, usecase.ErrTwoFactorNotEnabled),
+		errors.Is(err, usecase.ErrTwoFactorNotPending):
+		return fiber.StatusConflict
+	case errors.Is(err, usecase.ErrTwoFactorUnavailable):
+		return fiber.StatusServiceUnavailable
+	case errors.Is(err, usecase.ErrTooManyAttempts):
+		return fiber.StatusTooManyRequests
+	case errors.Is(err, usecase.ErrAccountLocked):
+		return fiber.StatusLocked
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// setRetryAfter tells a throttled or locked out client when to come back and
+// reports whether err was such a rejection.
+func setRetryAfter(ctx *fiber.Ctx, err error) bool {
+	var blocked *usecase.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		return false
+	}
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfterSeconds(blocked.RetryAfter)))
+	return true
+}
+
+// retryAfterSeconds rounds up so a client honouring Retry-After never comes back early.
+func retryAfterSeconds(wait time.Duration) int {
+	return int((wait + time.Second - 1) / time.Second)
+}
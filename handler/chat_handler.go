@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
+	"net/url"
 	"real-time-chat-app/config/logger"
+	"real-time-chat-app/dto/req"
 	"real-time-chat-app/dto/res"
+	"real-time-chat-app/dto/ws"
+	"real-time-chat-app/enum"
 	"real-time-chat-app/security"
 	"real-time-chat-app/usecase"
 )
@@ -11,12 +16,14 @@ import (
 type ChatHandler struct {
 	usecase.ChatUsecase
 	usecase.MessageUsecase
+	usecase.AttachmentUsecase
 	Log *logger.AppLogger
 	*security.JWT
+	Notifier Notifier
 }
 
-func NewChatHandler(chatUsecase usecase.ChatUsecase, messageUsecase usecase.MessageUsecase, logger *logger.AppLogger, JWT *security.JWT) *ChatHandler {
-	return &ChatHandler{ChatUsecase: chatUsecase, MessageUsecase: messageUsecase, Log: logger, JWT: JWT}
+func NewChatHandler(chatUsecase usecase.ChatUsecase, messageUsecase usecase.MessageUsecase, attachmentUsecase usecase.AttachmentUsecase, logger *logger.AppLogger, JWT *security.JWT, notifier Notifier) *ChatHandler {
+	return &ChatHandler{ChatUsecase: chatUsecase, MessageUsecase: messageUsecase, AttachmentUsecase: attachmentUsecase, Log: logger, JWT: JWT, Notifier: notifier}
 }
 
 func (handler *ChatHandler) GetAllChat(c *fiber.Ctx) error {
@@ -92,6 +99,23 @@ func (handler *ChatHandler) GetMessagesByID(c *fiber.Ctx) error {
 		})
 	}
 
+	var page req.MessagePageRequest
+	if err := c.QueryParser(&page); err != nil {
+		handler.Log.Http.Warning.Warn().
+			Err(err).
+			Str("chatId", chatId).
+			Msg("Invalid pagination query")
+
+		handler.Log.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", fiber.StatusBadRequest).
+			Msg("Response: Bad request - invalid pagination query")
+
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid pagination query",
+		})
+	}
+
 	token := c.Get("Authorization")[7:]
 
 	handler.Log.Http.Info.Info().
@@ -99,8 +123,10 @@ func (handler *ChatHandler) GetMessagesByID(c *fiber.Ctx) error {
 		Msg("Processing get messages request")
 
 	ctx := c.Context()
-	messages, err := handler.ChatUsecase.GetMessagesByChatID(ctx, token, chatId)
+	messagePage, err := handler.ChatUsecase.GetMessagesByChatID(ctx, token, chatId, page)
 	if err != nil {
+		statusCode := chatErrorStatus(err)
+
 		handler.Log.Http.Error.Error().
 			Err(err).
 			Str("chatId", chatId).
@@ -108,29 +134,67 @@ func (handler *ChatHandler) GetMessagesByID(c *fiber.Ctx) error {
 
 		handler.Log.Http.Stream.Error().
 			Err(err).
-			Int("statusCode", fiber.StatusInternalServerError).
+			Int("statusCode", statusCode).
 			Str("chatId", chatId).
 			Msg("Response: Failed to get messages")
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(statusCode).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
 	handler.Log.Http.Info.Info().
 		Str("chatId", chatId).
-		Int("messageCount", len(messages)).
+		Int("messageCount", len(messagePage.Messages)).
 		Msg("Successfully retrieved messages")
 
 	handler.Log.Http.Stream.Info().
 		Int("statusCode", fiber.StatusOK).
 		Str("chatId", chatId).
-		Int("messageCount", len(messages)).
+		Int("messageCount", len(messagePage.Messages)).
 		Msg("Response: Successfully retrieved messages")
 
-	return c.JSON(fiber.Map{
-		"chatId":   chatId,
-		"messages": messages,
+	return c.JSON(messagePage)
+}
+
+func (handler *ChatHandler) GetReplies(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+	messageId := c.Params("messageId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Get message replies")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	var page req.MessagePageRequest
+	if err := c.QueryParser(&page); err != nil {
+		return handler.badRequest(c, "invalid pagination query", err)
+	}
+
+	thread, err := handler.ChatUsecase.GetReplies(c.Context(), userID, chatId, messageId, page)
+	if err != nil {
+		return handler.chatError(c, "Failed to get message replies", err)
+	}
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Int("replyCount", len(thread.Replies)).
+		Msg("Response: Successfully retrieved message replies")
+
+	return c.JSON(res.CommonResponse[res.ThreadResponse]{
+		Message:    "Successfully to Get Message Replies",
+		StatusCode: fiber.StatusOK,
+		Data:       thread,
 	})
 }
 
@@ -144,63 +208,336 @@ func (handler *ChatHandler) MarkMessagesAsRead(c *fiber.Ctx) error {
 		Str("ip", c.IP()).
 		Msg("Incoming request: Mark messages as read")
 
-	token := c.Get("Authorization")[7:]
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
 
-	userID, err := handler.JWT.GetUserIdFromToken(token)
-	if err != nil {
-		handler.Log.Http.Error.Error().
-			Err(err).
-			Str("chatId", chatId).
-			Msg("Invalid token - failed to extract user ID")
+	// the body is optional, without a message id the whole chat is read
+	var request req.MarkReadRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			return handler.badRequest(c, "invalid request body", err)
+		}
+	}
 
-		handler.Log.Http.Stream.Error().
-			Err(err).
-			Int("statusCode", fiber.StatusUnauthorized).
-			Msg("Response: Unauthorized - invalid token")
+	receipt, err := handler.MessageUsecase.MarkRead(c.Context(), userID, chatId, request.MessageID)
+	if err != nil {
+		return handler.chatError(c, "Failed to mark messages as read", err)
+	}
 
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "invalid token",
-		})
+	if receipt.Seq != 0 {
+		handler.Notifier.NotifyRoom(chatId, ws.Event{Type: ws.TypeReadReceipt, Payload: receipt})
 	}
 
-	handler.Log.Http.Info.Info().
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
 		Str("chatId", chatId).
 		Str("userId", userID).
-		Msg("Processing mark messages as read")
+		Int64("readSeq", receipt.ReadSeq).
+		Msg("Response: Messages marked as read")
 
-	ctx := c.Context()
-	err = handler.MessageUsecase.MarkMessagesAsRead(ctx, chatId, userID)
+	return c.JSON(res.CommonResponse[ws.ReadReceiptPayload]{
+		Message:    "Successfully to Mark Messages as Read",
+		StatusCode: fiber.StatusOK,
+		Data:       receipt,
+	})
+}
+
+func (handler *ChatHandler) EditMessage(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+	messageId := c.Params("messageId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Edit message")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	var request req.EditMessageRequest
+	if err := c.BodyParser(&request); err != nil {
+		return handler.badRequest(c, "invalid request body", err)
+	}
+
+	edited, err := handler.MessageUsecase.EditMessage(c.Context(), userID, chatId, messageId, request.Content)
 	if err != nil {
-		handler.Log.Http.Error.Error().
-			Err(err).
-			Str("chatId", chatId).
-			Str("userId", userID).
-			Msg("Failed to mark messages as read")
+		return handler.chatError(c, "Failed to edit message", err)
+	}
 
-		handler.Log.Http.Stream.Error().
-			Err(err).
-			Int("statusCode", fiber.StatusInternalServerError).
-			Str("chatId", chatId).
-			Msg("Response: Failed to mark messages as read")
+	handler.Notifier.NotifyRoom(chatId, ws.Event{Type: ws.TypeMessageEdited, Payload: edited})
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Msg("Response: Message edited")
+
+	return c.JSON(res.CommonResponse[ws.MessageEditedPayload]{
+		Message:    "Successfully to Edit Message",
+		StatusCode: fiber.StatusOK,
+		Data:       edited,
+	})
+}
+
+func (handler *ChatHandler) DeleteMessage(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+	messageId := c.Params("messageId")
+	scope := enum.DeleteScope(c.Query("scope", string(enum.DeleteScopeMe)))
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Str("scope", string(scope)).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Delete message")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
 	}
 
-	handler.Log.Http.Info.Info().
+	deleted, err := handler.MessageUsecase.DeleteMessage(c.Context(), userID, chatId, messageId, scope)
+	if err != nil {
+		return handler.chatError(c, "Failed to delete message", err)
+	}
+
+	event := ws.Event{Type: ws.TypeMessageDeleted, Payload: deleted}
+	if scope == enum.DeleteScopeEveryone {
+		handler.Notifier.NotifyRoom(chatId, event)
+	} else {
+		// keeps the user's other devices in step
+		handler.Notifier.NotifyUsers("", []string{userID}, event)
+	}
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
 		Str("chatId", chatId).
-		Str("userId", userID).
-		Msg("Messages marked as read successfully")
+		Str("messageId", messageId).
+		Str("scope", string(scope)).
+		Msg("Response: Message deleted")
+
+	return c.JSON(res.CommonResponse[ws.MessageDeletedPayload]{
+		Message:    "Successfully to Delete Message",
+		StatusCode: fiber.StatusOK,
+		Data:       deleted,
+	})
+}
+
+func (handler *ChatHandler) GetMessageEdits(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+	messageId := c.Params("messageId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Get message edit history")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	edits, err := handler.MessageUsecase.GetMessageEdits(c.Context(), userID, chatId, messageId)
+	if err != nil {
+		return handler.chatError(c, "Failed to get message edit history", err)
+	}
 
 	handler.Log.Http.Stream.Info().
 		Int("statusCode", fiber.StatusOK).
+		Str("messageId", messageId).
+		Int("editCount", len(edits)).
+		Msg("Response: Successfully retrieved message edit history")
+
+	return c.JSON(res.CommonResponse[[]res.MessageEditResponse]{
+		Message:    "Successfully to Get Message Edits",
+		StatusCode: fiber.StatusOK,
+		Data:       edits,
+	})
+}
+
+func (handler *ChatHandler) GetMessageReceipts(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+	messageId := c.Params("messageId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
 		Str("chatId", chatId).
-		Str("userId", userID).
-		Msg("Response: Messages marked as read")
+		Str("messageId", messageId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Get message receipts")
 
-	return c.JSON(fiber.Map{
-		"chatId": chatId,
-		"status": "messages marked as read",
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	receipts, err := handler.MessageUsecase.GetMessageReceipts(c.Context(), userID, chatId, messageId)
+	if err != nil {
+		return handler.chatError(c, "Failed to get message receipts", err)
+	}
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("messageId", messageId).
+		Str("status", receipts.Status).
+		Msg("Response: Successfully retrieved message receipts")
+
+	return c.JSON(res.CommonResponse[res.MessageReceiptsResponse]{
+		Message:    "Successfully to Get Message Receipts",
+		StatusCode: fiber.StatusOK,
+		Data:       receipts,
 	})
 }
+
+func (handler *ChatHandler) GetSeenBy(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+	messageId := c.Params("messageId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Get message seen by")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	seenBy, err := handler.MessageUsecase.GetSeenBy(c.Context(), userID, chatId, messageId)
+	if err != nil {
+		return handler.chatError(c, "Failed to get message seen by", err)
+	}
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("messageId", messageId).
+		Int("seenCount", len(seenBy.SeenBy)).
+		Msg("Response: Successfully retrieved message seen by")
+
+	return c.JSON(res.CommonResponse[res.SeenByResponse]{
+		Message:    "Successfully to Get Message Seen By",
+		StatusCode: fiber.StatusOK,
+		Data:       seenBy,
+	})
+}
+
+func (handler *ChatHandler) AddReaction(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+	messageId := c.Params("messageId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Add reaction")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	var request req.ReactionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return handler.badRequest(c, "invalid request body", err)
+	}
+
+	updated, err := handler.MessageUsecase.AddReaction(c.Context(), userID, chatId, messageId, request.Emoji)
+	if err != nil {
+		return handler.chatError(c, "Failed to add reaction", err)
+	}
+
+	return handler.reactionUpdated(c, updated, "Successfully to Add Reaction")
+}
+
+func (handler *ChatHandler) RemoveReaction(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+	messageId := c.Params("messageId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Remove reaction")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	emoji, err := url.PathUnescape(c.Params("emoji"))
+	if err != nil {
+		return handler.badRequest(c, "invalid emoji", err)
+	}
+
+	updated, err := handler.MessageUsecase.RemoveReaction(c.Context(), userID, chatId, messageId, emoji)
+	if err != nil {
+		return handler.chatError(c, "Failed to remove reaction", err)
+	}
+
+	return handler.reactionUpdated(c, updated, "Successfully to Remove Reaction")
+}
+
+func (handler *ChatHandler) reactionUpdated(c *fiber.Ctx, updated ws.ReactionUpdatedPayload, message string) error {
+	if updated.Seq != 0 {
+		handler.Notifier.NotifyRoom(updated.ChatID, ws.Event{Type: ws.TypeReactionUpdated, Payload: updated})
+	}
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", updated.ChatID).
+		Str("messageId", updated.MessageID).
+		Str("emoji", updated.Emoji).
+		Int64("count", updated.Count).
+		Msg("Response: Reaction updated")
+
+	return c.JSON(res.CommonResponse[ws.ReactionUpdatedPayload]{
+		Message:    message,
+		StatusCode: fiber.StatusOK,
+		Data:       updated,
+	})
+}
+
+// chatErrorStatus maps usecase errors to HTTP status codes.
+func chatErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidCursor),
+		errors.Is(err, usecase.ErrInvalidRequest),
+		errors.Is(err, usecase.ErrNotGroupChat):
+		return fiber.StatusBadRequest
+	case errors.Is(err, usecase.ErrNotParticipant),
+		errors.Is(err, usecase.ErrForbidden),
+		errors.Is(err, usecase.ErrEditWindowExpired):
+		return fiber.StatusForbidden
+	case errors.Is(err, usecase.ErrChatNotFound),
+		errors.Is(err, usecase.ErrMessageNotFound),
+		errors.Is(err, usecase.ErrAttachmentNotFound),
+		errors.Is(err, usecase.ErrUserNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, usecase.ErrAttachmentTooLarge):
+		return fiber.StatusRequestEntityTooLarge
+	case errors.Is(err, usecase.ErrUnsupportedMediaType):
+		return fiber.StatusUnsupportedMediaType
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,433 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"real-time-chat-app/dto/req"
+	"real-time-chat-app/dto/res"
+	"real-time-chat-app/dto/ws"
+	"real-time-chat-app/enum"
+)
+
+func (handler *ChatHandler) CreateGroup(c *fiber.Ctx) error {
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Create group chat")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	var request req.CreateGroupRequest
+	if err := c.BodyParser(&request); err != nil {
+		return handler.badRequest(c, "invalid request body", err)
+	}
+
+	group, err := handler.ChatUsecase.CreateGroup(c.Context(), userID, request)
+	if err != nil {
+		return handler.chatError(c, "Failed to create group chat", err)
+	}
+
+	for _, participant := range group.Participants {
+		if participant.UserID == userID {
+			continue
+		}
+		handler.Notifier.NotifyUsers(group.ChatID, []string{participant.UserID}, ws.Event{
+			Type: ws.TypeNewChat,
+			Payload: ws.NewChatPayload{
+				ChatID:       group.ChatID,
+				ChatUsername: group.GroupName,
+				ChatType:     string(enum.GROUP),
+			},
+		})
+	}
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusCreated).
+		Str("chatId", group.ChatID).
+		Int("participantCount", len(group.Participants)).
+		Msg("Response: Group chat created")
+
+	return c.Status(fiber.StatusCreated).JSON(res.CommonResponse[res.GroupChatResponse]{
+		Message:    "Successfully to Create Group Chat",
+		StatusCode: fiber.StatusCreated,
+		Data:       group,
+	})
+}
+
+func (handler *ChatHandler) UpdateGroup(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Update group chat")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	var request req.UpdateGroupRequest
+	if err := c.BodyParser(&request); err != nil {
+		return handler.badRequest(c, "invalid request body", err)
+	}
+
+	renamed, err := handler.ChatUsecase.RenameGroup(c.Context(), userID, chatId, request)
+	if err != nil {
+		return handler.chatError(c, "Failed to update group chat", err)
+	}
+
+	handler.Notifier.NotifyRoom(chatId, ws.Event{Type: ws.TypeGroupRenamed, Payload: renamed})
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Msg("Response: Group chat updated")
+
+	return c.JSON(res.CommonResponse[ws.GroupRenamedPayload]{
+		Message:    "Successfully to Update Group Chat",
+		StatusCode: fiber.StatusOK,
+		Data:       renamed,
+	})
+}
+
+func (handler *ChatHandler) AddParticipants(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Add participants")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	var request req.ParticipantsRequest
+	if err := c.BodyParser(&request); err != nil {
+		return handler.badRequest(c, "invalid request body", err)
+	}
+
+	added, err := handler.ChatUsecase.AddParticipants(c.Context(), userID, chatId, request)
+	if err != nil {
+		return handler.chatError(c, "Failed to add participants", err)
+	}
+
+	event := ws.Event{Type: ws.TypeMemberAdded, Payload: added}
+	handler.Notifier.NotifyRoom(chatId, event)
+	handler.Notifier.NotifyUsers(chatId, added.UserIDs, event)
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Int("addedCount", len(added.UserIDs)).
+		Msg("Response: Participants added")
+
+	return c.JSON(res.CommonResponse[ws.MemberAddedPayload]{
+		Message:    "Successfully to Add Participants",
+		StatusCode: fiber.StatusOK,
+		Data:       added,
+	})
+}
+
+func (handler *ChatHandler) RemoveParticipants(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Remove participants")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	var request req.ParticipantsRequest
+	if err := c.BodyParser(&request); err != nil {
+		return handler.badRequest(c, "invalid request body", err)
+	}
+
+	removed, err := handler.ChatUsecase.RemoveParticipants(c.Context(), userID, chatId, request)
+	if err != nil {
+		return handler.chatError(c, "Failed to remove participants", err)
+	}
+
+	handler.notifyRemoved(removed)
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Int("removedCount", len(removed.UserIDs)).
+		Msg("Response: Participants removed")
+
+	return c.JSON(res.CommonResponse[ws.MemberRemovedPayload]{
+		Message:    "Successfully to Remove Participants",
+		StatusCode: fiber.StatusOK,
+		Data:       removed,
+	})
+}
+
+func (handler *ChatHandler) LeaveGroup(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Leave group chat")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	removed, err := handler.ChatUsecase.LeaveGroup(c.Context(), userID, chatId)
+	if err != nil {
+		return handler.chatError(c, "Failed to leave group chat", err)
+	}
+
+	handler.notifyRemoved(removed)
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Str("userId", userID).
+		Msg("Response: Left group chat")
+
+	return c.JSON(res.CommonResponse[ws.MemberRemovedPayload]{
+		Message:    "Successfully to Leave Group Chat",
+		StatusCode: fiber.StatusOK,
+		Data:       removed,
+	})
+}
+
+func (handler *ChatHandler) GetParticipants(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Get participants")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	participants, err := handler.ChatUsecase.ListParticipants(c.Context(), userID, chatId)
+	if err != nil {
+		return handler.chatError(c, "Failed to get participants", err)
+	}
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Int("participantCount", len(participants)).
+		Msg("Response: Successfully retrieved participants")
+
+	return c.JSON(res.CommonResponse[[]res.ParticipantResponse]{
+		Message:    "Successfully to Get Participants",
+		StatusCode: fiber.StatusOK,
+		Data:       participants,
+	})
+}
+
+func (handler *ChatHandler) UpdateParticipantRole(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+	targetId := c.Params("userId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("targetId", targetId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Update participant role")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	var request req.UpdateRoleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return handler.badRequest(c, "invalid request body", err)
+	}
+
+	changed, err := handler.ChatUsecase.UpdateParticipantRole(c.Context(), userID, chatId, targetId, request)
+	if err != nil {
+		return handler.chatError(c, "Failed to update participant role", err)
+	}
+
+	handler.Notifier.NotifyRoom(chatId, ws.Event{Type: ws.TypeRoleChanged, Payload: changed})
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Str("targetId", targetId).
+		Msg("Response: Participant role updated")
+
+	return c.JSON(res.CommonResponse[ws.RoleChangedPayload]{
+		Message:    "Successfully to Update Participant Role",
+		StatusCode: fiber.StatusOK,
+		Data:       changed,
+	})
+}
+
+func (handler *ChatHandler) TransferOwnership(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Transfer group ownership")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	var request req.TransferOwnershipRequest
+	if err := c.BodyParser(&request); err != nil {
+		return handler.badRequest(c, "invalid request body", err)
+	}
+
+	changed, err := handler.ChatUsecase.TransferOwnership(c.Context(), userID, chatId, request)
+	if err != nil {
+		return handler.chatError(c, "Failed to transfer ownership", err)
+	}
+
+	handler.Notifier.NotifyRoom(chatId, ws.Event{Type: ws.TypeRoleChanged, Payload: changed})
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Str("newOwnerId", request.UserID).
+		Msg("Response: Ownership transferred")
+
+	return c.JSON(res.CommonResponse[ws.RoleChangedPayload]{
+		Message:    "Successfully to Transfer Ownership",
+		StatusCode: fiber.StatusOK,
+		Data:       changed,
+	})
+}
+
+func (handler *ChatHandler) DeleteGroup(c *fiber.Ctx) error {
+	chatId := c.Params("chatId")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("chatId", chatId).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Delete group chat")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	deleted, participantIDs, err := handler.ChatUsecase.DeleteGroup(c.Context(), userID, chatId)
+	if err != nil {
+		return handler.chatError(c, "Failed to delete group chat", err)
+	}
+
+	event := ws.Event{Type: ws.TypeGroupDeleted, Payload: deleted}
+	handler.Notifier.NotifyRoom(chatId, event)
+	handler.Notifier.NotifyUsers(chatId, participantIDs, event)
+	handler.Notifier.EvictFromRoom(chatId, participantIDs)
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("chatId", chatId).
+		Msg("Response: Group chat deleted")
+
+	return c.JSON(res.CommonResponse[ws.GroupDeletedPayload]{
+		Message:    "Successfully to Delete Group Chat",
+		StatusCode: fiber.StatusOK,
+		Data:       deleted,
+	})
+}
+
+// notifyRemoved tells the room and the removed users, then drops the removed
+// users' sessions from the room so they stop receiving its traffic.
+func (handler *ChatHandler) notifyRemoved(removed ws.MemberRemovedPayload) {
+	event := ws.Event{Type: ws.TypeMemberRemoved, Payload: removed}
+	handler.Notifier.NotifyRoom(removed.ChatID, event)
+	handler.Notifier.NotifyUsers(removed.ChatID, removed.UserIDs, event)
+	handler.Notifier.EvictFromRoom(removed.ChatID, removed.UserIDs)
+}
+
+// currentUserID resolves the caller from the bearer token, writing a 401 response on failure.
+func (handler *ChatHandler) currentUserID(c *fiber.Ctx) (string, bool) {
+	token := c.Get("Authorization")[7:]
+
+	userID, err := handler.JWT.GetUserIdFromToken(token)
+	if err != nil {
+		handler.Log.Http.Error.Error().
+			Err(err).
+			Str("path", c.Path()).
+			Msg("Invalid token - failed to extract user ID")
+
+		handler.Log.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", fiber.StatusUnauthorized).
+			Msg("Response: Unauthorized - invalid token")
+
+		_ = c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token",
+		})
+		return "", false
+	}
+	return userID, true
+}
+
+func (handler *ChatHandler) badRequest(c *fiber.Ctx, message string, err error) error {
+	handler.Log.Http.Warning.Warn().
+		Err(err).
+		Str("path", c.Path()).
+		Msg("Bad request")
+
+	handler.Log.Http.Stream.Error().
+		Err(err).
+		Int("statusCode", fiber.StatusBadRequest).
+		Msg("Response: Bad request - " + message)
+
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": message,
+	})
+}
+
+func (handler *ChatHandler) chatError(c *fiber.Ctx, message string, err error) error {
+	statusCode := chatErrorStatus(err)
+
+	handler.Log.Http.Error.Error().
+		Err(err).
+		Str("path", c.Path()).
+		Msg(message)
+
+	handler.Log.Http.Stream.Error().
+		Err(err).
+		Int("statusCode", statusCode).
+		Msg("Response: " + message)
+
+	return c.Status(statusCode).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
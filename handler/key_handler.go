@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"real-time-chat-app/config/common"
+	"real-time-chat-app/config/logger"
+	"real-time-chat-app/security"
+	"time"
+)
+
+const jwksMaxAge = 5 * time.Minute
+
+type KeyHandler struct {
+	*security.JWT
+	*logger.AppLogger
+	cacheControl string
+}
+
+func NewKeyHandler(JWT *security.JWT, keyConfig common.JwtKeyConfig, logger *logger.AppLogger) *KeyHandler {
+	logger.Http.Info.Info().Msg("Key handler initialized")
+	return &KeyHandler{JWT: JWT, AppLogger: logger, cacheControl: jwksCacheControl(keyConfig.ActivationDelay)}
+}
+
+// jwksCacheControl keeps the cache at half the activation delay, so verifiers
+// refetch the key set before a new key signs anything. Without a delay a key
+// signs as soon as it is published and the set is not cached at all.
+func jwksCacheControl(activationDelay time.Duration) string {
+	maxAge := min(activationDelay/2, jwksMaxAge)
+	if maxAge < time.Second {
+		return "no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+}
+
+// GetJWKS serves the public keys other services use to verify our access tokens.
+func (handler *KeyHandler) GetJWKS(ctx *fiber.Ctx) error {
+	handler.AppLogger.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("ip", ctx.IP()).
+		Msg("Incoming JWKS request")
+
+	keySet := handler.JWT.JWKS()
+
+	handler.AppLogger.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Int("keyCount", len(keySet.Keys)).
+		Msg("Response: JWKS served")
+
+	ctx.Set(fiber.HeaderCacheControl, handler.cacheControl)
+	return ctx.Status(fiber.StatusOK).JSON(keySet)
+}
@@ -0,0 +1,90 @@
+package handler
+
+import "real-time-chat-app/dto/ws"
+
+// Notifier pushes chat events raised over REST to connected WebSocket clients.
+type Notifier interface {
+	NotifyRoom(chatID string, event ws.Event)
+	NotifyUsers(chatID string, userIDs []string, event ws.Event)
+	EvictFromRoom(chatID string, userIDs []string)
+}
+
+func (handler *WebSocketHandler) NotifyRoom(chatID string, event ws.Event) {
+	handler.broadcastToRoom(chatID, event)
+}
+
+// NotifyUsers reaches the given users' sessions that have not joined chatID,
+// pair it with NotifyRoom to cover everyone. An empty chatID reaches every session.
+func (handler *WebSocketHandler) NotifyUsers(chatID string, userIDs []string, event ws.Event) {
+	for _, userID := range userIDs {
+		handler.publish(delivery{Target: targetUser, UserID: userID, SkipInRoom: chatID}, event)
+	}
+}
+
+func (handler *WebSocketHandler) EvictFromRoom(chatID string, userIDs []string) {
+	for _, userID := range userIDs {
+		handler.publish(delivery{Target: targetEvict, ChatID: chatID, UserID: userID}, ws.Event{})
+	}
+}
+
+// CloseAuthSession closes, on every node, the connections opened with tokens of
+// a revoked login session.
+func (handler *WebSocketHandler) CloseAuthSession(userID, sessionID string) {
+	handler.publish(delivery{Target: targetClose, UserID: userID, SessionID: sessionID}, ws.Event{})
+}
+
+func (handler *WebSocketHandler) closeAuthSession(userID, sessionID string) {
+	if sessionID == "" {
+		return
+	}
+
+	var closing []*WebSocketSession
+	handler.Mutex.RLock()
+	for _, session := range handler.Clients[userID] {
+		if session.AuthSessionID == sessionID {
+			closing = append(closing, session)
+		}
+	}
+	handler.Mutex.RUnlock()
+
+	for _, session := range closing {
+		handler.Log.WS.Info.Info().
+			Str("userId", userID).
+			Str("sessionId", session.ID).
+			Str("authSessionId", sessionID).
+			Msg("Closing WebSocket connection of revoked session")
+
+		handler.removeClient(session)
+		session.stop()
+		handler.closeWithCode(session.rawConn, CloseSessionEnded, "session revoked")
+	}
+}
+
+// evictFromRoom drops every local session of userID from the room.
+func (handler *WebSocketHandler) evictFromRoom(chatID, userID string) {
+	handler.Mutex.Lock()
+	defer handler.Mutex.Unlock()
+
+	room, ok := handler.Rooms[chatID]
+	if !ok {
+		return
+	}
+
+	for sessionID, session := range room {
+		if session.UserID != userID {
+			continue
+		}
+		delete(room, sessionID)
+		delete(session.Rooms, chatID)
+
+		handler.Log.WS.Info.Info().
+			Str("userId", userID).
+			Str("sessionId", sessionID).
+			Str("chatId", chatID).
+			Msg("Session evicted from chat room")
+	}
+
+	if len(room) == 0 {
+		delete(handler.Rooms, chatID)
+	}
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"real-time-chat-app/dto/req"
+	"real-time-chat-app/dto/res"
+)
+
+func (handler *ChatHandler) SearchMessages(c *fiber.Ctx) error {
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Search messages")
+
+	userID, ok := handler.currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	var request req.MessageSearchRequest
+	if err := c.QueryParser(&request); err != nil {
+		return handler.badRequest(c, "invalid search query", err)
+	}
+
+	results, err := handler.ChatUsecase.SearchMessages(c.Context(), userID, request)
+	if err != nil {
+		return handler.chatError(c, "Failed to search messages", err)
+	}
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Int("resultCount", len(results.Results)).
+		Msg("Response: Successfully searched messages")
+
+	return c.JSON(res.CommonResponse[res.MessageSearchResponse]{
+		Message:    "Successfully to Search Messages",
+		StatusCode: fiber.StatusOK,
+		Data:       results,
+	})
+}
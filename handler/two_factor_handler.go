@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"real-time-chat-app/dto/req"
+	"real-time-chat-app/dto/res"
+)
+
+// VerifyTwoFactorLogin is the second login step for accounts with 2FA enabled.
+func (handler *AuthHandler) VerifyTwoFactorLogin(ctx *fiber.Ctx) error {
+	handler.AppLogger.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("ip", ctx.IP()).
+		Str("userAgent", ctx.Get("User-Agent")).
+		Msg("Incoming two-factor login request")
+
+	payload := new(req.TwoFactorLoginRequest)
+	if err := ctx.BodyParser(payload); err != nil {
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", fiber.StatusBadRequest).
+			Msg("Response: Bad request - invalid body")
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	payload.UserAgent = ctx.Get("User-Agent")
+	payload.IP = ctx.IP()
+
+	loginResponse, err := handler.AuthUsecase.VerifyTwoFactorLogin(ctx.Context(), payload)
+	if err != nil {
+		setRetryAfter(ctx, err)
+		statusCode := authErrorStatus(err)
+
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", statusCode).
+			Msg("Response: Two-factor login failed")
+
+		return ctx.Status(statusCode).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	handler.AppLogger.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Bool("tokenGenerated", loginResponse.Token != "").
+		Msg("Response: Two-factor login successful")
+
+	return ctx.Status(fiber.StatusOK).JSON(res.CommonResponse[res.LoginResponse]{
+		Message:    "Successfully to login",
+		StatusCode: fiber.StatusOK,
+		Data:       loginResponse,
+	})
+}
+
+func (handler *AuthHandler) SetupTwoFactor(ctx *fiber.Ctx) error {
+	handler.AppLogger.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("ip", ctx.IP()).
+		Msg("Incoming request: Set up two-factor authentication")
+
+	userID, _, ok := accessTokenOf(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token",
+		})
+	}
+
+	setup, err := handler.AuthUsecase.SetupTwoFactor(ctx.Context(), userID)
+	if err != nil {
+		statusCode := authErrorStatus(err)
+
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", statusCode).
+			Str("userId", userID).
+			Msg("Response: Failed to set up two-factor authentication")
+
+		return ctx.Status(statusCode).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	handler.AppLogger.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("userId", userID).
+		Msg("Response: Two-factor setup started")
+
+	return ctx.Status(fiber.StatusOK).JSON(res.CommonResponse[res.TwoFactorSetupResponse]{
+		Message:    "Successfully to set up two-factor authentication",
+		StatusCode: fiber.StatusOK,
+		Data:       setup,
+	})
+}
+
+func (handler *AuthHandler) ConfirmTwoFactor(ctx *fiber.Ctx) error {
+	handler.AppLogger.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("ip", ctx.IP()).
+		Msg("Incoming request: Confirm two-factor authentication")
+
+	userID, _, ok := accessTokenOf(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token",
+		})
+	}
+
+	payload := new(req.TwoFactorConfirmRequest)
+	if err := ctx.BodyParser(payload); err != nil {
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", fiber.StatusBadRequest).
+			Msg("Response: Bad request - invalid body")
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	codes, err := handler.AuthUsecase.ConfirmTwoFactor(ctx.Context(), userID, payload)
+	if err != nil {
+		statusCode := authErrorStatus(err)
+
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", statusCode).
+			Str("userId", userID).
+			Msg("Response: Failed to confirm two-factor authentication")
+
+		return ctx.Status(statusCode).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	handler.AppLogger.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("userId", userID).
+		Msg("Response: Two-factor authentication enabled")
+
+	return ctx.Status(fiber.StatusOK).JSON(res.CommonResponse[res.RecoveryCodesResponse]{
+		Message:    "Successfully to enable two-factor authentication",
+		StatusCode: fiber.StatusOK,
+		Data:       codes,
+	})
+}
+
+func (handler *AuthHandler) DisableTwoFactor(ctx *fiber.Ctx) error {
+	handler.AppLogger.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("ip", ctx.IP()).
+		Msg("Incoming request: Disable two-factor authentication")
+
+	userID, payload, err := handler.passwordConfirmation(ctx)
+	if err != nil {
+		return err
+	}
+	if payload == nil {
+		return nil
+	}
+
+	if err := handler.AuthUsecase.DisableTwoFactor(ctx.Context(), userID, payload); err != nil {
+		setRetryAfter(ctx, err)
+		statusCode := authErrorStatus(err)
+
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", statusCode).
+			Str("userId", userID).
+			Msg("Response: Failed to disable two-factor authentication")
+
+		return ctx.Status(statusCode).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	handler.AppLogger.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("userId", userID).
+		Msg("Response: Two-factor authentication disabled")
+
+	return ctx.Status(fiber.StatusOK).JSON(res.CommonResponse[any]{
+		Message:    "Successfully to disable two-factor authentication",
+		StatusCode: fiber.StatusOK,
+	})
+}
+
+func (handler *AuthHandler) RegenerateRecoveryCodes(ctx *fiber.Ctx) error {
+	handler.AppLogger.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("ip", ctx.IP()).
+		Msg("Incoming request: Regenerate recovery codes")
+
+	userID, payload, err := handler.passwordConfirmation(ctx)
+	if err != nil {
+		return err
+	}
+	if payload == nil {
+		return nil
+	}
+
+	codes, err := handler.AuthUsecase.RegenerateRecoveryCodes(ctx.Context(), userID, payload)
+	if err != nil {
+		setRetryAfter(ctx, err)
+		statusCode := authErrorStatus(err)
+
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", statusCode).
+			Str("userId", userID).
+			Msg("Response: Failed to regenerate recovery codes")
+
+		return ctx.Status(statusCode).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	handler.AppLogger.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("userId", userID).
+		Msg("Response: Recovery codes regenerated")
+
+	return ctx.Status(fiber.StatusOK).JSON(res.CommonResponse[res.RecoveryCodesResponse]{
+		Message:    "Successfully to regenerate recovery codes",
+		StatusCode: fiber.StatusOK,
+		Data:       codes,
+	})
+}
+
+// passwordConfirmation reads the caller and the re-entered password. A nil
+// payload means the error response was already written.
+func (handler *AuthHandler) passwordConfirmation(ctx *fiber.Ctx) (string, *req.PasswordConfirmRequest, error) {
+	userID, _, ok := accessTokenOf(ctx)
+	if !ok {
+		return "", nil, ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token",
+		})
+	}
+
+	payload := new(req.PasswordConfirmRequest)
+	if err := ctx.BodyParser(payload); err != nil {
+		handler.AppLogger.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", fiber.StatusBadRequest).
+			Msg("Response: Bad request - invalid body")
+
+		return "", nil, ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	payload.UserAgent = ctx.Get("User-Agent")
+	payload.IP = ctx.IP()
+	return userID, payload, nil
+}
@@ -4,16 +4,18 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"real-time-chat-app/config/logger"
 	"real-time-chat-app/dto/res"
+	"real-time-chat-app/dto/ws"
 	"real-time-chat-app/usecase"
 )
 
 type UserHandler struct {
 	usecase.UserUsecase
-	Log *logger.AppLogger
+	Presence *usecase.PresenceTracker
+	Log      *logger.AppLogger
 }
 
-func NewUserHandler(userUsecase usecase.UserUsecase, logger *logger.AppLogger) *UserHandler {
-	return &UserHandler{UserUsecase: userUsecase, Log: logger}
+func NewUserHandler(userUsecase usecase.UserUsecase, presence *usecase.PresenceTracker, logger *logger.AppLogger) *UserHandler {
+	return &UserHandler{UserUsecase: userUsecase, Presence: presence, Log: logger}
 }
 
 func (handler *UserHandler) GetUserByToken(ctx *fiber.Ctx) error {
@@ -115,3 +117,52 @@ func (handler *UserHandler) GetAllUsers(ctx *fiber.Ctx) error {
 func (handler *UserHandler) EditUser(ctx *fiber.Ctx) error {
 	panic("")
 }
+
+func (handler *UserHandler) GetPresence(ctx *fiber.Ctx) error {
+	userID := ctx.Params("id")
+
+	handler.Log.Http.Stream.Info().
+		Str("method", ctx.Method()).
+		Str("path", ctx.Path()).
+		Str("userId", userID).
+		Str("ip", ctx.IP()).
+		Msg("Incoming request: Get user presence")
+
+	viewerID, _, ok := accessTokenOf(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token",
+		})
+	}
+
+	presence, err := handler.Presence.GetPresence(ctx.Context(), viewerID, userID)
+	if err != nil {
+		statusCode := chatErrorStatus(err)
+
+		handler.Log.Http.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to get user presence")
+
+		handler.Log.Http.Stream.Error().
+			Err(err).
+			Int("statusCode", statusCode).
+			Msg("Response: Failed to get user presence")
+
+		return ctx.Status(statusCode).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Str("userId", userID).
+		Str("status", presence.Status).
+		Msg("Response: Successfully retrieved user presence")
+
+	return ctx.Status(fiber.StatusOK).JSON(res.CommonResponse[ws.PresencePayload]{
+		Message:    "Successfully To Get User Presence",
+		StatusCode: fiber.StatusOK,
+		Data:       presence,
+	})
+}
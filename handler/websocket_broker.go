@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"real-time-chat-app/dto/ws"
+)
+
+const deliveryTopic = "ws.delivery"
+
+// Delivery targets, every node resolves them against its own sessions.
+const (
+	targetRoom  = "room"
+	targetUser  = "user"
+	targetEvict = "evict"
+	targetClose = "close_session"
+)
+
+type delivery struct {
+	Target        string          `json:"target"`
+	ChatID        string          `json:"chatId,omitempty"`
+	UserID        string          `json:"userId,omitempty"`
+	SessionID     string          `json:"sessionId,omitempty"`     // close deliveries end this login session's connections
+	ExcludeUserID string          `json:"excludeUserId,omitempty"` // room deliveries skip this user's sessions
+	SkipInRoom    string          `json:"skipInRoom,omitempty"`    // user deliveries skip sessions joined to this chat
+	Type          string          `json:"type"`
+	ID            string          `json:"id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+func (handler *WebSocketHandler) subscribeDeliveries() {
+	if err := handler.Broker.Subscribe(deliveryTopic, handler.handleDelivery); err != nil {
+		handler.Log.WS.Error.Error().
+			Err(err).
+			Str("topic", deliveryTopic).
+			Msg("Failed to subscribe to broker topic")
+	}
+}
+
+func (handler *WebSocketHandler) publish(d delivery, event ws.Event) {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("type", event.Type).
+			Err(err).
+			Msg("Failed to encode event payload")
+		return
+	}
+
+	d.Type = event.Type
+	d.ID = event.ID
+	d.Payload = payload
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("type", event.Type).
+			Err(err).
+			Msg("Failed to encode delivery")
+		return
+	}
+
+	if err := handler.Broker.Publish(context.Background(), deliveryTopic, data); err != nil {
+		// other nodes miss this event, but local sessions still get it
+		handler.Log.WS.Error.Error().
+			Str("target", d.Target).
+			Str("type", event.Type).
+			Err(err).
+			Msg("Failed to publish delivery, delivering locally only")
+		handler.handleDelivery(data)
+	}
+}
+
+func (handler *WebSocketHandler) handleDelivery(data []byte) {
+	var d delivery
+	if err := json.Unmarshal(data, &d); err != nil {
+		handler.Log.WS.Error.Error().
+			Err(err).
+			Msg("Failed to decode delivery")
+		return
+	}
+
+	if d.Target == targetEvict {
+		handler.evictFromRoom(d.ChatID, d.UserID)
+		return
+	}
+	if d.Target == targetClose {
+		handler.closeAuthSession(d.UserID, d.SessionID)
+		return
+	}
+
+	msg, err := newOutboundMessage(ws.Event{Type: d.Type, ID: d.ID, Payload: d.Payload})
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("type", d.Type).
+			Err(err).
+			Msg("Failed to encode delivered event")
+		return
+	}
+
+	switch d.Target {
+	case targetRoom:
+		handler.deliverToRoom(d.ChatID, d.ExcludeUserID, msg)
+	case targetUser:
+		handler.deliverToUser(d.UserID, d.SkipInRoom, msg)
+	default:
+		handler.Log.WS.Warning.Warn().
+			Str("target", d.Target).
+			Msg("Unknown delivery target")
+	}
+}
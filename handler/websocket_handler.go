@@ -2,89 +2,149 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	fasthttpws "github.com/fasthttp/websocket"
 	"github.com/gofiber/contrib/websocket"
+	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
+	"real-time-chat-app/broker"
+	"real-time-chat-app/config/common"
 	"real-time-chat-app/config/logger"
 	"real-time-chat-app/dto/req"
+	"real-time-chat-app/dto/ws"
 	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
+	"real-time-chat-app/security"
 	"real-time-chat-app/usecase"
+	"strconv"
 	"sync"
+	"time"
 )
 
-type BroadcastMessage struct {
-	Type       string `json:"type"`
-	UserID     string `json:"userId"`
-	ReceiverID string `json:"receiverId"`
-	ChatID     string `json:"chatId"`
-	Content    string `json:"content"`
-}
+// Application close codes. Clients reconnect after a slow consumer or heartbeat
+// close, the auth codes need a new token and an ended session must not retry.
+const (
+	CloseAuthRequired     = 4001
+	CloseAuthInvalid      = 4002
+	CloseTokenExpired     = 4003
+	CloseSlowConsumer     = 4004
+	CloseHeartbeatTimeout = 4005
+	CloseSessionEnded     = 4006
+)
 
 type WebSocketHandler struct {
 	DB        *gorm.DB
 	Log       *logger.AppLogger
 	ChatUC    usecase.ChatUsecase
 	MessageUC usecase.MessageUsecase
-	Clients   map[string]*websocket.Conn            // userId -> conn
-	Rooms     map[string]map[string]*websocket.Conn // chatId -> map[userId]*conn
+	JWT       *security.JWT
+	Broker    broker.Broker
+	Presence  *usecase.PresenceTracker
+	Config    common.WebSocketConfig
+	Metrics   *WebSocketMetrics
+	Clients   map[string]map[string]*WebSocketSession // userId -> map[sessionId]*session
+	Rooms     map[string]map[string]*WebSocketSession // chatId -> map[sessionId]*session
+	Watchers  map[string]map[string]*WebSocketSession // watched userId -> map[sessionId]*session
 	Mutex     sync.RWMutex
 }
 
-func NewWebSocketHandler(db *gorm.DB, logger *logger.AppLogger, chatUC usecase.ChatUsecase, messageUC usecase.MessageUsecase) *WebSocketHandler {
+func NewWebSocketHandler(db *gorm.DB, logger *logger.AppLogger, chatUC usecase.ChatUsecase, messageUC usecase.MessageUsecase, JWT *security.JWT, broker broker.Broker, presence *usecase.PresenceTracker, config common.WebSocketConfig) *WebSocketHandler {
 	logger.WS.Info.Info().Msg("WebSocket handler initialized")
-	return &WebSocketHandler{
+	handler := &WebSocketHandler{
 		DB:        db,
 		Log:       logger,
 		ChatUC:    chatUC,
 		MessageUC: messageUC,
-		Clients:   make(map[string]*websocket.Conn),
-		Rooms:     make(map[string]map[string]*websocket.Conn),
+		JWT:       JWT,
+		Broker:    broker,
+		Presence:  presence,
+		Config:    config,
+		Metrics:   &WebSocketMetrics{},
+		Clients:   make(map[string]map[string]*WebSocketSession),
+		Rooms:     make(map[string]map[string]*WebSocketSession),
+		Watchers:  make(map[string]map[string]*WebSocketSession),
 	}
+	handler.subscribeDeliveries()
+	go handler.reapDeadSessions()
+
+	return handler
 }
 
 func (handler *WebSocketHandler) HandleWebSocket(c *websocket.Conn) {
 	ctx := context.Background()
-	userID := c.Query("userId")
+	version := protocolVersion(c.Subprotocol())
 
 	handler.Log.WS.Stream.Info().
-		Str("userId", userID).
 		Str("remoteAddr", c.RemoteAddr().String()).
+		Int("protocolVersion", version).
 		Msg("WebSocket connection attempt")
 
-	if userID == "" {
-		handler.Log.WS.Error.Error().
-			Str("remoteAddr", c.RemoteAddr().String()).
-			Msg("WebSocket connection rejected: missing userId")
-
-		c.WriteJSON(map[string]string{
-			"type":  "error",
-			"error": "userId is required",
-		})
-		c.Close()
+	userID, authSessionID, expiresAt, ok := handler.authenticate(c, version)
+	if !ok {
 		return
 	}
 
-	handler.registerClient(userID, c)
+	session := NewWebSocketSession(userID, c, version, handler.Config.SendQueueSize)
+	session.AuthSessionID = authSessionID
+
+	// close the connection as soon as the token expires, unless the client re-authenticates
+	expiryTimer := time.AfterFunc(time.Until(expiresAt), func() {
+		handler.Log.WS.Warning.Warn().
+			Str("userId", userID).
+			Msg("WebSocket token expired, closing connection")
+		handler.closeWithCode(session.rawConn, CloseTokenExpired, "token expired")
+	})
+
+	// every pong or inbound frame pushes the read deadline further
+	_ = c.SetReadDeadline(time.Now().Add(handler.Config.IdleTimeout))
+	c.SetPongHandler(func(string) error {
+		session.touch()
+		return c.SetReadDeadline(time.Now().Add(handler.Config.IdleTimeout))
+	})
+
+	handler.registerClient(session)
+	handler.Presence.Connect(userID, session.ID)
+	go handler.writePump(session)
 	defer func() {
-		handler.removeClient(userID, c)
+		expiryTimer.Stop()
+		handler.removeClient(session)
+		session.stop()
 		c.Close()
+		// the conn is returned to the pool after this handler returns
+		<-session.writerDone
 	}()
 
-	response := map[string]string{
-		"type":   "connected",
-		"userId": userID,
-	}
-	_ = c.WriteJSON(response)
+	handler.send(session, ws.Event{
+		Type: ws.TypeConnected,
+		Payload: ws.ConnectedPayload{
+			UserID:    userID,
+			SessionID: session.ID,
+			Device:    session.Device,
+			Protocol:  session.Protocol,
+		},
+	})
 
 	handler.Log.WS.Stream.Info().
 		Str("userId", userID).
-		Str("type", "connected").
+		Str("sessionId", session.ID).
+		Str("protocol", session.Protocol).
+		Str("type", ws.TypeConnected).
 		Msg("WebSocket connection established")
 
+	// clients may resume from a global sequence without an explicit sync frame
+	if since := c.Query("since"); since != "" {
+		globalSeq, err := strconv.ParseInt(since, 10, 64)
+		if err != nil {
+			handler.sendError(session, "", "invalid since parameter")
+		} else {
+			handler.syncEvents(ctx, session, "", req.SyncRequest{GlobalSeq: globalSeq})
+		}
+	}
+
 	// Main message loop
 	for {
-		var msg BroadcastMessage
-		if err := c.ReadJSON(&msg); err != nil {
+		_, data, err := c.ReadMessage()
+		if err != nil {
 			handler.Log.WS.Warning.Warn().
 				Str("userId", userID).
 				Err(err).
@@ -92,71 +152,254 @@ func (handler *WebSocketHandler) HandleWebSocket(c *websocket.Conn) {
 			break
 		}
 
-		msgJSON, _ := json.Marshal(msg)
-		handler.Log.WS.Stream.Info().
+		session.touch()
+		_ = c.SetReadDeadline(time.Now().Add(handler.Config.IdleTimeout))
+
+		frame, err := decodeFrame(session.Version, data)
+		if err != nil {
+			handler.Log.WS.Warning.Warn().
+				Str("userId", userID).
+				Err(err).
+				Msg("Received malformed frame")
+			handler.sendError(session, "", "malformed frame: "+err.Error())
+			continue
+		}
+
+		logEvent := handler.Log.WS.Stream.Info().
 			Str("userId", userID).
-			Str("messageType", msg.Type).
-			Str("payload", string(msgJSON)).
-			Msg("Incoming WebSocket message")
+			Str("messageType", frame.Type).
+			Str("requestId", frame.ID)
+		// auth frames carry the token, keep it out of the logs
+		if frame.Type != ws.TypeAuth && len(frame.Payload) > 0 {
+			logEvent = logEvent.RawJSON("payload", frame.Payload)
+		}
+		logEvent.Msg("Incoming WebSocket message")
 
-		if msg.Type == "" {
+		if frame.Type == "" {
 			handler.Log.WS.Warning.Warn().
 				Str("userId", userID).
 				Msg("Received message with empty type")
-			handler.sendError(c, "message type is required")
+			handler.sendError(session, frame.ID, "message type is required")
 			continue
 		}
 
-		switch msg.Type {
-		case "join_room":
-			handler.handleJoinRoom(ctx, c, userID, msg)
-		case "leave_room":
-			handler.handleLeaveRoom(userID, msg.ChatID)
-		case "send_message":
-			handler.handleSendMessage(ctx, userID, msg)
-		case "typing":
-			handler.handleTyping(userID, msg.ChatID, true)
-		case "stop_typing":
-			handler.handleTyping(userID, msg.ChatID, false)
+		switch frame.Type {
+		case ws.TypePing:
+			handler.send(session, ws.Event{
+				Type:    ws.TypePong,
+				ID:      frame.ID,
+				Payload: ws.PongPayload{ServerTime: time.Now().UnixMilli()},
+			})
+		case ws.TypeAuth:
+			if !handler.handleReauth(session, frame, expiryTimer) {
+				return
+			}
+		case ws.TypeJoinRoom:
+			handler.handleJoinRoom(ctx, session, frame)
+		case ws.TypeLeaveRoom:
+			handler.handleLeaveRoom(session, frame)
+		case ws.TypeSendMessage:
+			handler.handleSendMessage(ctx, session, frame)
+		case ws.TypeTyping:
+			handler.handleTyping(session, frame, true)
+		case ws.TypeStopTyping:
+			handler.handleTyping(session, frame, false)
+		case ws.TypeSync:
+			handler.handleSync(ctx, session, frame)
+		case ws.TypeEditMessage:
+			handler.handleEditMessage(ctx, session, frame)
+		case ws.TypeDeleteMessage:
+			handler.handleDeleteMessage(ctx, session, frame)
+		case ws.TypeAddReaction:
+			handler.handleReaction(ctx, session, frame, true)
+		case ws.TypeRemoveReaction:
+			handler.handleReaction(ctx, session, frame, false)
+		case ws.TypeMessageAck:
+			handler.handleMessageAck(ctx, session, frame)
+		case ws.TypeMarkRead:
+			handler.handleMarkRead(ctx, session, frame)
+		case ws.TypeSetPresence:
+			handler.handleSetPresence(session, frame)
+		case ws.TypeSubscribePresence:
+			handler.handleSubscribePresence(ctx, session, frame)
+		case ws.TypeUnsubscribePresence:
+			handler.handleUnsubscribePresence(session, frame)
 		default:
 			handler.Log.WS.Warning.Warn().
 				Str("userId", userID).
-				Str("messageType", msg.Type).
+				Str("messageType", frame.Type).
 				Msg("Unknown message type received")
-			handler.sendError(c, "unknown message type: "+msg.Type)
+			handler.sendError(session, frame.ID, "unknown message type: "+frame.Type)
 		}
 	}
 }
 
-func (handler *WebSocketHandler) handleJoinRoom(ctx context.Context, c *websocket.Conn, userID string, msg BroadcastMessage) {
+func (handler *WebSocketHandler) authenticate(c *websocket.Conn, version int) (string, string, time.Time, bool) {
+	token, _ := c.Locals("ws_token").(string)
+
+	// no token on the upgrade request, expect it in the first frame
+	if token == "" {
+		_ = c.SetReadDeadline(time.Now().Add(handler.Config.AuthTimeout))
+
+		var payload ws.AuthPayload
+		_, data, err := c.ReadMessage()
+		if err == nil {
+			var frame ws.Envelope
+			if frame, err = decodeFrame(version, data); err == nil && frame.Type == ws.TypeAuth {
+				err = decodePayload(frame, &payload)
+			}
+		}
+
+		if err != nil || payload.Token == "" {
+			handler.Log.WS.Warning.Warn().
+				Err(err).
+				Str("remoteAddr", c.RemoteAddr().String()).
+				Msg("WebSocket connection rejected: missing token")
+			handler.closeWithCode(c.Conn, CloseAuthRequired, "authentication required")
+			return "", "", time.Time{}, false
+		}
+
+		_ = c.SetReadDeadline(time.Time{})
+		token = payload.Token
+	}
+
+	userID, authSessionID, expiresAt, err := handler.verifyToken(token)
+	if err != nil {
+		handler.Log.WS.Warning.Warn().
+			Err(err).
+			Str("remoteAddr", c.RemoteAddr().String()).
+			Msg("WebSocket connection rejected: invalid token")
+
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			handler.closeWithCode(c.Conn, CloseTokenExpired, "token expired")
+		} else {
+			handler.closeWithCode(c.Conn, CloseAuthInvalid, "invalid token")
+		}
+		return "", "", time.Time{}, false
+	}
+
 	handler.Log.WS.Info.Info().
 		Str("userId", userID).
+		Str("authSessionId", authSessionID).
+		Time("expiresAt", expiresAt).
+		Msg("WebSocket connection authenticated")
+
+	return userID, authSessionID, expiresAt, true
+}
+
+func (handler *WebSocketHandler) handleReauth(session *WebSocketSession, frame ws.Envelope, expiryTimer *time.Timer) bool {
+	userID := session.UserID
+
+	var payload ws.AuthPayload
+	if err := decodePayload(frame, &payload); err != nil {
+		handler.sendError(session, frame.ID, "invalid auth payload")
+		return true
+	}
+
+	tokenUserID, authSessionID, expiresAt, err := handler.verifyToken(payload.Token)
+	if err != nil || tokenUserID != userID {
+		handler.Log.WS.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Str("tokenUserId", tokenUserID).
+			Msg("WebSocket re-authentication failed")
+		handler.closeWithCode(session.rawConn, CloseAuthInvalid, "invalid token")
+		return false
+	}
+
+	expiryTimer.Reset(time.Until(expiresAt))
+
+	// the connection now belongs to the login session of the new token
+	handler.Mutex.Lock()
+	session.AuthSessionID = authSessionID
+	handler.Mutex.Unlock()
+
+	handler.send(session, ws.Event{
+		Type: ws.TypeAuthenticated,
+		ID:   frame.ID,
+		Payload: ws.AuthenticatedPayload{
+			UserID:    userID,
+			ExpiresAt: expiresAt.Format("2006-01-02 15:04:05"),
+		},
+	})
+
+	handler.Log.WS.Info.Info().
+		Str("userId", userID).
+		Time("expiresAt", expiresAt).
+		Msg("WebSocket connection re-authenticated")
+
+	return true
+}
+
+func (handler *WebSocketHandler) verifyToken(token string) (string, string, time.Time, error) {
+	claims, err := handler.JWT.VerifyJwtToken(token)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	if err := handler.JWT.CheckRevoked(context.Background(), claims); err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	userID, err := security.UserIdFromClaims(claims)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	expiresAt, err := security.ExpirationFromClaims(claims)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	return userID, security.SessionIdFromClaims(claims), expiresAt, nil
+}
+
+func (handler *WebSocketHandler) closeWithCode(conn *fasthttpws.Conn, code int, reason string) {
+	_ = conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(time.Second),
+	)
+	_ = conn.Close()
+}
+
+func (handler *WebSocketHandler) handleJoinRoom(ctx context.Context, session *WebSocketSession, frame ws.Envelope) {
+	userID := session.UserID
+
+	var msg ws.JoinRoomPayload
+	if err := decodePayload(frame, &msg); err != nil {
+		handler.sendError(session, frame.ID, "invalid join_room payload")
+		return
+	}
+
+	handler.Log.WS.Info.Info().
+		Str("userId", userID).
+		Str("sessionId", session.ID).
 		Str("chatId", msg.ChatID).
 		Str("receiverId", msg.ReceiverID).
 		Msg("Processing join_room request")
 
-	chatID, isNewChat, err := handler.joinRoom(ctx, userID, msg.ReceiverID, msg.ChatID)
+	chatID, isNewChat, err := handler.joinRoom(ctx, session, msg.ReceiverID, msg.ChatID)
 	if err != nil {
 		handler.Log.WS.Error.Error().
 			Str("userId", userID).
 			Str("chatId", msg.ChatID).
 			Err(err).
 			Msg("Failed to join room")
-		handler.sendError(c, "failed to join room: "+err.Error())
+		handler.sendError(session, frame.ID, "failed to join room: "+err.Error())
 		return
 	}
 
-	response := map[string]string{
-		"type":   "joined_room",
-		"chatId": chatID,
-	}
-	_ = c.WriteJSON(response)
+	handler.send(session, ws.Event{
+		Type:    ws.TypeJoinedRoom,
+		ID:      frame.ID,
+		Payload: ws.JoinedRoomPayload{ChatID: chatID},
+	})
 
 	handler.Log.WS.Stream.Info().
 		Str("userId", userID).
 		Str("chatId", chatID).
 		Bool("isNewChat", isNewChat).
-		Str("type", "joined_room").
+		Str("type", ws.TypeJoinedRoom).
 		Msg("Sent joined_room response")
 
 	if isNewChat && msg.ReceiverID != "" {
@@ -169,25 +412,31 @@ func (handler *WebSocketHandler) handleJoinRoom(ctx context.Context, c *websocke
 	}
 }
 
-func (handler *WebSocketHandler) handleLeaveRoom(userID, chatID string) {
-	if chatID == "" {
+func (handler *WebSocketHandler) handleLeaveRoom(session *WebSocketSession, frame ws.Envelope) {
+	var msg ws.LeaveRoomPayload
+	if err := decodePayload(frame, &msg); err != nil || msg.ChatID == "" {
 		handler.Log.WS.Warning.Warn().
-			Str("userId", userID).
+			Str("userId", session.UserID).
 			Msg("Leave room failed: chatId is empty")
+		handler.sendError(session, frame.ID, "chatId is required")
 		return
 	}
+	chatID := msg.ChatID
 
 	handler.Log.WS.Info.Info().
-		Str("userId", userID).
+		Str("userId", session.UserID).
+		Str("sessionId", session.ID).
 		Str("chatId", chatID).
 		Msg("Processing leave_room request")
 
-	handler.leaveRoom(userID, chatID)
+	handler.leaveRoom(session, chatID)
+	handler.ack(session, frame, ws.LeaveRoomPayload{ChatID: chatID})
 }
 
-func (handler *WebSocketHandler) joinRoom(ctx context.Context, userID, receiverID, chatID string) (string, bool, error) {
+func (handler *WebSocketHandler) joinRoom(ctx context.Context, session *WebSocketSession, receiverID, chatID string) (string, bool, error) {
 	var err error
 	isNewChat := false
+	userID := session.UserID
 
 	handler.Log.WS.Trace.Trace().
 		Str("userId", userID).
@@ -204,6 +453,23 @@ func (handler *WebSocketHandler) joinRoom(ctx context.Context, userID, receiverI
 		return "", false, err
 	}
 
+	isParticipant, err := handler.ChatUC.IsParticipant(ctx, chatID, userID)
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("userId", userID).
+			Str("chatId", chatID).
+			Err(err).
+			Msg("Failed to verify participant")
+		return "", false, err
+	}
+	if !isParticipant {
+		handler.Log.WS.Warning.Warn().
+			Str("userId", userID).
+			Str("chatId", chatID).
+			Msg("User is not a participant of the chat")
+		return "", false, usecase.ErrNotParticipant
+	}
+
 	if chatID != "" && receiverID != "" {
 		var messageCount int64
 		handler.DB.Model(&entity.Messages{}).Where("chat_id = ?", chatID).Count(&messageCount)
@@ -220,16 +486,18 @@ func (handler *WebSocketHandler) joinRoom(ctx context.Context, userID, receiverI
 	defer handler.Mutex.Unlock()
 
 	if handler.Rooms[chatID] == nil {
-		handler.Rooms[chatID] = make(map[string]*websocket.Conn)
+		handler.Rooms[chatID] = make(map[string]*WebSocketSession)
 		handler.Log.WS.Trace.Trace().
 			Str("chatId", chatID).
 			Msg("Created new room")
 	}
 
-	handler.Rooms[chatID][userID] = handler.Clients[userID]
+	handler.Rooms[chatID][session.ID] = session
+	session.Rooms[chatID] = struct{}{}
 
 	handler.Log.WS.Info.Info().
 		Str("userId", userID).
+		Str("sessionId", session.ID).
 		Str("chatId", chatID).
 		Int("roomSize", len(handler.Rooms[chatID])).
 		Msg("User joined chat room")
@@ -237,15 +505,18 @@ func (handler *WebSocketHandler) joinRoom(ctx context.Context, userID, receiverI
 	return chatID, isNewChat, nil
 }
 
-func (handler *WebSocketHandler) leaveRoom(userID, chatID string) {
+func (handler *WebSocketHandler) leaveRoom(session *WebSocketSession, chatID string) {
 	handler.Mutex.Lock()
 	defer handler.Mutex.Unlock()
 
+	delete(session.Rooms, chatID)
+
 	if room, ok := handler.Rooms[chatID]; ok {
-		delete(room, userID)
+		delete(room, session.ID)
 
 		handler.Log.WS.Info.Info().
-			Str("userId", userID).
+			Str("userId", session.UserID).
+			Str("sessionId", session.ID).
 			Str("chatId", chatID).
 			Int("remainingUsers", len(room)).
 			Msg("User left chat room")
@@ -258,13 +529,21 @@ func (handler *WebSocketHandler) leaveRoom(userID, chatID string) {
 		}
 	} else {
 		handler.Log.WS.Warning.Warn().
-			Str("userId", userID).
+			Str("userId", session.UserID).
 			Str("chatId", chatID).
 			Msg("Attempted to leave non-existent room")
 	}
 }
 
-func (handler *WebSocketHandler) handleSendMessage(ctx context.Context, senderID string, msg BroadcastMessage) {
+func (handler *WebSocketHandler) handleSendMessage(ctx context.Context, session *WebSocketSession, frame ws.Envelope) {
+	senderID := session.UserID
+
+	var msg ws.SendMessagePayload
+	if err := decodePayload(frame, &msg); err != nil {
+		handler.sendError(session, frame.ID, "invalid send_message payload")
+		return
+	}
+
 	handler.Log.WS.Info.Info().
 		Str("senderId", senderID).
 		Str("chatId", msg.ChatID).
@@ -275,24 +554,26 @@ func (handler *WebSocketHandler) handleSendMessage(ctx context.Context, senderID
 		handler.Log.WS.Error.Error().
 			Str("senderId", senderID).
 			Msg("Send message failed: chatId is empty")
-		handler.sendErrorToUser(senderID, "chatId is required")
+		handler.sendError(session, frame.ID, "chatId is required")
 		return
 	}
 
-	if msg.Content == "" {
+	if msg.Content == "" && len(msg.AttachmentIDs) == 0 {
 		handler.Log.WS.Error.Error().
 			Str("senderId", senderID).
 			Str("chatId", msg.ChatID).
 			Msg("Send message failed: content is empty")
-		handler.sendErrorToUser(senderID, "message content cannot be empty")
+		handler.sendError(session, frame.ID, "message content cannot be empty")
 		return
 	}
 
 	msgRequest := req.MessageRequest{
-		SenderID:   senderID,
-		ReceiverID: msg.ReceiverID,
-		ChatID:     msg.ChatID,
-		Content:    msg.Content,
+		SenderID:      senderID,
+		ReceiverID:    msg.ReceiverID,
+		ChatID:        msg.ChatID,
+		Content:       msg.Content,
+		ReplyToID:     msg.ReplyToID,
+		AttachmentIDs: msg.AttachmentIDs,
 	}
 
 	broadcastMsg, err := handler.MessageUC.ProcessIncomingMessage(ctx, msgRequest)
@@ -302,7 +583,7 @@ func (handler *WebSocketHandler) handleSendMessage(ctx context.Context, senderID
 			Str("chatId", msg.ChatID).
 			Err(err).
 			Msg("Failed to process incoming message")
-		handler.sendErrorToUser(senderID, "failed to send message")
+		handler.sendError(session, frame.ID, "failed to send message")
 		return
 	}
 
@@ -312,48 +593,244 @@ func (handler *WebSocketHandler) handleSendMessage(ctx context.Context, senderID
 		Str("senderId", broadcastMsg.SenderID).
 		Msg("Message created successfully")
 
-	broadcastPayload := map[string]interface{}{
-		"type":         "new_message",
-		"messageId":    broadcastMsg.MessageID,
-		"chatId":       broadcastMsg.ChatID,
-		"senderId":     broadcastMsg.SenderID,
-		"senderName":   broadcastMsg.SenderName,
-		"senderAvatar": broadcastMsg.SenderAvatar,
-		"content":      broadcastMsg.Content,
-		"status":       broadcastMsg.Status,
-		"createdAt":    broadcastMsg.CreatedAt,
-	}
+	handler.ack(session, frame, broadcastMsg)
 
-	handler.broadcastToRoom(broadcastMsg.ChatID, broadcastPayload)
+	handler.broadcastToRoom(broadcastMsg.ChatID, ws.Event{
+		Type:    ws.TypeNewMessage,
+		Payload: broadcastMsg,
+	})
 
 	handler.notifyOfflineParticipants(ctx, broadcastMsg.ChatID, senderID)
 }
 
-func (handler *WebSocketHandler) broadcastToRoom(chatID string, message interface{}) {
+func (handler *WebSocketHandler) handleEditMessage(ctx context.Context, session *WebSocketSession, frame ws.Envelope) {
+	var msg ws.EditMessagePayload
+	if err := decodePayload(frame, &msg); err != nil {
+		handler.sendError(session, frame.ID, "invalid edit_message payload")
+		return
+	}
+
+	handler.Log.WS.Info.Info().
+		Str("userId", session.UserID).
+		Str("chatId", msg.ChatID).
+		Str("messageId", msg.MessageID).
+		Msg("Processing edit_message request")
+
+	if msg.ChatID == "" || msg.MessageID == "" {
+		handler.sendError(session, frame.ID, "chatId and messageId are required")
+		return
+	}
+
+	edited, err := handler.MessageUC.EditMessage(ctx, session.UserID, msg.ChatID, msg.MessageID, msg.Content)
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("userId", session.UserID).
+			Str("messageId", msg.MessageID).
+			Err(err).
+			Msg("Failed to edit message")
+		handler.sendError(session, frame.ID, "failed to edit message: "+err.Error())
+		return
+	}
+
+	handler.ack(session, frame, edited)
+
+	handler.broadcastToRoom(edited.ChatID, ws.Event{
+		Type:    ws.TypeMessageEdited,
+		Payload: edited,
+	})
+}
+
+func (handler *WebSocketHandler) handleDeleteMessage(ctx context.Context, session *WebSocketSession, frame ws.Envelope) {
+	var msg ws.DeleteMessagePayload
+	if err := decodePayload(frame, &msg); err != nil {
+		handler.sendError(session, frame.ID, "invalid delete_message payload")
+		return
+	}
+
+	scope := enum.DeleteScope(msg.Scope)
+	if scope == "" {
+		scope = enum.DeleteScopeMe
+	}
+
+	handler.Log.WS.Info.Info().
+		Str("userId", session.UserID).
+		Str("chatId", msg.ChatID).
+		Str("messageId", msg.MessageID).
+		Str("scope", string(scope)).
+		Msg("Processing delete_message request")
+
+	if msg.ChatID == "" || msg.MessageID == "" {
+		handler.sendError(session, frame.ID, "chatId and messageId are required")
+		return
+	}
+
+	deleted, err := handler.MessageUC.DeleteMessage(ctx, session.UserID, msg.ChatID, msg.MessageID, scope)
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("userId", session.UserID).
+			Str("messageId", msg.MessageID).
+			Err(err).
+			Msg("Failed to delete message")
+		handler.sendError(session, frame.ID, "failed to delete message: "+err.Error())
+		return
+	}
+
+	handler.ack(session, frame, deleted)
+
+	event := ws.Event{Type: ws.TypeMessageDeleted, Payload: deleted}
+	if scope == enum.DeleteScopeEveryone {
+		handler.broadcastToRoom(deleted.ChatID, event)
+	} else {
+		handler.NotifyUsers("", []string{session.UserID}, event)
+	}
+}
+
+func (handler *WebSocketHandler) handleReaction(ctx context.Context, session *WebSocketSession, frame ws.Envelope, add bool) {
+	var msg ws.ReactionPayload
+	if err := decodePayload(frame, &msg); err != nil {
+		handler.sendError(session, frame.ID, "invalid "+frame.Type+" payload")
+		return
+	}
+
+	handler.Log.WS.Info.Info().
+		Str("userId", session.UserID).
+		Str("chatId", msg.ChatID).
+		Str("messageId", msg.MessageID).
+		Str("emoji", msg.Emoji).
+		Msg("Processing " + frame.Type + " request")
+
+	if msg.ChatID == "" || msg.MessageID == "" {
+		handler.sendError(session, frame.ID, "chatId and messageId are required")
+		return
+	}
+
+	var updated ws.ReactionUpdatedPayload
+	var err error
+	if add {
+		updated, err = handler.MessageUC.AddReaction(ctx, session.UserID, msg.ChatID, msg.MessageID, msg.Emoji)
+	} else {
+		updated, err = handler.MessageUC.RemoveReaction(ctx, session.UserID, msg.ChatID, msg.MessageID, msg.Emoji)
+	}
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("userId", session.UserID).
+			Str("messageId", msg.MessageID).
+			Err(err).
+			Msg("Failed to update reaction")
+		handler.sendError(session, frame.ID, "failed to update reaction: "+err.Error())
+		return
+	}
+
+	handler.ack(session, frame, updated)
+
+	// repeating an add or remove changes nothing for the room
+	if updated.Seq != 0 {
+		handler.broadcastToRoom(updated.ChatID, ws.Event{
+			Type:    ws.TypeReactionUpdated,
+			Payload: updated,
+		})
+	}
+}
+
+// handleMessageAck records that new_message events reached the client and
+// tells each sender how far their message got.
+func (handler *WebSocketHandler) handleMessageAck(ctx context.Context, session *WebSocketSession, frame ws.Envelope) {
+	var msg ws.MessageAckPayload
+	if err := decodePayload(frame, &msg); err != nil {
+		handler.sendError(session, frame.ID, "invalid ack payload")
+		return
+	}
+
+	handler.Log.WS.Trace.Trace().
+		Str("userId", session.UserID).
+		Int("messageCount", len(msg.MessageIDs)).
+		Msg("Processing ack request")
+
+	changes, err := handler.MessageUC.MarkDelivered(ctx, session.UserID, msg.MessageIDs)
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("userId", session.UserID).
+			Err(err).
+			Msg("Failed to mark messages as delivered")
+		handler.sendError(session, frame.ID, "failed to acknowledge messages: "+err.Error())
+		return
+	}
+
+	for _, change := range changes {
+		handler.NotifyUsers("", []string{change.SenderID}, ws.Event{
+			Type:    ws.TypeMessageStatus,
+			Payload: change.Payload,
+		})
+	}
+}
+
+func (handler *WebSocketHandler) handleMarkRead(ctx context.Context, session *WebSocketSession, frame ws.Envelope) {
+	var msg ws.MarkReadPayload
+	if err := decodePayload(frame, &msg); err != nil {
+		handler.sendError(session, frame.ID, "invalid mark_read payload")
+		return
+	}
+
+	handler.Log.WS.Info.Info().
+		Str("userId", session.UserID).
+		Str("chatId", msg.ChatID).
+		Str("messageId", msg.MessageID).
+		Msg("Processing mark_read request")
+
+	if msg.ChatID == "" {
+		handler.sendError(session, frame.ID, "chatId is required")
+		return
+	}
+
+	receipt, err := handler.MessageUC.MarkRead(ctx, session.UserID, msg.ChatID, msg.MessageID)
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("userId", session.UserID).
+			Str("chatId", msg.ChatID).
+			Err(err).
+			Msg("Failed to mark messages as read")
+		handler.sendError(session, frame.ID, "failed to mark messages as read: "+err.Error())
+		return
+	}
+
+	handler.ack(session, frame, receipt)
+
+	// a watermark that did not move has nothing new to tell the room
+	if receipt.Seq != 0 {
+		handler.broadcastToRoom(receipt.ChatID, ws.Event{
+			Type:    ws.TypeReadReceipt,
+			Payload: receipt,
+		})
+	}
+}
+
+func (handler *WebSocketHandler) broadcastToRoom(chatID string, event ws.Event) {
+	handler.publish(delivery{Target: targetRoom, ChatID: chatID}, event)
+}
+
+func (handler *WebSocketHandler) deliverToRoom(chatID, excludeUserID string, msg outboundMessage) {
 	handler.Mutex.RLock()
-	room, exists := handler.Rooms[chatID]
-	handler.Mutex.RUnlock()
+	defer handler.Mutex.RUnlock()
 
+	room, exists := handler.Rooms[chatID]
 	if !exists {
-		handler.Log.WS.Warning.Warn().
+		handler.Log.WS.Trace.Trace().
 			Str("chatId", chatID).
-			Msg("Cannot broadcast: room not found")
+			Msg("No local sessions in room")
 		return
 	}
 
 	successCount := 0
 	failCount := 0
 
-	for userID, conn := range room {
-		if err := conn.WriteJSON(message); err != nil {
-			handler.Log.WS.Error.Error().
-				Str("userId", userID).
-				Str("chatId", chatID).
-				Err(err).
-				Msg("Failed to send message to user")
-			failCount++
-		} else {
+	for _, session := range room {
+		if excludeUserID != "" && session.UserID == excludeUserID {
+			continue
+		}
+		if handler.enqueue(session, msg) {
 			successCount++
+		} else {
+			failCount++
 		}
 	}
 
@@ -361,8 +838,8 @@ func (handler *WebSocketHandler) broadcastToRoom(chatID string, message interfac
 		Str("chatId", chatID).
 		Int("successCount", successCount).
 		Int("failCount", failCount).
-		Int("totalUsers", len(room)).
-		Str("messageType", "new_message").
+		Int("totalSessions", len(room)).
+		Str("messageType", msg.Type).
 		Msg("Message broadcast completed")
 }
 
@@ -391,22 +868,20 @@ func (handler *WebSocketHandler) notifyNewChat(ctx context.Context, chatID, send
 		return
 	}
 
-	notification := map[string]interface{}{
-		"type":         "new_chat",
-		"chatId":       chatID,
-		"chatUsername": sender.Name,
-		"chatAvatar":   sender.Avatar,
-		"chatType":     string(chat.ChatType),
-		"lastMessage":  "",
-		"unreadCount":  0,
-	}
-
-	handler.sendToUser(receiverID, notification)
+	handler.sendToUser(receiverID, ws.Event{
+		Type: ws.TypeNewChat,
+		Payload: ws.NewChatPayload{
+			ChatID:       chatID,
+			ChatUsername: sender.Name,
+			ChatAvatar:   sender.Avatar,
+			ChatType:     string(chat.ChatType),
+		},
+	})
 
 	handler.Log.WS.Stream.Info().
 		Str("receiverId", receiverID).
 		Str("chatId", chatID).
-		Str("type", "new_chat").
+		Str("type", ws.TypeNewChat).
 		Msg("Sent new_chat notification")
 }
 
@@ -445,10 +920,6 @@ func (handler *WebSocketHandler) notifyOfflineParticipants(ctx context.Context,
 		return
 	}
 
-	handler.Mutex.RLock()
-	room := handler.Rooms[chatID]
-	handler.Mutex.RUnlock()
-
 	offlineCount := 0
 
 	for _, p := range participants {
@@ -456,34 +927,27 @@ func (handler *WebSocketHandler) notifyOfflineParticipants(ctx context.Context,
 			continue
 		}
 
-		if room != nil {
-			if _, inRoom := room[p.UserID]; inRoom {
-				handler.Log.WS.Trace.Trace().
-					Str("userId", p.UserID).
-					Str("chatId", chatID).
-					Msg("User already in room, skipping notification")
-				continue
-			}
-		}
-
-		var unreadCount int64
-		handler.DB.Model(&entity.MessageStatus{}).
-			Joins("JOIN t_messages ON t_messages.id = t_message_status.message_id").
-			Where("t_message_status.user_id = ? AND t_messages.chat_id = ? AND t_message_status.is_read = false",
-				p.UserID, chatID).
-			Count(&unreadCount)
-
-		notification := map[string]interface{}{
-			"type":            "chat_update",
-			"chatId":          chatID,
-			"chatUsername":    sender.Name,
-			"chatAvatar":      sender.Avatar,
-			"lastMessage":     lastMessage.Content,
-			"lastMessageTime": lastMessage.CreatedAt.Format("2006-01-02 15:04:05"),
-			"unreadCount":     unreadCount,
+		unreadCount, err := handler.ChatUC.CountUnread(ctx, p.UserID, chatID)
+		if err != nil {
+			handler.Log.WS.Warning.Warn().
+				Str("chatId", chatID).
+				Str("userId", p.UserID).
+				Err(err).
+				Msg("Failed to count unread messages")
 		}
 
-		handler.sendToUser(p.UserID, notification)
+		// sessions joined to the room already received new_message, on whichever node they live
+		handler.publish(delivery{Target: targetUser, UserID: p.UserID, SkipInRoom: chatID}, ws.Event{
+			Type: ws.TypeChatUpdate,
+			Payload: ws.ChatUpdatePayload{
+				ChatID:          chatID,
+				ChatUsername:    sender.Name,
+				ChatAvatar:      sender.Avatar,
+				LastMessage:     lastMessage.Content,
+				LastMessageTime: lastMessage.CreatedAt.Format("2006-01-02 15:04:05"),
+				UnreadCount:     unreadCount,
+			},
+		})
 		offlineCount++
 	}
 
@@ -495,156 +959,221 @@ func (handler *WebSocketHandler) notifyOfflineParticipants(ctx context.Context,
 	}
 }
 
-func (handler *WebSocketHandler) sendToUser(userID string, message interface{}) {
+func (handler *WebSocketHandler) sendToUser(userID string, event ws.Event) {
+	handler.publish(delivery{Target: targetUser, UserID: userID}, event)
+}
+
+func (handler *WebSocketHandler) deliverToUser(userID, skipInRoom string, msg outboundMessage) {
 	handler.Mutex.RLock()
-	conn, exists := handler.Clients[userID]
-	handler.Mutex.RUnlock()
+	defer handler.Mutex.RUnlock()
 
+	sessions, exists := handler.Clients[userID]
 	if !exists {
 		handler.Log.WS.Trace.Trace().
 			Str("userId", userID).
-			Msg("User is offline, cannot send notification")
+			Msg("User has no local sessions, skipping notification")
 		return
 	}
 
-	if err := conn.WriteJSON(message); err != nil {
-		handler.Log.WS.Error.Error().
-			Str("userId", userID).
-			Err(err).
-			Msg("Failed to send notification to user")
-	} else {
-		msgType := "unknown"
-		if m, ok := message.(map[string]interface{}); ok {
-			if t, ok := m["type"].(string); ok {
-				msgType = t
+	for _, session := range sessions {
+		if skipInRoom != "" {
+			if _, inRoom := session.Rooms[skipInRoom]; inRoom {
+				continue
 			}
 		}
 
+		if !handler.enqueue(session, msg) {
+			handler.Log.WS.Error.Error().
+				Str("userId", userID).
+				Str("sessionId", session.ID).
+				Msg("Failed to send notification to user")
+			continue
+		}
+
 		handler.Log.WS.Stream.Info().
 			Str("userId", userID).
-			Str("messageType", msgType).
-			Msg("Notification sent to user")
+			Str("sessionId", session.ID).
+			Str("messageType", msg.Type).
+			Msg("Notification queued for user")
 	}
 }
 
-func (handler *WebSocketHandler) handleTyping(userID, chatID string, isTyping bool) {
-	if chatID == "" {
-		handler.Log.WS.Warning.Warn().
-			Str("userId", userID).
-			Msg("Typing event failed: chatId is empty")
+func (handler *WebSocketHandler) handleSync(ctx context.Context, session *WebSocketSession, frame ws.Envelope) {
+	var request req.SyncRequest
+	if err := decodePayload(frame, &request); err != nil {
+		handler.sendError(session, frame.ID, "invalid sync payload")
 		return
 	}
 
-	handler.Mutex.RLock()
-	room, exists := handler.Rooms[chatID]
-	handler.Mutex.RUnlock()
+	handler.syncEvents(ctx, session, frame.ID, request)
+}
 
-	if !exists {
-		handler.Log.WS.Warning.Warn().
-			Str("userId", userID).
-			Str("chatId", chatID).
-			Msg("Typing event failed: room not found")
+func (handler *WebSocketHandler) syncEvents(ctx context.Context, session *WebSocketSession, requestID string, request req.SyncRequest) {
+	response, err := handler.ChatUC.SyncEvents(ctx, session.UserID, request)
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("userId", session.UserID).
+			Str("sessionId", session.ID).
+			Err(err).
+			Msg("Failed to sync chat events")
+		handler.sendError(session, requestID, "failed to sync: "+err.Error())
 		return
 	}
 
-	typingMsg := map[string]interface{}{
-		"type":     "typing",
-		"chatId":   chatID,
-		"userId":   userID,
-		"isTyping": isTyping,
-	}
+	handler.send(session, ws.Event{
+		Type:    ws.TypeSync,
+		ID:      requestID,
+		Payload: response,
+	})
 
-	sentCount := 0
-	for uid, conn := range room {
-		if uid != userID {
-			if err := conn.WriteJSON(typingMsg); err == nil {
-				sentCount++
-			}
-		}
+	handler.Log.WS.Stream.Info().
+		Str("userId", session.UserID).
+		Str("sessionId", session.ID).
+		Int("eventCount", len(response.Events)).
+		Int64("globalSeq", response.GlobalSeq).
+		Bool("hasMore", response.HasMore).
+		Str("type", ws.TypeSync).
+		Msg("Sent sync batch")
+}
+
+func (handler *WebSocketHandler) handleTyping(session *WebSocketSession, frame ws.Envelope, isTyping bool) {
+	userID := session.UserID
+
+	var payload ws.TypingPayload
+	_ = decodePayload(frame, &payload)
+	chatID := payload.ChatID
+
+	if chatID == "" {
+		handler.Log.WS.Warning.Warn().
+			Str("userId", userID).
+			Msg("Typing event failed: chatId is empty")
+		return
 	}
 
+	handler.publish(delivery{Target: targetRoom, ChatID: chatID, ExcludeUserID: userID}, ws.Event{
+		Type: ws.TypeTyping,
+		Payload: ws.TypingEventPayload{
+			ChatID:   chatID,
+			UserID:   userID,
+			IsTyping: isTyping,
+		},
+	})
+
 	handler.Log.WS.Trace.Trace().
 		Str("userId", userID).
 		Str("chatId", chatID).
 		Bool("isTyping", isTyping).
-		Int("sentCount", sentCount).
-		Msg("Typing indicator broadcasted")
+		Msg("Typing indicator published")
 }
 
-func (handler *WebSocketHandler) sendErrorToUser(userID, errorMsg string) {
-	handler.Mutex.RLock()
-	conn, exists := handler.Clients[userID]
-	handler.Mutex.RUnlock()
-
-	if exists {
-		errorPayload := map[string]string{
-			"type":  "error",
-			"error": errorMsg,
-		}
-		_ = conn.WriteJSON(errorPayload)
+func (handler *WebSocketHandler) sendError(session *WebSocketSession, requestID, errorMsg string) {
+	handler.send(session, ws.Event{
+		Type:    ws.TypeError,
+		ID:      requestID,
+		Payload: ws.ErrorPayload{Error: errorMsg},
+	})
 
-		handler.Log.WS.Stream.Error().
-			Str("userId", userID).
-			Str("error", errorMsg).
-			Str("type", "error").
-			Msg("Sent error response to user")
-	}
+	handler.Log.WS.Stream.Error().
+		Str("userId", session.UserID).
+		Str("sessionId", session.ID).
+		Str("requestId", requestID).
+		Str("error", errorMsg).
+		Str("type", ws.TypeError).
+		Msg("Sent error response")
 }
 
-func (handler *WebSocketHandler) sendError(c *websocket.Conn, errorMsg string) {
-	errorPayload := map[string]string{
-		"type":  "error",
-		"error": errorMsg,
+// ack confirms a command to clients that sent a request id, legacy clients never get acks.
+func (handler *WebSocketHandler) ack(session *WebSocketSession, frame ws.Envelope, result interface{}) {
+	if frame.ID == "" || session.Version < ws.Version2 {
+		return
 	}
-	_ = c.WriteJSON(errorPayload)
 
-	handler.Log.WS.Stream.Error().
-		Str("error", errorMsg).
-		Str("type", "error").
-		Msg("Sent error response")
+	handler.send(session, ws.Event{
+		Type: ws.TypeAck,
+		ID:   frame.ID,
+		Payload: ws.AckPayload{
+			Type:   frame.Type,
+			Result: result,
+		},
+	})
 }
 
-func (handler *WebSocketHandler) registerClient(userID string, conn *websocket.Conn) {
+func (handler *WebSocketHandler) registerClient(session *WebSocketSession) {
 	handler.Mutex.Lock()
 	defer handler.Mutex.Unlock()
 
-	handler.Clients[userID] = conn
+	if handler.Clients[session.UserID] == nil {
+		handler.Clients[session.UserID] = make(map[string]*WebSocketSession)
+	}
+	handler.Clients[session.UserID][session.ID] = session
 
 	handler.Log.WS.Info.Info().
-		Str("userId", userID).
+		Str("userId", session.UserID).
+		Str("sessionId", session.ID).
+		Str("device", session.Device).
+		Int("userSessions", len(handler.Clients[session.UserID])).
 		Int("totalClients", len(handler.Clients)).
-		Str("remoteAddr", conn.RemoteAddr().String()).
+		Str("remoteAddr", session.Conn.RemoteAddr().String()).
 		Msg("User registered successfully")
 }
 
-func (handler *WebSocketHandler) removeClient(userID string, conn *websocket.Conn) {
+func (handler *WebSocketHandler) removeClient(session *WebSocketSession) {
+	// the reaper and the connection loop may both remove a session, presence is
+	// told once and only after the mutex is released
+	removed := false
+	defer func() {
+		if removed {
+			handler.Presence.Disconnect(session.UserID, session.ID)
+		}
+	}()
+
 	handler.Mutex.Lock()
 	defer handler.Mutex.Unlock()
 
-	delete(handler.Clients, userID)
+	// only this session goes away, other devices of the same user stay connected
+	if sessions, ok := handler.Clients[session.UserID]; ok {
+		_, removed = sessions[session.ID]
+		delete(sessions, session.ID)
+		if len(sessions) == 0 {
+			delete(handler.Clients, session.UserID)
+		}
+	}
 
 	roomsLeft := 0
-	for chatID, room := range handler.Rooms {
-		if _, exists := room[userID]; exists {
-			delete(room, userID)
-			roomsLeft++
+	for chatID := range session.Rooms {
+		room, exists := handler.Rooms[chatID]
+		if !exists {
+			continue
+		}
+
+		delete(room, session.ID)
+		roomsLeft++
 
+		handler.Log.WS.Trace.Trace().
+			Str("userId", session.UserID).
+			Str("sessionId", session.ID).
+			Str("chatId", chatID).
+			Msg("Session removed from room during disconnect")
+
+		if len(room) == 0 {
+			delete(handler.Rooms, chatID)
 			handler.Log.WS.Trace.Trace().
-				Str("userId", userID).
 				Str("chatId", chatID).
-				Msg("User removed from room during disconnect")
-
-			if len(room) == 0 {
-				delete(handler.Rooms, chatID)
-				handler.Log.WS.Trace.Trace().
-					Str("chatId", chatID).
-					Msg("Empty room deleted during user disconnect")
-			}
+				Msg("Empty room deleted during user disconnect")
 		}
 	}
+	session.Rooms = make(map[string]struct{})
+
+	watching := make([]string, 0, len(session.Watching))
+	for userID := range session.Watching {
+		watching = append(watching, userID)
+	}
+	handler.unwatch(session, watching)
 
 	handler.Log.WS.Info.Info().
-		Str("userId", userID).
+		Str("userId", session.UserID).
+		Str("sessionId", session.ID).
+		Int("remainingUserSessions", len(handler.Clients[session.UserID])).
 		Int("remainingClients", len(handler.Clients)).
 		Int("roomsLeft", roomsLeft).
 		Msg("User disconnected and cleaned up")
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"real-time-chat-app/dto/res"
+	"sort"
+	"sync/atomic"
+)
+
+const maxReportedSessions = 10
+
+type WebSocketMetrics struct {
+	Enqueued                atomic.Uint64
+	Dropped                 atomic.Uint64
+	DroppedTyping           atomic.Uint64
+	SlowConsumerDisconnects atomic.Uint64
+}
+
+func (handler *WebSocketHandler) MetricsSnapshot() res.WebSocketMetricsResponse {
+	handler.Mutex.RLock()
+	sessions := make([]*WebSocketSession, 0)
+	for _, userSessions := range handler.Clients {
+		for _, session := range userSessions {
+			sessions = append(sessions, session)
+		}
+	}
+	handler.Mutex.RUnlock()
+
+	snapshot := res.WebSocketMetricsResponse{
+		ActiveSessions:          len(sessions),
+		QueueCapacity:           handler.Config.SendQueueSize,
+		OverflowPolicy:          handler.Config.OverflowPolicy,
+		Enqueued:                handler.Metrics.Enqueued.Load(),
+		Dropped:                 handler.Metrics.Dropped.Load(),
+		DroppedTyping:           handler.Metrics.DroppedTyping.Load(),
+		SlowConsumerDisconnects: handler.Metrics.SlowConsumerDisconnects.Load(),
+	}
+
+	sessionMetrics := make([]res.WebSocketSessionMetrics, 0, len(sessions))
+	for _, session := range sessions {
+		depth := session.QueueDepth()
+		snapshot.TotalQueued += depth
+		if depth > snapshot.MaxQueueDepth {
+			snapshot.MaxQueueDepth = depth
+		}
+
+		sessionMetrics = append(sessionMetrics, res.WebSocketSessionMetrics{
+			QueueDepth: depth,
+			HighWater:  int(session.highWater.Load()),
+			Dropped:    session.dropped.Load(),
+		})
+	}
+
+	// only report the busiest sessions
+	sort.Slice(sessionMetrics, func(i, j int) bool {
+		return sessionMetrics[i].QueueDepth > sessionMetrics[j].QueueDepth
+	})
+	if len(sessionMetrics) > maxReportedSessions {
+		sessionMetrics = sessionMetrics[:maxReportedSessions]
+	}
+	snapshot.BusiestSessions = sessionMetrics
+
+	return snapshot
+}
+
+func (handler *WebSocketHandler) GetMetrics(c *fiber.Ctx) error {
+	handler.Log.Http.Stream.Info().
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("ip", c.IP()).
+		Msg("Incoming request: Get WebSocket metrics")
+
+	snapshot := handler.MetricsSnapshot()
+
+	handler.Log.Http.Stream.Info().
+		Int("statusCode", fiber.StatusOK).
+		Int("activeSessions", snapshot.ActiveSessions).
+		Int("totalQueued", snapshot.TotalQueued).
+		Msg("Response: Successfully retrieved WebSocket metrics")
+
+	return c.Status(fiber.StatusOK).JSON(res.CommonResponse[res.WebSocketMetricsResponse]{
+		Message:    "Successfully to Get WebSocket Metrics",
+		StatusCode: fiber.StatusOK,
+		Data:       snapshot,
+	})
+}
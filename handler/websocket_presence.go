@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"real-time-chat-app/dto/ws"
+	"real-time-chat-app/enum"
+)
+
+// PresenceChanged delivers a presence event to the local sessions watching the
+// user. Every node runs its own tracker, so there is nothing to publish.
+func (handler *WebSocketHandler) PresenceChanged(payload ws.PresencePayload) {
+	msg, err := newOutboundMessage(ws.Event{Type: ws.TypePresence, Payload: payload})
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("userId", payload.UserID).
+			Err(err).
+			Msg("Failed to encode presence event")
+		return
+	}
+
+	handler.Mutex.RLock()
+	defer handler.Mutex.RUnlock()
+
+	watchers := handler.Watchers[payload.UserID]
+	for _, session := range watchers {
+		handler.enqueue(session, msg)
+	}
+
+	handler.Log.WS.Trace.Trace().
+		Str("userId", payload.UserID).
+		Str("status", payload.Status).
+		Int("watcherCount", len(watchers)).
+		Msg("Presence event delivered")
+}
+
+func (handler *WebSocketHandler) handleSetPresence(session *WebSocketSession, frame ws.Envelope) {
+	var msg ws.SetPresencePayload
+	if err := decodePayload(frame, &msg); err != nil {
+		handler.sendError(session, frame.ID, "invalid set_presence payload")
+		return
+	}
+
+	handler.Log.WS.Trace.Trace().
+		Str("userId", session.UserID).
+		Str("sessionId", session.ID).
+		Str("status", msg.Status).
+		Msg("Processing set_presence request")
+
+	if err := handler.Presence.SetStatus(session.UserID, session.ID, enum.PresenceStatus(msg.Status)); err != nil {
+		handler.sendError(session, frame.ID, "failed to set presence: "+err.Error())
+		return
+	}
+
+	handler.ack(session, frame, msg)
+}
+
+func (handler *WebSocketHandler) handleSubscribePresence(ctx context.Context, session *WebSocketSession, frame ws.Envelope) {
+	// an empty payload subscribes to every contact
+	var msg ws.PresenceSubscriptionPayload
+	if len(frame.Payload) > 0 {
+		if err := decodePayload(frame, &msg); err != nil {
+			handler.sendError(session, frame.ID, "invalid subscribe_presence payload")
+			return
+		}
+	}
+
+	presences, err := handler.Presence.Subscribe(ctx, session.UserID, msg.UserIDs)
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("userId", session.UserID).
+			Err(err).
+			Msg("Failed to subscribe to presence")
+		handler.sendError(session, frame.ID, "failed to subscribe to presence: "+err.Error())
+		return
+	}
+
+	handler.Mutex.Lock()
+	for _, presence := range presences {
+		if handler.Watchers[presence.UserID] == nil {
+			handler.Watchers[presence.UserID] = make(map[string]*WebSocketSession)
+		}
+		handler.Watchers[presence.UserID][session.ID] = session
+		session.Watching[presence.UserID] = struct{}{}
+	}
+	watching := len(session.Watching)
+	handler.Mutex.Unlock()
+
+	handler.Log.WS.Info.Info().
+		Str("userId", session.UserID).
+		Str("sessionId", session.ID).
+		Int("subscribed", len(presences)).
+		Int("watching", watching).
+		Msg("Presence subscription updated")
+
+	// legacy clients get no ack, so they receive the current state as events
+	if frame.ID != "" && session.Version >= ws.Version2 {
+		handler.ack(session, frame, presences)
+		return
+	}
+	for _, presence := range presences {
+		handler.send(session, ws.Event{Type: ws.TypePresence, Payload: presence})
+	}
+}
+
+func (handler *WebSocketHandler) handleUnsubscribePresence(session *WebSocketSession, frame ws.Envelope) {
+	// an empty payload drops every subscription of the session
+	var msg ws.PresenceSubscriptionPayload
+	if len(frame.Payload) > 0 {
+		if err := decodePayload(frame, &msg); err != nil {
+			handler.sendError(session, frame.ID, "invalid unsubscribe_presence payload")
+			return
+		}
+	}
+
+	handler.Mutex.Lock()
+	userIDs := msg.UserIDs
+	if len(userIDs) == 0 {
+		userIDs = make([]string, 0, len(session.Watching))
+		for userID := range session.Watching {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	handler.unwatch(session, userIDs)
+	handler.Mutex.Unlock()
+
+	handler.ack(session, frame, ws.PresenceSubscriptionPayload{UserIDs: userIDs})
+}
+
+// unwatch drops the session's presence subscriptions, the caller holds the mutex.
+func (handler *WebSocketHandler) unwatch(session *WebSocketSession, userIDs []string) {
+	for _, userID := range userIDs {
+		delete(session.Watching, userID)
+		if watchers, ok := handler.Watchers[userID]; ok {
+			delete(watchers, session.ID)
+			if len(watchers) == 0 {
+				delete(handler.Watchers, userID)
+			}
+		}
+	}
+}
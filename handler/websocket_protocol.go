@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"real-time-chat-app/dto/ws"
+)
+
+// legacyTypeKeys names the v1 field carrying a payload's own "type", which
+// the flat legacy frame uses for the event type.
+var legacyTypeKeys = map[string]string{
+	ws.TypeNewMessage: "messageType",
+	ws.TypeAck:        "ackType",
+}
+
+func protocolVersion(subprotocol string) int {
+	if subprotocol == ws.ProtocolV2 {
+		return ws.Version2
+	}
+	return ws.Version1
+}
+
+func decodeFrame(version int, data []byte) (ws.Envelope, error) {
+	if version >= ws.Version2 {
+		var frame ws.Envelope
+		if err := json.Unmarshal(data, &frame); err != nil {
+			return ws.Envelope{}, err
+		}
+		if frame.V != version {
+			return ws.Envelope{}, fmt.Errorf("unsupported protocol version: %d", frame.V)
+		}
+		return frame, nil
+	}
+
+	// legacy frames are flat, the whole frame doubles as the payload
+	var legacy struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return ws.Envelope{}, err
+	}
+
+	return ws.Envelope{V: ws.Version1, Type: legacy.Type, Payload: data}, nil
+}
+
+func decodePayload(frame ws.Envelope, target interface{}) error {
+	if len(frame.Payload) == 0 {
+		return fmt.Errorf("payload is required for %s", frame.Type)
+	}
+	return json.Unmarshal(frame.Payload, target)
+}
+
+func encodeEvent(version int, event ws.Event) ([]byte, error) {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if version >= ws.Version2 {
+		return json.Marshal(ws.Envelope{
+			V:       version,
+			Type:    event.Type,
+			ID:      event.ID,
+			Payload: payload,
+		})
+	}
+
+	// legacy clients expect the payload fields next to "type", a payload type
+	// of its own moves aside instead of being overwritten
+	fields := make(map[string]json.RawMessage)
+	if event.Payload != nil {
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			return nil, err
+		}
+	}
+	if own, ok := fields["type"]; ok {
+		key, ok := legacyTypeKeys[event.Type]
+		if !ok {
+			key = "payloadType"
+		}
+		fields[key] = own
+	}
+	fields["type"], _ = json.Marshal(event.Type)
+
+	return json.Marshal(fields)
+}
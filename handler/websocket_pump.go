@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"github.com/gofiber/contrib/websocket"
+	"real-time-chat-app/dto/ws"
+	"time"
+)
+
+// Overflow policies applied when a session's outbound queue is full.
+const (
+	OverflowDisconnect = "disconnect"
+	OverflowDrop       = "drop"
+)
+
+func (handler *WebSocketHandler) writePump(session *WebSocketSession) {
+	ticker := time.NewTicker(handler.Config.PingInterval)
+	defer func() {
+		ticker.Stop()
+		close(session.writerDone)
+	}()
+
+	for {
+		select {
+		case <-session.done:
+			return
+		case <-ticker.C:
+			if err := session.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(handler.Config.WriteTimeout)); err != nil {
+				handler.Log.WS.Warning.Warn().
+					Str("userId", session.UserID).
+					Str("sessionId", session.ID).
+					Err(err).
+					Msg("Failed to send ping, closing connection")
+				_ = session.Conn.Close()
+				return
+			}
+		case msg := <-session.send:
+			_ = session.Conn.SetWriteDeadline(time.Now().Add(handler.Config.WriteTimeout))
+			if err := session.Conn.WriteMessage(websocket.TextMessage, msg.dataFor(session.Version)); err != nil {
+				handler.Log.WS.Error.Error().
+					Str("userId", session.UserID).
+					Str("sessionId", session.ID).
+					Str("messageType", msg.Type).
+					Err(err).
+					Msg("Failed to write message, closing connection")
+				_ = session.Conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// enqueue hands the message to the session's write pump without blocking the caller.
+func (handler *WebSocketHandler) enqueue(session *WebSocketSession, msg outboundMessage) bool {
+	// shed droppable events early so there is room left for real messages
+	if msg.Droppable && session.QueueDepth() >= session.QueueCapacity()/2 {
+		session.dropped.Add(1)
+		handler.Metrics.DroppedTyping.Add(1)
+		handler.Log.WS.Trace.Trace().
+			Str("userId", session.UserID).
+			Str("sessionId", session.ID).
+			Str("messageType", msg.Type).
+			Int("queueDepth", session.QueueDepth()).
+			Msg("Dropped droppable message, queue under pressure")
+		return false
+	}
+
+	select {
+	case <-session.done:
+		return false
+	case session.send <- msg:
+		handler.Metrics.Enqueued.Add(1)
+		depth := int64(session.QueueDepth())
+		for {
+			high := session.highWater.Load()
+			if depth <= high || session.highWater.CompareAndSwap(high, depth) {
+				break
+			}
+		}
+		return true
+	default:
+	}
+
+	session.dropped.Add(1)
+	handler.Metrics.Dropped.Add(1)
+
+	if handler.Config.OverflowPolicy == OverflowDrop {
+		handler.Log.WS.Warning.Warn().
+			Str("userId", session.UserID).
+			Str("sessionId", session.ID).
+			Str("messageType", msg.Type).
+			Msg("Outbound queue full, message dropped")
+		return false
+	}
+
+	handler.Metrics.SlowConsumerDisconnects.Add(1)
+	handler.Log.WS.Warning.Warn().
+		Str("userId", session.UserID).
+		Str("sessionId", session.ID).
+		Str("messageType", msg.Type).
+		Msg("Outbound queue full, disconnecting slow consumer")
+
+	// callers hold the hub lock and the close frame waits on the very socket
+	// that is backed up, so it is written off the caller's goroutine
+	session.stop()
+	go handler.closeWithCode(session.rawConn, CloseSlowConsumer, "slow consumer")
+	return false
+}
+
+func (handler *WebSocketHandler) send(session *WebSocketSession, event ws.Event) bool {
+	msg, err := newOutboundMessage(event)
+	if err != nil {
+		handler.Log.WS.Error.Error().
+			Str("userId", session.UserID).
+			Str("sessionId", session.ID).
+			Err(err).
+			Msg("Failed to encode outbound message")
+		return false
+	}
+
+	return handler.enqueue(session, msg)
+}
+
+// reapDeadSessions closes sessions that stopped answering pings, as a safety net
+// for half-open connections the read deadline did not catch.
+func (handler *WebSocketHandler) reapDeadSessions() {
+	ticker := time.NewTicker(handler.Config.ReapInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deadline := time.Now().Add(-handler.Config.IdleTimeout)
+
+		var dead []*WebSocketSession
+		handler.Mutex.RLock()
+		for _, sessions := range handler.Clients {
+			for _, session := range sessions {
+				if session.LastSeen().Before(deadline) {
+					dead = append(dead, session)
+				}
+			}
+		}
+		handler.Mutex.RUnlock()
+
+		for _, session := range dead {
+			handler.Log.WS.Warning.Warn().
+				Str("userId", session.UserID).
+				Str("sessionId", session.ID).
+				Time("lastSeen", session.LastSeen()).
+				Msg("Reaping dead WebSocket session")
+
+			handler.removeClient(session)
+			session.stop()
+			handler.closeWithCode(session.rawConn, CloseHeartbeatTimeout, "heartbeat timeout")
+		}
+
+		if len(dead) > 0 {
+			handler.Log.WS.Info.Info().
+				Int("reapedCount", len(dead)).
+				Msg("Dead WebSocket sessions reaped")
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	fasthttpws "github.com/fasthttp/websocket"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/google/uuid"
+	"real-time-chat-app/dto/ws"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const maxDeviceLabelLength = 100
+
+type WebSocketSession struct {
+	ID     string
+	UserID string
+	// login session of the token the connection authenticated with, guarded by WebSocketHandler.Mutex
+	AuthSessionID string
+	Device        string
+	Protocol      string
+	Version       int
+	ConnectedAt   time.Time
+	Conn          *websocket.Conn
+	Rooms         map[string]struct{} // chatIds joined by this session, guarded by WebSocketHandler.Mutex
+	Watching      map[string]struct{} // userIds whose presence this session follows, guarded by WebSocketHandler.Mutex
+
+	// the fiber conn wrapper is pooled once the handler returns, closes from
+	// other goroutines (timers, reaper) go through the underlying conn instead
+	rawConn    *fasthttpws.Conn
+	lastSeen   atomic.Int64
+	send       chan outboundMessage
+	done       chan struct{}
+	writerDone chan struct{}
+	closeOnce  sync.Once
+	highWater  atomic.Int64
+	dropped    atomic.Uint64
+}
+
+// outboundMessage is encoded once for every protocol version so a broadcast
+// does not re-encode per session.
+type outboundMessage struct {
+	Type      string
+	V1        []byte
+	V2        []byte
+	Droppable bool // typing indicators and other events that may be skipped under pressure
+}
+
+func NewWebSocketSession(userID string, conn *websocket.Conn, version int, queueSize int) *WebSocketSession {
+	// prefer the label sent by the client, fall back to the user agent
+	device := conn.Query("device")
+	if device == "" {
+		device = conn.Headers("User-Agent")
+	}
+	if runes := []rune(device); len(runes) > maxDeviceLabelLength {
+		device = string(runes[:maxDeviceLabelLength])
+	}
+
+	protocol := ws.ProtocolV1
+	if version >= ws.Version2 {
+		protocol = ws.ProtocolV2
+	}
+
+	session := &WebSocketSession{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Device:      device,
+		Protocol:    protocol,
+		Version:     version,
+		ConnectedAt: time.Now(),
+		Conn:        conn,
+		Rooms:       make(map[string]struct{}),
+		Watching:    make(map[string]struct{}),
+		rawConn:     conn.Conn,
+		send:        make(chan outboundMessage, queueSize),
+		done:        make(chan struct{}),
+		writerDone:  make(chan struct{}),
+	}
+	session.touch()
+	return session
+}
+
+func newOutboundMessage(event ws.Event) (outboundMessage, error) {
+	v1, err := encodeEvent(ws.Version1, event)
+	if err != nil {
+		return outboundMessage{}, err
+	}
+
+	v2, err := encodeEvent(ws.Version2, event)
+	if err != nil {
+		return outboundMessage{}, err
+	}
+
+	return outboundMessage{
+		Type:      event.Type,
+		V1:        v1,
+		V2:        v2,
+		Droppable: event.Type == ws.TypeTyping,
+	}, nil
+}
+
+func (msg outboundMessage) dataFor(version int) []byte {
+	if version >= ws.Version2 {
+		return msg.V2
+	}
+	return msg.V1
+}
+
+func (session *WebSocketSession) touch() {
+	session.lastSeen.Store(time.Now().UnixNano())
+}
+
+func (session *WebSocketSession) LastSeen() time.Time {
+	return time.Unix(0, session.lastSeen.Load())
+}
+
+func (session *WebSocketSession) QueueDepth() int {
+	return len(session.send)
+}
+
+func (session *WebSocketSession) QueueCapacity() int {
+	return cap(session.send)
+}
+
+// stop signals the write pump to exit, it is safe to call more than once.
+func (session *WebSocketSession) stop() {
+	session.closeOnce.Do(func() {
+		close(session.done)
+	})
+}
@@ -2,28 +2,34 @@ package middleware
 
 import (
 	jwtware "github.com/gofiber/contrib/jwt"
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	"real-time-chat-app/config/common"
 	"real-time-chat-app/config/logger"
 	"real-time-chat-app/dto/res"
 	"real-time-chat-app/security"
+	"strings"
 )
 
+// WSTokenSubprotocol is the Sec-WebSocket-Protocol entry that precedes the
+// access token for clients that cannot set query params or headers.
+const WSTokenSubprotocol = "access_token"
+
 type Middleware struct {
 	*common.Config
 	*security.JWT
 	Log *logger.AppLogger
 }
 
-func NewMiddleware(config *common.Config, logger *logger.AppLogger) *Middleware {
-	return &Middleware{Config: config, Log: logger}
+func NewMiddleware(config *common.Config, JWT *security.JWT, logger *logger.AppLogger) *Middleware {
+	return &Middleware{Config: config, JWT: JWT, Log: logger}
 }
 
 func (middleware *Middleware) JWTProtected(c *fiber.Ctx) error {
-	secretKey := middleware.GetJwtConfig()
-
 	return jwtware.New(jwtware.Config{
-		SigningKey: jwtware.SigningKey{Key: secretKey},
+		// the kid header picks the verification key, tokens without one use JWT_SECRET
+		KeyFunc:    middleware.JWT.Keyfunc,
 		ContextKey: "jwt",
 		ErrorHandler: func(ctx *fiber.Ctx, err error) error {
 			middleware.Log.Http.Error.Err(err).Msg("Failed to validate JWT")
@@ -33,6 +39,34 @@ func (middleware *Middleware) JWTProtected(c *fiber.Ctx) error {
 				Error:      "Token is not valid",
 			})
 		},
+		// a valid signature is not enough, the token may have been revoked by a logout
+		SuccessHandler: func(ctx *fiber.Ctx) error {
+			token, ok := ctx.Locals("jwt").(*jwt.Token)
+			if !ok {
+				return ctx.Next()
+			}
+			claims, _ := token.Claims.(jwt.MapClaims)
+
+			// a 2fa challenge only unlocks the second login step
+			if middleware.JWT.IsChallengeToken(claims) {
+				middleware.Log.Http.Warning.Warn().Msg("Rejected 2fa challenge token used as access token")
+				return ctx.Status(fiber.StatusUnauthorized).JSON(res.ErrorResponse{
+					Status:     fiber.ErrUnauthorized.Message,
+					StatusCode: fiber.StatusUnauthorized,
+					Error:      "Token is not valid",
+				})
+			}
+
+			if err := middleware.JWT.CheckRevoked(ctx.Context(), claims); err != nil {
+				middleware.Log.Http.Warning.Warn().Err(err).Msg("Rejected revoked JWT")
+				return ctx.Status(fiber.StatusUnauthorized).JSON(res.ErrorResponse{
+					Status:     fiber.ErrUnauthorized.Message,
+					StatusCode: fiber.StatusUnauthorized,
+					Error:      "Token has been revoked",
+				})
+			}
+			return ctx.Next()
+		},
 	})(c)
 }
 
@@ -53,3 +87,30 @@ func (middleware *Middleware) ExtractUserID(c *fiber.Ctx) error {
 	c.Locals("user_id", userID)
 	return c.Next()
 }
+
+func (middleware *Middleware) WebSocketUpgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	c.Locals("allowed", true)
+
+	// token from query param, otherwise from Sec-WebSocket-Protocol: access_token, <jwt>
+	token := c.Query("token")
+	if token == "" {
+		protocols := strings.Split(c.Get("Sec-WebSocket-Protocol"), ",")
+		for i := 0; i < len(protocols)-1; i++ {
+			if strings.TrimSpace(protocols[i]) == WSTokenSubprotocol {
+				token = strings.TrimSpace(protocols[i+1])
+				break
+			}
+		}
+	}
+
+	// an empty token is allowed here, the handler then expects an auth message as first frame
+	if token != "" {
+		c.Locals("ws_token", token)
+	}
+
+	return c.Next()
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
+	"time"
+)
+
+type AttachmentRepository struct {
+	Repository[entity.Attachment]
+}
+
+func NewAttachmentRepository() *AttachmentRepository {
+	return &AttachmentRepository{}
+}
+
+func (repository AttachmentRepository) FindInChat(ctx context.Context, db *gorm.DB, chatID, attachmentID string) (*entity.Attachment, error) {
+	var attachment entity.Attachment
+	err := db.WithContext(ctx).
+		Where("id = ? AND chat_id = ?", attachmentID, chatID).
+		First(&attachment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// LinkToMessage attaches uploads of the sender that no message claimed yet and
+// returns how many of them it found.
+func (repository AttachmentRepository) LinkToMessage(ctx context.Context, db *gorm.DB, chatID, uploaderID, messageID string, attachmentIDs []string) (int64, error) {
+	result := db.WithContext(ctx).
+		Model(&entity.Attachment{}).
+		Where("id IN ? AND chat_id = ? AND uploader_id = ? AND message_id IS NULL", attachmentIDs, chatID, uploaderID).
+		Update("message_id", messageID)
+	return result.RowsAffected, result.Error
+}
+
+// FindUnsent returns the uploads of the sender that no message claimed yet.
+func (repository AttachmentRepository) FindUnsent(ctx context.Context, db *gorm.DB, chatID, uploaderID string, attachmentIDs []string) ([]entity.Attachment, error) {
+	var attachments []entity.Attachment
+	err := db.WithContext(ctx).
+		Where("id IN ? AND chat_id = ? AND uploader_id = ? AND message_id IS NULL", attachmentIDs, chatID, uploaderID).
+		Order("created_at ASC").
+		Find(&attachments).Error
+	return attachments, err
+}
+
+func (repository AttachmentRepository) FindByMediaStatus(ctx context.Context, db *gorm.DB, status enum.MediaStatus) ([]entity.Attachment, error) {
+	var attachments []entity.Attachment
+	err := db.WithContext(ctx).
+		Where("media_status = ?", status).
+		Order("created_at ASC").
+		Find(&attachments).Error
+	return attachments, err
+}
+
+// ClaimMedia takes an image for processing unless another node holds a claim
+// younger than staleBefore, and reports whether it got it.
+func (repository AttachmentRepository) ClaimMedia(ctx context.Context, db *gorm.DB, attachmentID string, now, staleBefore time.Time) (bool, error) {
+	result := db.WithContext(ctx).
+		Model(&entity.Attachment{}).
+		Where("id = ? AND media_status = ? AND (media_claimed_at IS NULL OR media_claimed_at < ?)", attachmentID, enum.MediaStatusProcessing, staleBefore).
+		Update("media_claimed_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (repository AttachmentRepository) UpdateMedia(ctx context.Context, db *gorm.DB, attachment *entity.Attachment) error {
+	return db.WithContext(ctx).
+		Model(attachment).
+		Select("media_status", "thumbnail_key", "preview_key", "blur_hash").
+		Updates(attachment).Error
+}
+
+func (repository AttachmentRepository) FindByMessageID(ctx context.Context, db *gorm.DB, messageID string) ([]entity.Attachment, error) {
+	var attachments []entity.Attachment
+	err := db.WithContext(ctx).
+		Where("message_id = ?", messageID).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+// FindStaleUnsent returns uploads no message claimed since before.
+func (repository AttachmentRepository) FindStaleUnsent(ctx context.Context, db *gorm.DB, before time.Time, limit int) ([]entity.Attachment, error) {
+	var attachments []entity.Attachment
+	err := db.WithContext(ctx).
+		Where("message_id IS NULL AND created_at < ?", before).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+// DeleteUnsent deletes the upload unless a message claimed it meanwhile and
+// reports whether it did.
+func (repository AttachmentRepository) DeleteUnsent(ctx context.Context, db *gorm.DB, attachmentID string) (bool, error) {
+	result := db.WithContext(ctx).
+		Where("id = ? AND message_id IS NULL", attachmentID).
+		Delete(&entity.Attachment{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (repository AttachmentRepository) DeleteByMessageID(ctx context.Context, db *gorm.DB, messageID string) error {
+	return db.WithContext(ctx).
+		Where("message_id = ?", messageID).
+		Delete(&entity.Attachment{}).Error
+}
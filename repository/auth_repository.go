@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"context"
 	"gorm.io/gorm"
 	"real-time-chat-app/entity"
+	"time"
 )
 
 type AuthRepository struct {
@@ -21,3 +23,55 @@ func (repository AuthRepository) FindByUsername(db *gorm.DB, username string) (e
 	}
 	return *user, err
 }
+
+// SetLockedUntil locks the account until the given time, nil unlocks it.
+func (repository AuthRepository) SetLockedUntil(ctx context.Context, db *gorm.DB, accountID string, lockedUntil *time.Time) error {
+	return db.WithContext(ctx).
+		Model(&entity.Account{}).
+		Where("id = ?", accountID).
+		Update("locked_until", lockedUntil).Error
+}
+
+func (repository AuthRepository) FindByID(ctx context.Context, db *gorm.DB, accountID string) (*entity.Account, error) {
+	var account entity.Account
+	err := db.WithContext(ctx).
+		Preload("User").
+		Where("t_account.id = ?", accountID).
+		Take(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (repository AuthRepository) FindByUserID(ctx context.Context, db *gorm.DB, userID string) (*entity.Account, error) {
+	var account entity.Account
+	err := db.WithContext(ctx).
+		Preload("User").
+		Joins("JOIN t_user ON t_user.auth_id = t_account.id").
+		Where("t_user.id = ?", userID).
+		Take(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// SetTOTP stores the TOTP state of an account, an empty secret turns 2FA off.
+func (repository AuthRepository) SetTOTP(ctx context.Context, db *gorm.DB, accountID, secret string, enabled bool, lastStep int64) error {
+	return db.WithContext(ctx).
+		Model(&entity.Account{}).
+		Where("id = ?", accountID).
+		Updates(map[string]interface{}{
+			"totp_secret":    secret,
+			"totp_enabled":   enabled,
+			"totp_last_step": lastStep,
+		}).Error
+}
+
+func (repository AuthRepository) SetTOTPLastStep(ctx context.Context, db *gorm.DB, accountID string, lastStep int64) error {
+	return db.WithContext(ctx).
+		Model(&entity.Account{}).
+		Where("id = ?", accountID).
+		Update("totp_last_step", lastStep).Error
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"gorm.io/gorm"
+	"real-time-chat-app/entity"
+)
+
+type ChatEventRepository struct {
+	Repository[entity.ChatEvent]
+}
+
+func NewChatEventRepository() *ChatEventRepository {
+	return &ChatEventRepository{}
+}
+
+// NextSeq reserves the next sequence number of a chat, the row lock on t_chat
+// serializes concurrent writers until the surrounding transaction ends.
+func (repository ChatEventRepository) NextSeq(ctx context.Context, db *gorm.DB, chatID string) (int64, error) {
+	var seq int64
+	result := db.WithContext(ctx).
+		Raw("UPDATE t_chat SET last_seq = last_seq + 1 WHERE id = ? RETURNING last_seq", chatID).
+		Scan(&seq)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return seq, nil
+}
+
+func (repository ChatEventRepository) AppendWithSeq(ctx context.Context, db *gorm.DB, chatID string, seq int64, eventType string, payload interface{}) (*entity.ChatEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &entity.ChatEvent{
+		ChatID:  chatID,
+		Seq:     seq,
+		Type:    eventType,
+		Payload: string(data),
+	}
+	if err := db.WithContext(ctx).Create(event).Error; err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (repository ChatEventRepository) Append(ctx context.Context, db *gorm.DB, chatID string, eventType string, payload interface{}) (*entity.ChatEvent, error) {
+	var event *entity.ChatEvent
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		seq, err := repository.NextSeq(ctx, tx, chatID)
+		if err != nil {
+			return err
+		}
+		event, err = repository.AppendWithSeq(ctx, tx, chatID, seq, eventType, payload)
+		return err
+	})
+	return event, err
+}
+
+func (repository ChatEventRepository) FindAfterSeq(ctx context.Context, db *gorm.DB, chatID string, afterSeq int64, limit int) ([]entity.ChatEvent, error) {
+	var events []entity.ChatEvent
+	err := db.WithContext(ctx).
+		Where("chat_id = ? AND seq > ?", chatID, afterSeq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// TxHorizon returns the oldest transaction still running. Every event with a
+// lower TxID is committed, or rolled back, and is already visible.
+func (repository ChatEventRepository) TxHorizon(ctx context.Context, db *gorm.DB) (int64, error) {
+	var horizon int64
+	err := db.WithContext(ctx).
+		Raw("SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint").
+		Scan(&horizon).Error
+	return horizon, err
+}
+
+// FindTxRange returns the events of the chats written by transactions in
+// [fromTx, toTx), ordered by transaction. A limit below one returns them all.
+func (repository ChatEventRepository) FindTxRange(ctx context.Context, db *gorm.DB, chatIDs []string, fromTx, toTx int64, limit int) ([]entity.ChatEvent, error) {
+	var events []entity.ChatEvent
+	if len(chatIDs) == 0 || fromTx >= toTx {
+		return events, nil
+	}
+
+	query := db.WithContext(ctx).
+		Where("chat_id IN ? AND tx_id >= ? AND tx_id < ?", chatIDs, fromTx, toTx).
+		Order("tx_id ASC, id ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&events).Error
+	return events, err
+}
+
+// FindRemovals returns, per chat the user no longer belongs to, the latest
+// member_removed event naming them. The type is inlined to match the partial
+// index on the removed user ids.
+func (repository ChatEventRepository) FindRemovals(ctx context.Context, db *gorm.DB, userID string) ([]entity.ChatEvent, error) {
+	var events []entity.ChatEvent
+	err := db.WithContext(ctx).
+		Raw(`SELECT DISTINCT ON (e.chat_id) e.* FROM t_chat_event e
+			WHERE e.type = 'member_removed' AND e.payload->'userIds' @> jsonb_build_array(?::text)
+			AND NOT EXISTS (SELECT 1 FROM t_chat_participant p WHERE p.chat_id = e.chat_id AND p.user_id = ?)
+			ORDER BY e.chat_id, e.seq DESC`, userID, userID).
+		Scan(&events).Error
+	return events, err
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"gorm.io/gorm"
 	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
+	"time"
 )
 
 type ChatRepository struct {
@@ -71,11 +73,139 @@ func (repository ChatRepository) FindAllByUserID(ctx context.Context, db *gorm.D
 	return chats, nil
 }
 
+func (repository ChatRepository) FindChatIDsByUserID(ctx context.Context, db *gorm.DB, userID string) ([]string, error) {
+	var chatIDs []string
+	err := db.WithContext(ctx).
+		Model(&entity.ChatParticipant{}).
+		Where("user_id = ?", userID).
+		Pluck("chat_id", &chatIDs).Error
+	return chatIDs, err
+}
+
+// FindContactIDs returns everyone who shares at least one chat with the user.
+// Participants of deleted groups are kept, so the chat itself has to be live.
+func (repository ChatRepository) FindContactIDs(ctx context.Context, db *gorm.DB, userID string) ([]string, error) {
+	var contactIDs []string
+	err := db.WithContext(ctx).
+		Table("t_chat_participant AS me").
+		Joins("JOIN t_chat c ON c.id = me.chat_id AND c.deleted_at IS NULL").
+		Joins("JOIN t_chat_participant other ON other.chat_id = me.chat_id").
+		Where("me.user_id = ? AND other.user_id <> ?", userID, userID).
+		Distinct().
+		Pluck("other.user_id", &contactIDs).Error
+	return contactIDs, err
+}
+
+// SharesChat reports whether two users are contacts, by the rule of FindContactIDs.
+func (repository ChatRepository) SharesChat(ctx context.Context, db *gorm.DB, userID, otherID string) (bool, error) {
+	var count int64
+	err := db.WithContext(ctx).
+		Table("t_chat_participant AS me").
+		Joins("JOIN t_chat c ON c.id = me.chat_id AND c.deleted_at IS NULL").
+		Joins("JOIN t_chat_participant other ON other.chat_id = me.chat_id").
+		Where("me.user_id = ? AND other.user_id = ?", userID, otherID).
+		Limit(1).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (repository ChatRepository) FindParticipants(ctx context.Context, db *gorm.DB, chatID string) ([]entity.ChatParticipant, error) {
+	var participants []entity.ChatParticipant
+	err := db.WithContext(ctx).
+		Preload("User").
+		Where("chat_id = ?", chatID).
+		Order("joined_at ASC, id ASC").
+		Find(&participants).Error
+	return participants, err
+}
+
+func (repository ChatRepository) FindParticipant(ctx context.Context, db *gorm.DB, chatID, userID string) (*entity.ChatParticipant, error) {
+	var participant entity.ChatParticipant
+	err := db.WithContext(ctx).
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		First(&participant).Error
+	if err != nil {
+		return nil, err
+	}
+	return &participant, nil
+}
+
+func (repository ChatRepository) UpdateReadPosition(ctx context.Context, db *gorm.DB, chatID, userID string, message *entity.Messages, readAt time.Time) error {
+	return db.WithContext(ctx).
+		Model(&entity.ChatParticipant{}).
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		Updates(map[string]interface{}{
+			"last_read_seq":        message.Seq,
+			"last_read_message_id": message.ID,
+			"last_read_at":         readAt,
+		}).Error
+}
+
+// CountUnread counts, per chat, the visible messages of others past the
+// user's read position. Without chat ids it covers every chat of the user.
+func (repository ChatRepository) CountUnread(ctx context.Context, db *gorm.DB, userID string, chatIDs ...string) (map[string]int64, error) {
+	var rows []struct {
+		ChatID string
+		Count  int64
+	}
+
+	query := db.WithContext(ctx).
+		Table("t_messages").
+		Select("t_messages.chat_id, COUNT(t_messages.id) AS count").
+		Joins("JOIN t_chat_participant p ON p.chat_id = t_messages.chat_id AND p.user_id = ?", userID).
+		Where("t_messages.seq > p.last_read_seq AND t_messages.sender_id <> ?", userID).
+		Where("t_messages.deleted_at IS NULL AND t_messages.is_deleted = false").
+		Scopes(VisibleTo(userID))
+	if len(chatIDs) > 0 {
+		query = query.Where("t_messages.chat_id IN ?", chatIDs)
+	}
+
+	if err := query.Group("t_messages.chat_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		counts[r.ChatID] = r.Count
+	}
+	return counts, nil
+}
+
+func (repository ChatRepository) UpdateParticipantRole(ctx context.Context, db *gorm.DB, chatID, userID string, role enum.ParticipantRole) error {
+	return db.WithContext(ctx).
+		Model(&entity.ChatParticipant{}).
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		Update("role", role).Error
+}
+
+func (repository ChatRepository) AddParticipants(ctx context.Context, db *gorm.DB, chatID string, userIDs []string) error {
+	participants := make([]entity.ChatParticipant, 0, len(userIDs))
+	for _, id := range userIDs {
+		participants = append(participants, entity.ChatParticipant{ChatID: chatID, UserID: id, Role: enum.RoleMember})
+	}
+	return db.WithContext(ctx).Create(&participants).Error
+}
+
+func (repository ChatRepository) RemoveParticipants(ctx context.Context, db *gorm.DB, chatID string, userIDs []string) (int64, error) {
+	result := db.WithContext(ctx).
+		Where("chat_id = ? AND user_id IN ?", chatID, userIDs).
+		Delete(&entity.ChatParticipant{})
+	return result.RowsAffected, result.Error
+}
+
+func (repository ChatRepository) UpdateGroupName(ctx context.Context, db *gorm.DB, chatID, name string) error {
+	return db.WithContext(ctx).
+		Model(&entity.Chat{}).
+		Where("id = ?", chatID).
+		Update("group_name", name).Error
+}
+
 func (repository ChatRepository) IsUserInChat(ctx context.Context, db *gorm.DB, chatId, userId string) (bool, error) {
 	var count int64
 	err := db.WithContext(ctx).
 		Model(&entity.ChatParticipant{}).
-		Where("chat_id = ? AND user_id = ?", chatId, userId).
+		Joins("JOIN t_chat c ON c.id = t_chat_participant.chat_id AND c.deleted_at IS NULL").
+		Where("t_chat_participant.chat_id = ? AND t_chat_participant.user_id = ?", chatId, userId).
 		Count(&count).Error
 
 	if err != nil {
@@ -85,12 +215,46 @@ func (repository ChatRepository) IsUserInChat(ctx context.Context, db *gorm.DB,
 	return count > 0, nil
 }
 
-func (repository ChatRepository) FindMessagesByChatID(ctx context.Context, db *gorm.DB, chatId string) ([]entity.Messages, error) {
+// VisibleTo filters out messages the user deleted for themselves.
+func VisibleTo(userID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("NOT EXISTS (SELECT 1 FROM t_hidden_message h WHERE h.message_id = t_messages.id AND h.user_id = ? AND h.deleted_at IS NULL)", userID)
+	}
+}
+
+// RepliesTo narrows a message query to the replies of one message.
+func RepliesTo(messageID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("reply_to_id = ?", messageID)
+	}
+}
+
+// FindMessagesPage returns up to limit messages of a chat after (forward) or
+// before (backward) the cursor, ordered in the direction of travel.
+func (repository ChatRepository) FindMessagesPage(ctx context.Context, db *gorm.DB, chatId, userId string, cursor *MessageCursor, forward bool, limit int, scopes ...func(*gorm.DB) *gorm.DB) ([]entity.Messages, error) {
 	var messages []entity.Messages
-	err := db.WithContext(ctx).
+	query := db.WithContext(ctx).
 		Preload("Sender").
+		Preload("ReplyTo.Sender").
+		Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).
 		Where("chat_id = ?", chatId).
-		Order("created_at ASC").
-		Find(&messages).Error
+		Scopes(VisibleTo(userId)).
+		Scopes(scopes...)
+
+	if forward {
+		if cursor != nil {
+			query = query.Where("(created_at, id) > (?, ?)", cursor.CreatedAt, cursor.ID)
+		}
+		query = query.Order("created_at ASC, id ASC")
+	} else {
+		if cursor != nil {
+			query = query.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+		}
+		query = query.Order("created_at DESC, id DESC")
+	}
+
+	err := query.Limit(limit).Find(&messages).Error
 	return messages, err
 }
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
+	"time"
+)
+
+type LoginAttemptRepository struct {
+	Repository[entity.LoginAttempt]
+}
+
+func NewLoginAttemptRepository() *LoginAttemptRepository {
+	return &LoginAttemptRepository{}
+}
+
+func (repository LoginAttemptRepository) Find(ctx context.Context, db *gorm.DB, scope enum.LoginAttemptScope, subject string) (*entity.LoginAttempt, error) {
+	var attempt entity.LoginAttempt
+	err := db.WithContext(ctx).
+		Where("scope = ? AND subject = ?", scope, subject).
+		Take(&attempt).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// Lock loads the counter for update, creating it on the first failure so
+// concurrent attempts on different nodes count against the same row.
+func (repository LoginAttemptRepository) Lock(ctx context.Context, db *gorm.DB, scope enum.LoginAttemptScope, subject string) (*entity.LoginAttempt, error) {
+	created := &entity.LoginAttempt{Scope: scope, Subject: subject}
+	err := db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "scope"}, {Name: "subject"}}, DoNothing: true}).
+		Create(created).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var attempt entity.LoginAttempt
+	err = db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND subject = ?", scope, subject).
+		Take(&attempt).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (repository LoginAttemptRepository) Reset(ctx context.Context, db *gorm.DB, scope enum.LoginAttemptScope, subject string) error {
+	return db.WithContext(ctx).
+		Unscoped().
+		Where("scope = ? AND subject = ?", scope, subject).
+		Delete(&entity.LoginAttempt{}).Error
+}
+
+// PurgeStale drops counters without a failure since before that are not blocking anything.
+func (repository LoginAttemptRepository) PurgeStale(ctx context.Context, db *gorm.DB, before, now time.Time) error {
+	return db.WithContext(ctx).
+		Unscoped().
+		Where("last_failed_at < ? AND (blocked_until IS NULL OR blocked_until < ?)", before, now).
+		Delete(&entity.LoginAttempt{}).Error
+}
+
+func (repository LoginAttemptRepository) Audit(ctx context.Context, db *gorm.DB, failure *entity.FailedLogin) error {
+	return db.WithContext(ctx).Create(failure).Error
+}
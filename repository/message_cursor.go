@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+var errMalformedCursor = errors.New("malformed cursor")
+
+// MessageCursor is a keyset position on (created_at, id) within a chat.
+type MessageCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func (cursor MessageCursor) Encode() string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeMessageCursor(value string) (*MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errMalformedCursor
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found || id == "" {
+		return nil, errMalformedCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, errMalformedCursor
+	}
+
+	return &MessageCursor{CreatedAt: t, ID: id}, nil
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
+	"time"
+)
+
+type MessageRepository struct {
+	Repository[entity.Messages]
+}
+
+func NewMessageRepository() *MessageRepository {
+	return &MessageRepository{}
+}
+
+func (repository MessageRepository) FindInChat(ctx context.Context, db *gorm.DB, chatID, messageID string) (*entity.Messages, error) {
+	var message entity.Messages
+	err := db.WithContext(ctx).
+		Where("id = ? AND chat_id = ?", messageID, chatID).
+		First(&message).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (repository MessageRepository) UpdateContent(ctx context.Context, db *gorm.DB, messageID, content string, editedAt time.Time) error {
+	return db.WithContext(ctx).
+		Model(&entity.Messages{}).
+		Where("id = ?", messageID).
+		Updates(map[string]interface{}{
+			"content":   content,
+			"is_edited": true,
+			"edited_at": editedAt,
+		}).Error
+}
+
+func (repository MessageRepository) IncrementReplyCount(ctx context.Context, db *gorm.DB, messageID string) error {
+	return db.WithContext(ctx).
+		Model(&entity.Messages{}).
+		Where("id = ?", messageID).
+		UpdateColumn("reply_count", gorm.Expr("reply_count + 1")).Error
+}
+
+func (repository MessageRepository) Hide(ctx context.Context, db *gorm.DB, messageID, userID string) error {
+	return db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&entity.HiddenMessage{MessageID: messageID, UserID: userID}).Error
+}
+
+// Tombstone clears a message for everyone, including its edit history and the
+// content and attachments carried by earlier events in the chat log.
+func (repository MessageRepository) Tombstone(ctx context.Context, db *gorm.DB, chatID, messageID string, deletedAt time.Time) error {
+	err := db.WithContext(ctx).
+		Model(&entity.Messages{}).
+		Where("id = ?", messageID).
+		Updates(map[string]interface{}{
+			"content":                 "",
+			"is_deleted":              true,
+			"deleted_for_everyone_at": deletedAt,
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	if err := db.WithContext(ctx).Where("message_id = ?", messageID).Delete(&entity.MessageEdit{}).Error; err != nil {
+		return err
+	}
+
+	err = db.WithContext(ctx).
+		Exec(`UPDATE t_chat_event SET payload = jsonb_set(payload, '{content}', '""')
+			WHERE chat_id = ? AND payload->>'messageId' = ? AND payload->>'content' IS NOT NULL`, chatID, messageID).Error
+	if err != nil {
+		return err
+	}
+
+	// attachment metadata names files too, their blobs are removed by the caller
+	err = db.WithContext(ctx).
+		Exec(`UPDATE t_chat_event SET payload = jsonb_set(payload, '{attachments}', '[]')
+			WHERE chat_id = ? AND payload->>'messageId' = ? AND payload->'attachments' IS NOT NULL`, chatID, messageID).Error
+	if err != nil {
+		return err
+	}
+	err = db.WithContext(ctx).
+		Exec(`UPDATE t_chat_event SET payload = payload - 'attachment'
+			WHERE chat_id = ? AND payload->>'messageId' = ? AND payload->'attachment' IS NOT NULL`, chatID, messageID).Error
+	if err != nil {
+		return err
+	}
+
+	// replies logged earlier still quote the old content
+	return db.WithContext(ctx).
+		Exec(`UPDATE t_chat_event SET payload = jsonb_set(jsonb_set(payload, '{replyTo,content}', '""'), '{replyTo,isDeleted}', 'true')
+			WHERE chat_id = ? AND payload #>> '{replyTo,messageId}' = ?`, chatID, messageID).Error
+}
+
+func (repository MessageRepository) SaveEdit(ctx context.Context, db *gorm.DB, edit *entity.MessageEdit) error {
+	return db.WithContext(ctx).Create(edit).Error
+}
+
+func (repository MessageRepository) FindEdits(ctx context.Context, db *gorm.DB, messageID string) ([]entity.MessageEdit, error) {
+	var edits []entity.MessageEdit
+	err := db.WithContext(ctx).
+		Where("message_id = ?", messageID).
+		Order("created_at ASC").
+		Find(&edits).Error
+	return edits, err
+}
+
+// MarkDelivered moves the user's sent receipts of the messages to delivered and
+// returns the ids that changed.
+func (repository MessageRepository) MarkDelivered(ctx context.Context, db *gorm.DB, userID string, messageIDs []string, deliveredAt time.Time) ([]string, error) {
+	var updated []string
+	err := db.WithContext(ctx).
+		Raw(`UPDATE t_message_status SET status = ?, delivered_at = ?, updated_at = ?
+			WHERE user_id = ? AND message_id IN ? AND status = ? AND deleted_at IS NULL
+			RETURNING message_id`,
+			enum.MessageStatusDelivered, deliveredAt, deliveredAt, userID, messageIDs, enum.MessageStatusSent).
+		Scan(&updated).Error
+	return updated, err
+}
+
+// RefreshStatus recomputes the aggregate status of the messages from their
+// receipts and the recipients' read positions, a message is delivered or read
+// once every recipient got there.
+func (repository MessageRepository) RefreshStatus(ctx context.Context, db *gorm.DB, messageIDs []string) error {
+	return db.WithContext(ctx).
+		Exec(`UPDATE t_messages m SET status = agg.status
+			FROM (
+				SELECT s.message_id,
+					CASE WHEN bool_and(s.status = ? OR p.last_read_seq >= msg.seq) THEN ?
+						WHEN bool_and(s.status IN ? OR p.last_read_seq >= msg.seq) THEN ?
+						ELSE ? END AS status
+				FROM t_message_status s
+				JOIN t_messages msg ON msg.id = s.message_id
+				LEFT JOIN t_chat_participant p ON p.chat_id = msg.chat_id AND p.user_id = s.user_id
+				WHERE s.message_id IN ? AND s.deleted_at IS NULL
+				GROUP BY s.message_id
+			) agg
+			WHERE m.id = agg.message_id AND m.status IS DISTINCT FROM agg.status`,
+			enum.MessageStatusRead, enum.MessageStatusRead,
+			[]enum.MessageStatus{enum.MessageStatusDelivered, enum.MessageStatusRead}, enum.MessageStatusDelivered,
+			enum.MessageStatusSent, messageIDs).Error
+}
+
+// StatusSummary counts the receipts of a message by how far they got.
+type StatusSummary struct {
+	MessageID string
+	ChatID    string
+	SenderID  string
+	Status    enum.MessageStatus
+	Total     int64
+	Delivered int64 // includes read
+	Read      int64
+}
+
+func (repository MessageRepository) SummarizeStatus(ctx context.Context, db *gorm.DB, messageIDs []string) ([]StatusSummary, error) {
+	var summaries []StatusSummary
+	err := db.WithContext(ctx).
+		Table("t_messages m").
+		Select(`m.id AS message_id, m.chat_id, m.sender_id, m.status,
+			COUNT(s.id) AS total,
+			COUNT(s.id) FILTER (WHERE s.status IN ? OR p.last_read_seq >= m.seq) AS delivered,
+			COUNT(s.id) FILTER (WHERE s.status = ? OR p.last_read_seq >= m.seq) AS read`,
+			[]enum.MessageStatus{enum.MessageStatusDelivered, enum.MessageStatusRead}, enum.MessageStatusRead).
+		Joins("LEFT JOIN t_message_status s ON s.message_id = m.id AND s.deleted_at IS NULL").
+		Joins("LEFT JOIN t_chat_participant p ON p.chat_id = m.chat_id AND p.user_id = s.user_id").
+		Where("m.id IN ?", messageIDs).
+		Group("m.id").
+		Scan(&summaries).Error
+	return summaries, err
+}
+
+// FindIDsInSeqRange returns the messages of a chat with afterSeq < seq <= uptoSeq
+// that someone other than userID sent.
+func (repository MessageRepository) FindIDsInSeqRange(ctx context.Context, db *gorm.DB, chatID, userID string, afterSeq, uptoSeq int64) ([]string, error) {
+	var ids []string
+	err := db.WithContext(ctx).
+		Model(&entity.Messages{}).
+		Where("chat_id = ? AND sender_id <> ? AND seq > ? AND seq <= ?", chatID, userID, afterSeq, uptoSeq).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (repository MessageRepository) FindLatestInChat(ctx context.Context, db *gorm.DB, chatID string) (*entity.Messages, error) {
+	var message entity.Messages
+	err := db.WithContext(ctx).
+		Where("chat_id = ?", chatID).
+		Order("seq DESC").
+		First(&message).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (repository MessageRepository) FindReceipts(ctx context.Context, db *gorm.DB, messageID string) ([]entity.MessageStatus, error) {
+	var receipts []entity.MessageStatus
+	err := db.WithContext(ctx).
+		Where("message_id = ?", messageID).
+		Order("created_at ASC").
+		Find(&receipts).Error
+	return receipts, err
+}
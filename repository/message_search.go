@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+// MessageSearchFilter narrows a full-text search to what the caller may see.
+type MessageSearchFilter struct {
+	UserID   string
+	Query    string
+	ChatID   string
+	SenderID string
+	From     *time.Time
+	To       *time.Time // exclusive
+	Cursor   *MessageCursor
+	Limit    int
+}
+
+type MessageSearchHit struct {
+	ID         string
+	ChatID     string
+	ChatType   string
+	GroupName  string
+	SenderID   string
+	SenderName string
+	Highlight  string
+	Seq        int64
+	CreatedAt  time.Time
+}
+
+// Highlight sentinels wrap the matches of MessageSearchHit.Highlight. Control
+// characters are stripped from the content first, so a message cannot forge
+// them, and the caller escapes the text before turning them into markup.
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
+const searchHeadlineOptions = "StartSel=\"" + HighlightStart + "\", StopSel=\"" + HighlightStop + "\", MaxWords=24, MinWords=8, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// SearchMessages matches messages of the user's chats against a web-style
+// query, newest first, using the search_vector column and its GIN index.
+func (repository ChatRepository) SearchMessages(ctx context.Context, db *gorm.DB, filter MessageSearchFilter) ([]MessageSearchHit, error) {
+	var hits []MessageSearchHit
+
+	query := db.WithContext(ctx).
+		Table("t_messages").
+		Select(`t_messages.id, t_messages.chat_id, c.chat_type, c.group_name, t_messages.sender_id, u.name AS sender_name,
+			ts_headline('simple', translate(t_messages.content, ?, ''), q.query, ?) AS highlight, t_messages.seq, t_messages.created_at`,
+			HighlightStart+HighlightStop, searchHeadlineOptions).
+		Joins("CROSS JOIN websearch_to_tsquery('simple', ?) AS q(query)", filter.Query).
+		Joins("JOIN t_chat_participant cp ON cp.chat_id = t_messages.chat_id AND cp.user_id = ?", filter.UserID).
+		Joins("JOIN t_chat c ON c.id = t_messages.chat_id AND c.deleted_at IS NULL").
+		Joins("LEFT JOIN t_user u ON u.id = t_messages.sender_id").
+		Where("t_messages.search_vector @@ q.query").
+		Where("t_messages.deleted_at IS NULL AND t_messages.is_deleted = false").
+		Scopes(VisibleTo(filter.UserID))
+
+	if filter.ChatID != "" {
+		query = query.Where("t_messages.chat_id = ?", filter.ChatID)
+	}
+	if filter.SenderID != "" {
+		query = query.Where("t_messages.sender_id = ?", filter.SenderID)
+	}
+	if filter.From != nil {
+		query = query.Where("t_messages.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("t_messages.created_at < ?", *filter.To)
+	}
+	if filter.Cursor != nil {
+		query = query.Where("(t_messages.created_at, t_messages.id) < (?, ?)", filter.Cursor.CreatedAt, filter.Cursor.ID)
+	}
+
+	err := query.
+		Order("t_messages.created_at DESC, t_messages.id DESC").
+		Limit(filter.Limit).
+		Scan(&hits).Error
+	return hits, err
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"real-time-chat-app/entity"
+)
+
+type ReactionRepository struct {
+	Repository[entity.MessageReaction]
+}
+
+func NewReactionRepository() *ReactionRepository {
+	return &ReactionRepository{}
+}
+
+// ReactionCount is the aggregate of one emoji on one message.
+type ReactionCount struct {
+	MessageID   string
+	Emoji       string
+	Count       int64
+	ReactedByMe bool
+}
+
+// Add stores the reaction and reports whether it was new.
+func (repository ReactionRepository) Add(ctx context.Context, db *gorm.DB, messageID, userID, emoji string) (bool, error) {
+	result := db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&entity.MessageReaction{MessageID: messageID, UserID: userID, Emoji: emoji})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Remove deletes the reaction for good so the unique index lets it be added again.
+func (repository ReactionRepository) Remove(ctx context.Context, db *gorm.DB, messageID, userID, emoji string) (bool, error) {
+	result := db.WithContext(ctx).
+		Unscoped().
+		Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&entity.MessageReaction{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (repository ReactionRepository) CountEmoji(ctx context.Context, db *gorm.DB, messageID, emoji string) (int64, error) {
+	var count int64
+	err := db.WithContext(ctx).
+		Model(&entity.MessageReaction{}).
+		Where("message_id = ? AND emoji = ?", messageID, emoji).
+		Count(&count).Error
+	return count, err
+}
+
+// CountByMessages aggregates the reactions of the given messages, emojis of a
+// message come in the order they were first used.
+func (repository ReactionRepository) CountByMessages(ctx context.Context, db *gorm.DB, messageIDs []string, userID string) ([]ReactionCount, error) {
+	var counts []ReactionCount
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+
+	err := db.WithContext(ctx).
+		Model(&entity.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted_by_me", userID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("MIN(created_at) ASC").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (repository ReactionRepository) DeleteByMessage(ctx context.Context, db *gorm.DB, messageID string) error {
+	return db.WithContext(ctx).
+		Unscoped().
+		Where("message_id = ?", messageID).
+		Delete(&entity.MessageReaction{}).Error
+}
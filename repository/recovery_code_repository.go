@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"real-time-chat-app/entity"
+	"time"
+)
+
+type RecoveryCodeRepository struct {
+	Repository[entity.RecoveryCode]
+}
+
+func NewRecoveryCodeRepository() *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{}
+}
+
+// Replace drops every code of the account and stores the new hashes.
+func (repository RecoveryCodeRepository) Replace(ctx context.Context, db *gorm.DB, accountID string, hashes []string) error {
+	if err := repository.DeleteByAccount(ctx, db, accountID); err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	codes := make([]entity.RecoveryCode, 0, len(hashes))
+	for _, hash := range hashes {
+		codes = append(codes, entity.RecoveryCode{AccountID: accountID, CodeHash: hash})
+	}
+	return repository.SaveAll(ctx, db, &codes)
+}
+
+// Consume marks an unused code as used and reports whether there was one.
+func (repository RecoveryCodeRepository) Consume(ctx context.Context, db *gorm.DB, accountID, codeHash string, usedAt time.Time) (bool, error) {
+	result := db.WithContext(ctx).
+		Model(&entity.RecoveryCode{}).
+		Where("account_id = ? AND code_hash = ? AND used_at IS NULL", accountID, codeHash).
+		Update("used_at", usedAt)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repository RecoveryCodeRepository) CountUnused(ctx context.Context, db *gorm.DB, accountID string) (int64, error) {
+	var count int64
+	err := db.WithContext(ctx).
+		Model(&entity.RecoveryCode{}).
+		Where("account_id = ? AND used_at IS NULL", accountID).
+		Count(&count).Error
+	return count, err
+}
+
+func (repository RecoveryCodeRepository) DeleteByAccount(ctx context.Context, db *gorm.DB, accountID string) error {
+	return db.WithContext(ctx).
+		Unscoped().
+		Where("account_id = ?", accountID).
+		Delete(&entity.RecoveryCode{}).Error
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"real-time-chat-app/entity"
+	"time"
+)
+
+type SessionRepository struct {
+	Repository[entity.AuthSession]
+}
+
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{}
+}
+
+func (repository SessionRepository) FindActiveByUser(ctx context.Context, db *gorm.DB, userID string) ([]entity.AuthSession, error) {
+	var sessions []entity.AuthSession
+	err := db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("last_used_at DESC, id DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (repository SessionRepository) FindActive(ctx context.Context, db *gorm.DB, userID, sessionID string) (*entity.AuthSession, error) {
+	var session entity.AuthSession
+	err := db.WithContext(ctx).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (repository SessionRepository) Touch(ctx context.Context, db *gorm.DB, sessionID, ip, userAgent string, usedAt time.Time) error {
+	updates := map[string]interface{}{"last_used_at": usedAt}
+	if ip != "" {
+		updates["ip"] = ip
+	}
+	if userAgent != "" {
+		updates["user_agent"] = userAgent
+	}
+	return db.WithContext(ctx).
+		Model(&entity.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(updates).Error
+}
+
+func (repository SessionRepository) Revoke(ctx context.Context, db *gorm.DB, sessionID string, revokedAt time.Time) error {
+	return db.WithContext(ctx).
+		Model(&entity.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", revokedAt).Error
+}
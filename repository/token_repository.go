@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"real-time-chat-app/entity"
+	"time"
+)
+
+type TokenRepository struct {
+	Repository[entity.RefreshToken]
+}
+
+func NewTokenRepository() *TokenRepository {
+	return &TokenRepository{}
+}
+
+func (repository TokenRepository) FindByHash(ctx context.Context, db *gorm.DB, tokenHash string) (*entity.RefreshToken, error) {
+	var token entity.RefreshToken
+	err := db.WithContext(ctx).
+		Where("token_hash = ?", tokenHash).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (repository TokenRepository) FindByAccessJTI(ctx context.Context, db *gorm.DB, userID, jti string) (*entity.RefreshToken, error) {
+	var token entity.RefreshToken
+	err := db.WithContext(ctx).
+		Where("user_id = ? AND access_jti = ?", userID, jti).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (repository TokenRepository) MarkUsed(ctx context.Context, db *gorm.DB, id, replacedByID string, usedAt time.Time) error {
+	return db.WithContext(ctx).
+		Model(&entity.RefreshToken{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"used_at":        usedAt,
+			"replaced_by_id": replacedByID,
+		}).Error
+}
+
+// RevokeFamily revokes every live token of a family and returns the access
+// tokens issued with them that have not expired yet.
+func (repository TokenRepository) RevokeFamily(ctx context.Context, db *gorm.DB, familyID string, revokedAt time.Time) ([]entity.RevokedToken, error) {
+	var issued []entity.RefreshToken
+	err := db.WithContext(ctx).
+		Raw(`UPDATE t_refresh_token SET revoked_at = ?
+			WHERE family_id = ? AND revoked_at IS NULL AND deleted_at IS NULL
+			RETURNING user_id, access_jti, access_expires_at`, revokedAt, familyID).
+		Scan(&issued).Error
+	if err != nil {
+		return nil, err
+	}
+
+	denied := make([]entity.RevokedToken, 0, len(issued))
+	for _, token := range issued {
+		if token.AccessJTI == "" || !token.AccessExpiresAt.After(revokedAt) {
+			continue
+		}
+		denied = append(denied, entity.RevokedToken{
+			JTI:       token.AccessJTI,
+			UserID:    token.UserID,
+			ExpiresAt: token.AccessExpiresAt,
+		})
+	}
+	return denied, nil
+}
+
+func (repository TokenRepository) Deny(ctx context.Context, db *gorm.DB, tokens []entity.RevokedToken) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	return db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "jti"}}, DoNothing: true}).
+		Create(&tokens).Error
+}
+
+func (repository TokenRepository) IsDenied(ctx context.Context, db *gorm.DB, jti string) (bool, error) {
+	var count int64
+	err := db.WithContext(ctx).
+		Model(&entity.RevokedToken{}).
+		Where("jti = ? AND expires_at > ?", jti, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// PurgeExpired drops denylist entries and refresh tokens that can no longer be presented.
+func (repository TokenRepository) PurgeExpired(ctx context.Context, db *gorm.DB, now time.Time) error {
+	if err := db.WithContext(ctx).Unscoped().Where("expires_at < ?", now).Delete(&entity.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Unscoped().Where("expires_at < ?", now).Delete(&entity.RefreshToken{}).Error
+}
@@ -1,6 +1,11 @@
 package repository
 
-import "real-time-chat-app/entity"
+import (
+	"context"
+	"gorm.io/gorm"
+	"real-time-chat-app/entity"
+	"time"
+)
 
 type UserRepository struct {
 	Repository[entity.User]
@@ -9,3 +14,10 @@ type UserRepository struct {
 func NewUserRepository() *UserRepository {
 	return &UserRepository{}
 }
+
+func (repository UserRepository) UpdateLastSeen(ctx context.Context, db *gorm.DB, userID string, seenAt time.Time) error {
+	return db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ? AND (last_seen_at IS NULL OR last_seen_at < ?)", userID, seenAt).
+		Update("last_seen_at", seenAt).Error
+}
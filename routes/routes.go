@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"real-time-chat-app/dto/ws"
 	"real-time-chat-app/handler"
 	"real-time-chat-app/middleware"
 )
@@ -13,6 +14,7 @@ type ConfigRoute struct {
 	*handler.AuthHandler
 	*handler.UserHandler
 	*handler.ChatHandler
+	*handler.KeyHandler
 }
 
 func (rc *ConfigRoute) GetRoute() {
@@ -24,6 +26,11 @@ func (rc *ConfigRoute) GetPublicRoute() {
 	app := rc.App.Group("/api/v1")
 	app.Post("/auth/register", rc.AuthHandler.RegisterUser)
 	app.Post("/auth/login", rc.AuthHandler.LoginUser)
+	app.Post("/auth/login/2fa", rc.AuthHandler.VerifyTwoFactorLogin)
+	app.Post("/auth/refresh", rc.AuthHandler.RefreshToken)
+
+	// verification keys of our access tokens for other services
+	rc.App.Get("/.well-known/jwks.json", rc.KeyHandler.GetJWKS)
 }
 
 func (rc *ConfigRoute) GetProtectedRoute() {
@@ -31,25 +38,60 @@ func (rc *ConfigRoute) GetProtectedRoute() {
 	app.Use(rc.Middleware.JWTProtected)
 
 	app.Get("/auth/me", rc.UserHandler.GetUserByToken)
+	app.Post("/auth/logout", rc.AuthHandler.Logout)
+	app.Get("/auth/sessions", rc.AuthHandler.GetSessions)
+	app.Delete("/auth/sessions/:id", rc.AuthHandler.RevokeSession)
+	app.Post("/auth/2fa/setup", rc.AuthHandler.SetupTwoFactor)
+	app.Post("/auth/2fa/confirm", rc.AuthHandler.ConfirmTwoFactor)
+	app.Post("/auth/2fa/disable", rc.AuthHandler.DisableTwoFactor)
+	app.Post("/auth/2fa/recovery-codes", rc.AuthHandler.RegenerateRecoveryCodes)
 
 	// users endpoint
 	app.Get("/users", rc.UserHandler.GetAllUsers)
 	app.Put("/users/profile/:userId", rc.UserHandler.EditUser)
+	app.Get("/users/:id/presence", rc.UserHandler.GetPresence)
 
 	//chat endpoint
 	app.Get("/chats/:chatId/messages", rc.ChatHandler.GetMessagesByID)
+	app.Patch("/chats/:chatId/messages/:messageId", rc.ChatHandler.EditMessage)
+	app.Delete("/chats/:chatId/messages/:messageId", rc.ChatHandler.DeleteMessage)
+	app.Get("/chats/:chatId/messages/:messageId/edits", rc.ChatHandler.GetMessageEdits)
+	app.Get("/chats/:chatId/messages/:messageId/replies", rc.ChatHandler.GetReplies)
+	app.Get("/chats/:chatId/messages/:messageId/receipts", rc.ChatHandler.GetMessageReceipts)
+	app.Get("/chats/:chatId/messages/:messageId/seen", rc.ChatHandler.GetSeenBy)
+	app.Post("/chats/:chatId/messages/:messageId/reactions", rc.ChatHandler.AddReaction)
+	app.Delete("/chats/:chatId/messages/:messageId/reactions/:emoji", rc.ChatHandler.RemoveReaction)
 	app.Put("/chats/:chatId/read", rc.ChatHandler.MarkMessagesAsRead)
+	app.Post("/chats/:chatId/attachments", rc.ChatHandler.UploadAttachment)
+	app.Get("/chats/:chatId/attachments/:attachmentId", rc.ChatHandler.DownloadAttachment)
 	app.Get("/chats", rc.ChatHandler.GetAllChat)
+
+	// search endpoint
+	app.Get("/search/messages", rc.ChatHandler.SearchMessages)
+
+	// group chat endpoint
+	app.Post("/chats/groups", rc.ChatHandler.CreateGroup)
+	app.Patch("/chats/:chatId", rc.ChatHandler.UpdateGroup)
+	app.Delete("/chats/:chatId", rc.ChatHandler.DeleteGroup)
+	app.Get("/chats/:chatId/participants", rc.ChatHandler.GetParticipants)
+	app.Post("/chats/:chatId/participants", rc.ChatHandler.AddParticipants)
+	app.Delete("/chats/:chatId/participants", rc.ChatHandler.RemoveParticipants)
+	app.Patch("/chats/:chatId/participants/:userId", rc.ChatHandler.UpdateParticipantRole)
+	app.Post("/chats/:chatId/owner", rc.ChatHandler.TransferOwnership)
+	app.Post("/chats/:chatId/leave", rc.ChatHandler.LeaveGroup)
 }
 
 func (rc *ConfigRoute) GetWebSocketRoute(wsHandler *handler.WebSocketHandler) {
-	rc.App.Use("/ws", func(c *fiber.Ctx) error {
-		if websocket.IsWebSocketUpgrade(c) {
-			c.Locals("allowed", true)
-			return c.Next()
-		}
-		return fiber.ErrUpgradeRequired
-	})
-
-	rc.App.Get("/ws", websocket.New(wsHandler.HandleWebSocket))
+	rc.App.Use("/ws", rc.Middleware.WebSocketUpgrade)
+
+	rc.App.Get("/ws", websocket.New(wsHandler.HandleWebSocket, websocket.Config{
+		// preferred first, access_token only carries the JWT and falls back to the legacy protocol
+		Subprotocols: []string{ws.ProtocolV2, ws.ProtocolV1, middleware.WSTokenSubprotocol},
+	}))
+}
+
+// GetInternalRoute registers the operator endpoints, app listens apart from the
+// public port so they are not reachable by users.
+func GetInternalRoute(app *fiber.App, wsHandler *handler.WebSocketHandler) {
+	app.Get("/ws/metrics", wsHandler.GetMetrics)
 }
@@ -1,50 +1,205 @@
 package security
 
 import (
+	"context"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"real-time-chat-app/config/common"
 	"real-time-chat-app/entity"
 	"time"
 )
 
+// ErrTokenRevoked is returned for access tokens whose jti was denylisted.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// challengeTokenType marks the token handed out between the password and the
+// second factor, it is refused wherever an access token is expected.
+const (
+	challengeTokenType = "2fa_challenge"
+	challengeAudience  = "real-time-chat-app/2fa"
+)
+
+// Denylist tells whether an access token was revoked before it expired.
+type Denylist interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// JWT signs with the key set when one is configured and with JWT_SECRET otherwise.
 type JWT struct {
-	config *common.Config
+	config   *common.Config
+	keys     *KeySet
+	denylist Denylist
+}
+
+// AccessToken is a signed token together with the claims needed to revoke it.
+type AccessToken struct {
+	Token     string
+	JTI       string
+	SessionID string
+	ExpiresAt time.Time
+}
+
+func NewJWT(config *common.Config, keys *KeySet) *JWT {
+	return &JWT{config: config, keys: keys}
 }
 
-func NewJWT(config *common.Config) *JWT {
-	return &JWT{config: config}
+// UseDenylist enables revocation checks, tokens are only verified by signature without one.
+func (j *JWT) UseDenylist(denylist Denylist) {
+	j.denylist = denylist
 }
 
-func (j *JWT) GenerateToken(user *entity.User) (string, error) {
-	secretKey := j.config.GetJwtConfig()
+// GenerateToken signs an access token bound to the login session sessionID.
+func (j *JWT) GenerateToken(user *entity.User, sessionID string) (AccessToken, error) {
+	now := time.Now()
+	expiresAt := now.Add(j.config.GetTokenConfig().AccessTTL)
+	jti := uuid.New().String()
 
 	claims := jwt.MapClaims{
 		"user_id": user.ID,
+		"jti":     jti,
+		"sid":     sessionID,
 		"aud":     "real-time-chat-app",
 		"iss":     "real-time-chat-app",
-		"iat":     time.Now().Unix(),
-		"exp":     time.Now().Add(time.Hour * 1).Unix(),
+		"iat":     now.Unix(),
+		"exp":     expiresAt.Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	return token.SignedString(secretKey)
+	signed, err := j.sign(claims)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	return AccessToken{Token: signed, JTI: jti, SessionID: sessionID, ExpiresAt: expiresAt}, nil
 }
 
-func (j *JWT) VerifyJwtToken(token string) (jwt.MapClaims, error) {
-	secretKey := j.config.GetJwtConfig()
+// GenerateChallengeToken signs the short lived token that carries a login from
+// the password step to the second factor.
+func (j *JWT) GenerateChallengeToken(accountID string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	claims := jwt.MapClaims{
+		"account_id": accountID,
+		"typ":        challengeTokenType,
+		"jti":        uuid.New().String(),
+		"aud":        challengeAudience,
+		"iss":        "real-time-chat-app",
+		"iat":        now.Unix(),
+		"exp":        expiresAt.Unix(),
+	}
+
+	signed, err := j.sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// VerifyChallengeToken returns the account a challenge token was issued for.
+func (j *JWT) VerifyChallengeToken(token string) (string, error) {
+	parsed, err := jwt.Parse(token, j.Keyfunc, jwt.WithAudience(challengeAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid || !j.IsChallengeToken(claims) {
+		return "", jwt.ErrTokenInvalidClaims
+	}
+
+	accountID, _ := claims["account_id"].(string)
+	if accountID == "" {
+		return "", jwt.ErrTokenInvalidClaims
+	}
+	return accountID, nil
+}
+
+func (j *JWT) IsChallengeToken(claims jwt.MapClaims) bool {
+	typ, _ := claims["typ"].(string)
+	return typ == challengeTokenType
+}
+
+func (j *JWT) sign(claims jwt.MapClaims) (string, error) {
+	if j.keys == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString(j.config.GetJwtConfig())
+	}
+
+	key := j.keys.Signing()
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.private)
+}
 
-	tokenParse, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+// Keyfunc resolves the verification key of a token from its kid header. Tokens
+// without a kid were signed with JWT_SECRET and keep working while it is set.
+func (j *JWT) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		secretKey := j.config.GetJwtConfig()
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, jwt.ErrSignatureInvalid
 		}
+		if j.keys != nil && len(secretKey) == 0 {
+			return nil, ErrUnknownKey
+		}
 		return secretKey, nil
-	})
+	}
+
+	if j.keys == nil {
+		return nil, ErrUnknownKey
+	}
+	key, ok := j.keys.Lookup(kid)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return key.Public, nil
+}
+
+// JWKS publishes the verification keys, empty while tokens are signed with JWT_SECRET.
+func (j *JWT) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if j.keys == nil {
+		return set
+	}
+	for _, key := range j.keys.Keys() {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
+
+// CheckRevoked fails with ErrTokenRevoked when the claims belong to a revoked
+// token. Tokens issued before jti existed cannot be revoked individually.
+func (j *JWT) CheckRevoked(ctx context.Context, claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	if j.denylist == nil || jti == "" {
+		return nil
+	}
+
+	revoked, err := j.denylist.IsRevoked(ctx, jti)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+func (j *JWT) VerifyJwtToken(token string) (jwt.MapClaims, error) {
+	tokenParse, err := jwt.Parse(token, j.Keyfunc)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := tokenParse.Claims.(jwt.MapClaims); ok && tokenParse.Valid {
+		if j.IsChallengeToken(claims) {
+			return nil, jwt.ErrTokenInvalidClaims
+		}
 		return claims, nil
 	}
 
@@ -57,6 +212,11 @@ func (j *JWT) GetUserIdFromToken(token string) (string, error) {
 		return "", err
 	}
 
+	return UserIdFromClaims(claims)
+}
+
+// UserIdFromClaims reads the user of already verified claims.
+func UserIdFromClaims(claims jwt.MapClaims) (string, error) {
 	userID, ok := claims["user_id"].(string)
 
 	if !ok {
@@ -65,3 +225,23 @@ func (j *JWT) GetUserIdFromToken(token string) (string, error) {
 
 	return userID, nil
 }
+
+// SessionIdFromClaims returns the login session of verified claims, empty for
+// tokens issued before sessions were recorded.
+func SessionIdFromClaims(claims jwt.MapClaims) string {
+	sessionID, _ := claims["sid"].(string)
+	return sessionID
+}
+
+func ExpirationFromClaims(claims jwt.MapClaims) (time.Time, error) {
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if expiresAt == nil {
+		return time.Time{}, jwt.ErrTokenInvalidClaims
+	}
+
+	return expiresAt.Time, nil
+}
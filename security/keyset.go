@@ -0,0 +1,369 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"math/big"
+	"os"
+	"path/filepath"
+	"real-time-chat-app/config/common"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+
+	keyFileExt    = ".pem"
+	minRSAKeyBits = 2048
+)
+
+// ErrUnknownKey is returned for tokens whose kid is not in the key set.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// SigningKey is one key of the set. Keys without a private half, loaded from a
+// PUBLIC KEY block, only verify.
+type SigningKey struct {
+	KID       string
+	Algorithm string
+	Public    crypto.PublicKey
+	ActiveAt  time.Time
+
+	private crypto.Signer
+	path    string
+}
+
+func (key *SigningKey) method() jwt.SigningMethod {
+	if key.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// KeySet holds the asymmetric keys of KeyDir, one file per key named <kid>.pem.
+// A key becomes active ActivationDelay after its file was written, the newest
+// active key signs while every key of the set verifies.
+type KeySet struct {
+	config  common.JwtKeyConfig
+	mutex   sync.RWMutex
+	keys    map[string]*SigningKey
+	signing *SigningKey
+}
+
+func NewKeySet(config common.JwtKeyConfig) (*KeySet, error) {
+	if err := os.MkdirAll(config.KeyDir, 0700); err != nil {
+		return nil, err
+	}
+
+	keySet := &KeySet{config: config}
+	if err := keySet.Rotate(time.Now()); err != nil {
+		return nil, err
+	}
+	return keySet, nil
+}
+
+// Signing returns the key new tokens are signed with.
+func (ks *KeySet) Signing() *SigningKey {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+	return ks.signing
+}
+
+func (ks *KeySet) Lookup(kid string) (*SigningKey, bool) {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// Keys returns every verification key ordered by activation.
+func (ks *KeySet) Keys() []*SigningKey {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+	return sortedKeys(ks.keys)
+}
+
+// Rotate reloads KeyDir. When rotation is enabled it also generates a key once
+// the newest one is RotationInterval old, and deletes private keys whose
+// successor has been signing for longer than Retention. A directory without a
+// private key always gets one generated.
+func (ks *KeySet) Rotate(now time.Time) error {
+	keys, err := ks.load()
+	if err != nil {
+		return err
+	}
+
+	if ks.rotationDue(keys, now) {
+		key, err := ks.generate()
+		if err != nil {
+			return err
+		}
+		keys[key.KID] = key
+	}
+
+	ordered := sortedKeys(keys)
+	signing := selectSigning(ordered, now)
+	if signing == nil {
+		return fmt.Errorf("no private key in %s", ks.config.KeyDir)
+	}
+
+	if ks.config.RotationInterval > 0 {
+		// verify-only keys are provisioned by hand, they neither sign nor get deleted
+		private := make([]*SigningKey, 0, len(ordered))
+		for _, key := range ordered {
+			if key.private != nil {
+				private = append(private, key)
+			}
+		}
+		for i := 0; i < len(private)-1; i++ {
+			key, successor := private[i], private[i+1]
+			if key == signing || now.Sub(successor.ActiveAt) <= ks.config.Retention || successor.ActiveAt.After(signing.ActiveAt) {
+				continue
+			}
+			if err := os.Remove(key.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			delete(keys, key.KID)
+		}
+	}
+
+	ks.mutex.Lock()
+	ks.keys = keys
+	ks.signing = signing
+	ks.mutex.Unlock()
+
+	return nil
+}
+
+func (ks *KeySet) rotationDue(keys map[string]*SigningKey, now time.Time) bool {
+	var newest *SigningKey
+	for _, key := range keys {
+		if key.private != nil && (newest == nil || key.ActiveAt.After(newest.ActiveAt)) {
+			newest = key
+		}
+	}
+	if newest == nil {
+		return true
+	}
+	if ks.config.RotationInterval <= 0 {
+		return false
+	}
+	return now.Sub(newest.ActiveAt.Add(-ks.config.ActivationDelay)) >= ks.config.RotationInterval
+}
+
+func (ks *KeySet) load() (map[string]*SigningKey, error) {
+	entries, err := os.ReadDir(ks.config.KeyDir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*SigningKey, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != keyFileExt {
+			continue
+		}
+
+		path := filepath.Join(ks.config.KeyDir, entry.Name())
+		key, err := readKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		key.KID = strings.TrimSuffix(entry.Name(), keyFileExt)
+		key.ActiveAt = info.ModTime().Add(ks.config.ActivationDelay)
+		keys[key.KID] = key
+	}
+	return keys, nil
+}
+
+// generate writes a new private key of the configured algorithm, through a
+// temporary file so other nodes never read a partial key.
+func (ks *KeySet) generate() (*SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch ks.config.Algorithm {
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+	default:
+		err = fmt.Errorf("cannot generate %s keys", ks.config.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := uuid.New().String()
+	path := filepath.Join(ks.config.KeyDir, kid+keyFileExt)
+	tmp, err := os.CreateTemp(ks.config.KeyDir, kid+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := pem.Encode(tmp, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		KID:       kid,
+		Algorithm: ks.config.Algorithm,
+		Public:    private.Public(),
+		ActiveAt:  info.ModTime().Add(ks.config.ActivationDelay),
+		private:   private,
+		path:      path,
+	}, nil
+}
+
+func readKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key := &SigningKey{path: path}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key")
+		}
+		key.private = signer
+		key.Public = signer.Public()
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.private = parsed
+		key.Public = parsed.Public()
+	case "PUBLIC KEY":
+		key.Public, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	switch public := key.Public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key shorter than %d bits", minRSAKeyBits)
+		}
+		key.Algorithm = AlgorithmRS256
+	case ed25519.PublicKey:
+		key.Algorithm = AlgorithmEdDSA
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+	return key, nil
+}
+
+// selectSigning picks the newest active private key, before any is active the
+// oldest one signs so a fresh deployment can issue tokens right away.
+func selectSigning(ordered []*SigningKey, now time.Time) *SigningKey {
+	var signing, oldest *SigningKey
+	for _, key := range ordered {
+		if key.private == nil {
+			continue
+		}
+		if oldest == nil {
+			oldest = key
+		}
+		if !key.ActiveAt.After(now) {
+			signing = key
+		}
+	}
+	if signing == nil {
+		return oldest
+	}
+	return signing
+}
+
+func sortedKeys(keys map[string]*SigningKey) []*SigningKey {
+	ordered := make([]*SigningKey, 0, len(keys))
+	for _, key := range keys {
+		ordered = append(ordered, key)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].ActiveAt.Equal(ordered[j].ActiveAt) {
+			return ordered[i].KID < ordered[j].KID
+		}
+		return ordered[i].ActiveAt.Before(ordered[j].ActiveAt)
+	})
+	return ordered
+}
+
+// JWK is the public half of a key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (key *SigningKey) JWK() JWK {
+	jwk := JWK{Kid: key.KID, Use: "sig", Alg: key.Algorithm}
+	switch public := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
@@ -0,0 +1,36 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// 80 random bits, too many to guess, so a plain hash is enough to store them
+const recoveryCodeBytes = 10
+
+// NewRecoveryCode returns a one-time code for the user, grouped as
+// xxxx-xxxx-xxxx-xxxx, and the hash to store.
+func NewRecoveryCode() (code, hash string, err error) {
+	buf := make([]byte, recoveryCodeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	raw := strings.ToLower(totpEncoding.EncodeToString(buf))
+	groups := make([]string, 0, len(raw)/4)
+	for i := 0; i < len(raw); i += 4 {
+		groups = append(groups, raw[i:i+4])
+	}
+
+	code = strings.Join(groups, "-")
+	return code, HashRecoveryCode(code), nil
+}
+
+// HashRecoveryCode ignores case, dashes and spaces so codes typed from paper still match.
+func HashRecoveryCode(code string) string {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,60 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890".
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// The appendix lists 8 digit codes, these are their last 6 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestVerifyTOTPMatchesRFC6238Vectors(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		now := time.Unix(vector.unix, 0)
+		step, ok := VerifyTOTP(rfc6238Secret, vector.code, now, 0)
+		if !ok {
+			t.Errorf("code %s at %d was refused", vector.code, vector.unix)
+			continue
+		}
+		if want := vector.unix / totpPeriod; step != want {
+			t.Errorf("code %s at %d matched step %d, want %d", vector.code, vector.unix, step, want)
+		}
+	}
+}
+
+func TestVerifyTOTPRefusesReplay(t *testing.T) {
+	vector := rfc6238Vectors[1]
+	now := time.Unix(vector.unix, 0)
+
+	step, ok := VerifyTOTP(rfc6238Secret, vector.code, now, 0)
+	if !ok {
+		t.Fatalf("code %s at %d was refused", vector.code, vector.unix)
+	}
+
+	if _, ok := VerifyTOTP(rfc6238Secret, vector.code, now, step); ok {
+		t.Errorf("code %s was accepted again at step %d", vector.code, step)
+	}
+	if _, ok := VerifyTOTP(rfc6238Secret, vector.code, now, step+1); ok {
+		t.Errorf("code %s was accepted after a later step was used", vector.code)
+	}
+}
+
+func TestVerifyTOTPRefusesWrongCode(t *testing.T) {
+	vector := rfc6238Vectors[0]
+	if _, ok := VerifyTOTP(rfc6238Secret, "000000", time.Unix(vector.unix, 0), 0); ok {
+		t.Error("a wrong code was accepted")
+	}
+}
@@ -0,0 +1,22 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"real-time-chat-app/dto"
+	"real-time-chat-app/enum"
+)
+
+type AttachmentUsecase interface {
+	UploadAttachment(ctx context.Context, userID, chatID, fileName string, file io.ReadSeeker) (dto.Attachment, error)
+	OpenAttachment(ctx context.Context, userID, chatID, attachmentID string, variant enum.MediaVariant) (AttachmentContent, error)
+}
+
+// AttachmentContent is a readable attachment or one of its image variants.
+type AttachmentContent struct {
+	FileName string
+	MimeType string
+	Size     int64 // -1 when unknown
+	ETag     string
+	Body     io.ReadCloser
+}
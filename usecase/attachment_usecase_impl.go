@@ -0,0 +1,356 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"real-time-chat-app/config/common"
+	"real-time-chat-app/config/logger"
+	"real-time-chat-app/dto"
+	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
+	"real-time-chat-app/repository"
+	"real-time-chat-app/storage"
+	"strings"
+	"time"
+)
+
+const (
+	maxFileNameLength = 255
+	sweepBatchSize    = 100
+)
+
+type attachmentUsecase struct {
+	db                   *gorm.DB
+	chatRepository       *repository.ChatRepository
+	attachmentRepository *repository.AttachmentRepository
+	store                storage.BlobStore
+	mediaProcessor       *MediaProcessor
+	config               common.AttachmentConfig
+	log                  *logger.AppLogger
+}
+
+func NewAttachmentUsecase(db *gorm.DB, chatRepository *repository.ChatRepository, attachmentRepository *repository.AttachmentRepository, store storage.BlobStore, mediaProcessor *MediaProcessor, config common.AttachmentConfig, logger *logger.AppLogger) AttachmentUsecase {
+	logger.Http.Info.Info().Msg("Attachment usecase initialized")
+	uc := &attachmentUsecase{
+		db:                   db,
+		chatRepository:       chatRepository,
+		attachmentRepository: attachmentRepository,
+		store:                store,
+		mediaProcessor:       mediaProcessor,
+		config:               config,
+		log:                  logger,
+	}
+	go uc.sweepUnsent()
+	return uc
+}
+
+func (uc *attachmentUsecase) UploadAttachment(ctx context.Context, userID, chatID, fileName string, file io.ReadSeeker) (dto.Attachment, error) {
+	uc.log.Http.Info.Info().
+		Str("userId", userID).
+		Str("chatId", chatID).
+		Str("fileName", fileName).
+		Msg("UploadAttachment started")
+
+	if err := uc.requireParticipant(ctx, chatID, userID); err != nil {
+		return dto.Attachment{}, err
+	}
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return dto.Attachment{}, err
+	}
+	if size == 0 {
+		return dto.Attachment{}, fmt.Errorf("%w: file is empty", ErrInvalidRequest)
+	}
+	if size > uc.config.MaxSize {
+		uc.log.Http.Warning.Warn().
+			Int64("size", size).
+			Int64("maxSize", uc.config.MaxSize).
+			Msg("Upload rejected: file too large")
+		return dto.Attachment{}, ErrAttachmentTooLarge
+	}
+
+	// trust the content, not the name or the declared type
+	mimeType, err := sniffMimeType(file)
+	if err != nil {
+		return dto.Attachment{}, err
+	}
+	if !uc.allowed(mimeType) {
+		uc.log.Http.Warning.Warn().
+			Str("mimeType", mimeType).
+			Msg("Upload rejected: type not allowed")
+		return dto.Attachment{}, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mimeType)
+	}
+
+	attachment := entity.Attachment{
+		ChatID:     chatID,
+		UploaderID: userID,
+		FileName:   cleanFileName(fileName),
+		MimeType:   mimeType,
+		Size:       size,
+	}
+	attachment.ID = uuid.New().String()
+	attachment.StorageKey = chatID + "/" + attachment.ID
+
+	if strings.HasPrefix(mimeType, "image/") {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return dto.Attachment{}, err
+		}
+		// formats without a registered decoder simply have no dimensions
+		if cfg, _, err := image.DecodeConfig(file); err == nil {
+			attachment.Width, attachment.Height = cfg.Width, cfg.Height
+			attachment.MediaStatus = enum.MediaStatusProcessing
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return dto.Attachment{}, err
+	}
+	hash := sha256.New()
+	if err := uc.store.Put(ctx, attachment.StorageKey, io.TeeReader(file, hash)); err != nil {
+		uc.log.Http.Error.Error().
+			Err(err).
+			Str("key", attachment.StorageKey).
+			Msg("Failed to store attachment")
+		return dto.Attachment{}, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	if err := uc.attachmentRepository.Save(ctx, uc.db, &attachment); err != nil {
+		uc.log.Http.Error.Error().
+			Err(err).
+			Str("attachmentId", attachment.ID).
+			Msg("Failed to save attachment")
+		if err := uc.store.Delete(context.Background(), attachment.StorageKey); err != nil {
+			uc.log.Http.Warning.Warn().
+				Err(err).
+				Str("key", attachment.StorageKey).
+				Msg("Failed to remove orphaned attachment blob")
+		}
+		return dto.Attachment{}, fmt.Errorf("failed to save attachment: %w", err)
+	}
+
+	if attachment.MediaStatus == enum.MediaStatusProcessing && !uc.mediaProcessor.Enqueue(attachment.ID) {
+		attachment.MediaStatus = enum.MediaStatusFailed
+		if err := uc.attachmentRepository.UpdateMedia(ctx, uc.db, &attachment); err != nil {
+			uc.log.Http.Error.Error().
+				Err(err).
+				Str("attachmentId", attachment.ID).
+				Msg("Failed to mark image as unprocessed")
+		}
+	}
+
+	uc.log.Http.Info.Info().
+		Str("attachmentId", attachment.ID).
+		Str("chatId", chatID).
+		Str("mimeType", mimeType).
+		Int64("size", size).
+		Msg("Attachment uploaded successfully")
+
+	return attachmentOf(attachment), nil
+}
+
+func (uc *attachmentUsecase) OpenAttachment(ctx context.Context, userID, chatID, attachmentID string, variant enum.MediaVariant) (AttachmentContent, error) {
+	uc.log.Http.Info.Info().
+		Str("userId", userID).
+		Str("chatId", chatID).
+		Str("attachmentId", attachmentID).
+		Str("variant", string(variant)).
+		Msg("OpenAttachment started")
+
+	if err := uc.requireParticipant(ctx, chatID, userID); err != nil {
+		return AttachmentContent{}, err
+	}
+
+	attachment, err := uc.attachmentRepository.FindInChat(ctx, uc.db, chatID, attachmentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return AttachmentContent{}, ErrAttachmentNotFound
+		}
+		uc.log.Http.Error.Error().
+			Err(err).
+			Str("attachmentId", attachmentID).
+			Msg("Failed to find attachment")
+		return AttachmentContent{}, err
+	}
+
+	// until a message carries it, an upload is only visible to its uploader
+	if attachment.MessageID == nil && attachment.UploaderID != userID {
+		return AttachmentContent{}, ErrAttachmentNotFound
+	}
+
+	content := AttachmentContent{
+		FileName: attachment.FileName,
+		MimeType: attachment.MimeType,
+		Size:     attachment.Size,
+		ETag:     attachment.Checksum,
+	}
+	key := attachment.StorageKey
+
+	switch variant {
+	case enum.MediaVariantOriginal:
+	case enum.MediaVariantThumbnail, enum.MediaVariantPreview:
+		if attachment.MediaStatus != enum.MediaStatusReady {
+			return AttachmentContent{}, fmt.Errorf("%w: image %s is not available", ErrAttachmentNotFound, variant)
+		}
+		key = attachment.ThumbnailKey
+		if variant == enum.MediaVariantPreview {
+			key = attachment.PreviewKey
+		}
+		content.FileName = strings.TrimSuffix(attachment.FileName, filepath.Ext(attachment.FileName)) + "_" + string(variant) + ".jpg"
+		content.MimeType = "image/jpeg"
+		content.Size = -1
+		content.ETag = attachment.Checksum + "-" + string(variant)
+	default:
+		return AttachmentContent{}, fmt.Errorf("%w: unknown variant %q", ErrInvalidRequest, variant)
+	}
+
+	content.Body, err = uc.store.Open(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			uc.log.Http.Error.Error().
+				Str("attachmentId", attachmentID).
+				Str("key", key).
+				Msg("Attachment blob is missing")
+			return AttachmentContent{}, ErrAttachmentNotFound
+		}
+		return AttachmentContent{}, err
+	}
+
+	return content, nil
+}
+
+// sweepUnsent deletes uploads that were never sent, together with their blobs.
+func (uc *attachmentUsecase) sweepUnsent() {
+	ticker := time.NewTicker(uc.config.SweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+		stale, err := uc.attachmentRepository.FindStaleUnsent(ctx, uc.db, time.Now().Add(-uc.config.UnsentTTL), sweepBatchSize)
+		if err != nil {
+			uc.log.Http.Error.Error().
+				Err(err).
+				Msg("Failed to find unsent attachments")
+			continue
+		}
+
+		swept := 0
+		for _, attachment := range stale {
+			// a message may claim the upload between the lookup and the delete
+			deleted, err := uc.attachmentRepository.DeleteUnsent(ctx, uc.db, attachment.ID)
+			if err != nil {
+				uc.log.Http.Error.Error().
+					Err(err).
+					Str("attachmentId", attachment.ID).
+					Msg("Failed to delete unsent attachment")
+				continue
+			}
+			if deleted {
+				deleteAttachmentBlobs(ctx, uc.store, uc.log, attachment)
+				swept++
+			}
+		}
+
+		if swept > 0 {
+			uc.log.Http.Info.Info().
+				Int("sweptCount", swept).
+				Msg("Unsent attachments swept")
+		}
+	}
+}
+
+func (uc *attachmentUsecase) allowed(mimeType string) bool {
+	for _, allowed := range uc.config.AllowedTypes {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == mimeType {
+			return true
+		}
+		if family, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mimeType, family+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (uc *attachmentUsecase) requireParticipant(ctx context.Context, chatID, userID string) error {
+	isParticipant, err := uc.chatRepository.IsUserInChat(ctx, uc.db, chatID, userID)
+	if err != nil {
+		uc.log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Str("userId", userID).
+			Msg("Failed to verify participant")
+		return err
+	}
+	if !isParticipant {
+		uc.log.Http.Warning.Warn().
+			Str("chatId", chatID).
+			Str("userId", userID).
+			Msg("User not authorized for this chat")
+		return ErrNotParticipant
+	}
+	return nil
+}
+
+func sniffMimeType(file io.ReadSeeker) (string, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(header[:n]))
+	if err != nil {
+		return "application/octet-stream", nil
+	}
+	return mediaType, nil
+}
+
+func cleanFileName(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+
+	if runes := []rune(name); len(runes) > maxFileNameLength {
+		name = string(runes[:maxFileNameLength])
+	}
+	return name
+}
+
+// deleteAttachmentBlobs removes the original and every image variant. Failures
+// are only logged, the rows are gone already and nothing serves the blobs.
+func deleteAttachmentBlobs(ctx context.Context, store storage.BlobStore, log *logger.AppLogger, attachment entity.Attachment) {
+	keys := []string{
+		attachment.StorageKey,
+		attachment.StorageKey + "_" + string(enum.MediaVariantThumbnail),
+		attachment.StorageKey + "_" + string(enum.MediaVariantPreview),
+	}
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Http.Warning.Warn().
+				Err(err).
+				Str("attachmentId", attachment.ID).
+				Str("key", key).
+				Msg("Failed to remove attachment blob")
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"real-time-chat-app/dto/req"
+	"real-time-chat-app/dto/res"
+	"real-time-chat-app/entity"
+	"time"
+)
+
+const (
+	maxDeviceNameLength = 100
+	maxUserAgentLength  = 512
+)
+
+// SessionCloser drops the live connections of a revoked login session, the
+// websocket handler implements it.
+type SessionCloser interface {
+	CloseAuthSession(userID, sessionID string)
+}
+
+func (uc *AuthUsecaseImpl) createSession(ctx context.Context, tx *gorm.DB, user *entity.User, request *req.LoginRequest) (*entity.AuthSession, error) {
+	// prefer the name sent by the client, fall back to the user agent
+	deviceName := request.DeviceName
+	if deviceName == "" {
+		deviceName = request.UserAgent
+	}
+	if runes := []rune(deviceName); len(runes) > maxDeviceNameLength {
+		deviceName = string(runes[:maxDeviceNameLength])
+	}
+
+	userAgent := request.UserAgent
+	if runes := []rune(userAgent); len(runes) > maxUserAgentLength {
+		userAgent = string(runes[:maxUserAgentLength])
+	}
+
+	session := &entity.AuthSession{
+		UserID:     user.ID,
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		IP:         request.IP,
+		LastUsedAt: time.Now(),
+	}
+	if err := uc.SessionRepository.Save(ctx, tx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (uc *AuthUsecaseImpl) ListSessions(ctx context.Context, userID, currentSessionID string) ([]res.SessionResponse, error) {
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Msg("ListSessions started")
+
+	sessions, err := uc.SessionRepository.FindActiveByUser(ctx, uc.DB, userID)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to get sessions")
+		return nil, err
+	}
+
+	responses := make([]res.SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		responses = append(responses, res.SessionResponse{
+			ID:         s.ID,
+			DeviceName: s.DeviceName,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt.Format("2006-01-02 15:04:05"),
+			LastUsedAt: s.LastUsedAt.Format("2006-01-02 15:04:05"),
+			Current:    s.ID == currentSessionID,
+		})
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Int("sessionCount", len(responses)).
+		Msg("Successfully retrieved sessions")
+
+	return responses, nil
+}
+
+// RevokeSession signs one device out, its tokens stop working and its open
+// websocket connections are closed.
+func (uc *AuthUsecaseImpl) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Str("sessionId", sessionID).
+		Msg("RevokeSession started")
+
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := uc.SessionRepository.FindActive(ctx, tx, userID, sessionID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSessionNotFound
+			}
+			return err
+		}
+		return uc.revokeFamily(ctx, tx, sessionID, time.Now())
+	})
+	if err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Str("sessionId", sessionID).
+			Msg("Failed to revoke session")
+		return err
+	}
+
+	uc.closeSession(userID, sessionID)
+
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Str("sessionId", sessionID).
+		Msg("Session revoked successfully")
+
+	return nil
+}
+
+func (uc *AuthUsecaseImpl) closeSession(userID, sessionID string) {
+	if uc.SessionCloser != nil {
+		uc.SessionCloser.CloseAuthSession(userID, sessionID)
+	}
+}
@@ -0,0 +1,204 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"real-time-chat-app/dto/req"
+	"real-time-chat-app/dto/res"
+	"real-time-chat-app/entity"
+	"real-time-chat-app/security"
+	"time"
+)
+
+// issueTokens signs an access token and stores the refresh token paired with
+// it. The token family is the login session the tokens belong to.
+func (uc *AuthUsecaseImpl) issueTokens(ctx context.Context, tx *gorm.DB, user *entity.User, familyID string) (res.LoginResponse, *entity.RefreshToken, error) {
+	access, err := uc.JWT.GenerateToken(user, familyID)
+	if err != nil {
+		return res.LoginResponse{}, nil, err
+	}
+
+	refresh, hash, err := security.NewRefreshToken()
+	if err != nil {
+		return res.LoginResponse{}, nil, err
+	}
+
+	stored := &entity.RefreshToken{
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenHash:       hash,
+		ExpiresAt:       time.Now().Add(uc.TokenConfig.RefreshTTL),
+		AccessJTI:       access.JTI,
+		AccessExpiresAt: access.ExpiresAt,
+	}
+	if err := uc.TokenRepository.Save(ctx, tx, stored); err != nil {
+		return res.LoginResponse{}, nil, err
+	}
+
+	return res.LoginResponse{
+		SessionID:        familyID,
+		Token:            access.Token,
+		ExpiresAt:        access.ExpiresAt.Format(time.RFC3339),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: stored.ExpiresAt.Format(time.RFC3339),
+	}, stored, nil
+}
+
+func (uc *AuthUsecaseImpl) RefreshToken(ctx context.Context, request *req.RefreshRequest) (res.LoginResponse, error) {
+	uc.Log.Http.Info.Info().Msg("RefreshToken usecase started")
+
+	if err := uc.Validate.Struct(request); err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Msg("Validation failed for refresh request")
+		return res.LoginResponse{}, ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+	var response res.LoginResponse
+	var reused *entity.RefreshToken
+
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := uc.TokenRepository.FindByHash(ctx, tx.Clauses(clause.Locking{Strength: "UPDATE"}), security.HashRefreshToken(request.RefreshToken))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		// a rotated or revoked token coming back means it leaked, the whole
+		// family goes, including the legitimate holder's current tokens
+		if current.UsedAt != nil || current.RevokedAt != nil {
+			reused = current
+			return uc.revokeFamily(ctx, tx, current.FamilyID, now)
+		}
+		if !current.ExpiresAt.After(now) {
+			return ErrInvalidRefreshToken
+		}
+
+		var user entity.User
+		if err := tx.Where("id = ?", current.UserID).Take(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		var next *entity.RefreshToken
+		response, next, err = uc.issueTokens(ctx, tx, &user, current.FamilyID)
+		if err != nil {
+			return err
+		}
+		if err := uc.SessionRepository.Touch(ctx, tx, current.FamilyID, request.IP, request.UserAgent, now); err != nil {
+			return err
+		}
+
+		return uc.TokenRepository.MarkUsed(ctx, tx, current.ID, next.ID, now)
+	})
+	if err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Msg("Failed to refresh token")
+		return res.LoginResponse{}, err
+	}
+
+	if reused != nil {
+		uc.Log.Http.Warning.Warn().
+			Str("userId", reused.UserID).
+			Str("familyId", reused.FamilyID).
+			Msg("Refresh token reuse detected, token family revoked")
+		uc.closeSession(reused.UserID, reused.FamilyID)
+		return res.LoginResponse{}, ErrRefreshTokenReused
+	}
+
+	uc.Log.Http.Info.Info().Msg("Token refreshed successfully")
+	return response, nil
+}
+
+// Logout revokes the refresh token family of the session together with the
+// access token used for the call.
+func (uc *AuthUsecaseImpl) Logout(ctx context.Context, userID string, access security.AccessToken, request *req.LogoutRequest) error {
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Msg("Logout usecase started")
+
+	now := time.Now()
+	var current *entity.RefreshToken
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if request.RefreshToken != "" {
+			current, err = uc.TokenRepository.FindByHash(ctx, tx, security.HashRefreshToken(request.RefreshToken))
+			if err == nil && current.UserID != userID {
+				err = gorm.ErrRecordNotFound
+			}
+		} else if access.JTI != "" {
+			current, err = uc.TokenRepository.FindByAccessJTI(ctx, tx, userID, access.JTI)
+		}
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if request.RefreshToken != "" {
+				return ErrInvalidRefreshToken
+			}
+		}
+
+		if current != nil {
+			if err := uc.revokeFamily(ctx, tx, current.FamilyID, now); err != nil {
+				return err
+			}
+		}
+
+		if access.JTI == "" || !access.ExpiresAt.After(now) {
+			return nil
+		}
+		return uc.TokenRepository.Deny(ctx, tx, []entity.RevokedToken{{
+			JTI:       access.JTI,
+			UserID:    userID,
+			ExpiresAt: access.ExpiresAt,
+		}})
+	})
+	if err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to logout")
+		return err
+	}
+
+	if current != nil {
+		uc.closeSession(userID, current.FamilyID)
+	}
+
+	if err := uc.TokenRepository.PurgeExpired(ctx, uc.DB, now); err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Msg("Failed to purge expired tokens")
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Msg("Logout successful, tokens revoked")
+
+	return nil
+}
+
+// IsRevoked backs the access token denylist checked on every request.
+func (uc *AuthUsecaseImpl) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return uc.TokenRepository.IsDenied(ctx, uc.DB, jti)
+}
+
+// revokeFamily ends a login session together with every token issued for it.
+func (uc *AuthUsecaseImpl) revokeFamily(ctx context.Context, tx *gorm.DB, familyID string, revokedAt time.Time) error {
+	denied, err := uc.TokenRepository.RevokeFamily(ctx, tx, familyID, revokedAt)
+	if err != nil {
+		return err
+	}
+	if err := uc.TokenRepository.Deny(ctx, tx, denied); err != nil {
+		return err
+	}
+	return uc.SessionRepository.Revoke(ctx, tx, familyID, revokedAt)
+}
@@ -0,0 +1,414 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"real-time-chat-app/dto/req"
+	"real-time-chat-app/dto/res"
+	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
+	"real-time-chat-app/security"
+	auth "real-time-chat-app/util"
+	"time"
+)
+
+// twoFactorChallenge answers a correct password on a 2FA account with a
+// challenge token instead of a session.
+func (uc *AuthUsecaseImpl) twoFactorChallenge(account *entity.Account) (res.LoginResponse, error) {
+	token, expiresAt, err := uc.JWT.GenerateChallengeToken(account.ID, uc.TwoFactorConfig.ChallengeTTL)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("username", account.UserName).
+			Msg("Failed to generate two-factor challenge")
+		return res.LoginResponse{}, errors.New("failed to generate authentication token")
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("username", account.UserName).
+		Str("userId", account.User.ID).
+		Msg("Password verified, two-factor code required")
+
+	return res.LoginResponse{
+		TwoFactorRequired:  true,
+		ChallengeToken:     token,
+		ChallengeExpiresAt: expiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// VerifyTwoFactorLogin is the second login step, it exchanges a challenge and
+// a TOTP or recovery code for a session.
+func (uc *AuthUsecaseImpl) VerifyTwoFactorLogin(ctx context.Context, request *req.TwoFactorLoginRequest) (res.LoginResponse, error) {
+	uc.Log.Http.Info.Info().Msg("VerifyTwoFactorLogin usecase started")
+
+	if err := uc.Validate.Struct(request); err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Msg("Validation failed for two-factor login request")
+		return res.LoginResponse{}, ErrInvalidRequest
+	}
+
+	accountID, err := uc.JWT.VerifyChallengeToken(request.ChallengeToken)
+	if err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Msg("Invalid two-factor challenge")
+		return res.LoginResponse{}, ErrInvalidChallenge
+	}
+
+	now := time.Now()
+	trx := uc.DB.WithContext(ctx).Begin()
+	defer trx.Rollback()
+
+	// the row lock keeps a code from being accepted twice by concurrent requests
+	account, err := uc.AuthRepository.FindByID(ctx, trx.Clauses(clause.Locking{Strength: "UPDATE"}), accountID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res.LoginResponse{}, ErrInvalidChallenge
+		}
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("accountId", accountID).
+			Msg("Database error while finding account")
+		return res.LoginResponse{}, errors.New("failed to process login")
+	}
+	// 2FA was turned off since the password step
+	if !account.TOTPEnabled {
+		return res.LoginResponse{}, ErrInvalidChallenge
+	}
+
+	login := &req.LoginRequest{
+		Username:   account.UserName,
+		DeviceName: request.DeviceName,
+		UserAgent:  request.UserAgent,
+		IP:         request.IP,
+	}
+
+	// wrong codes count against the same limits as wrong passwords
+	if err := uc.checkLoginAllowed(ctx, login, now); err != nil {
+		var blocked *LoginBlockedError
+		if errors.As(err, &blocked) {
+			return res.LoginResponse{}, err
+		}
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("username", account.UserName).
+			Msg("Database error while checking login attempts")
+		return res.LoginResponse{}, errors.New("failed to process login")
+	}
+	if err := uc.checkAccountLocked(ctx, login, account, now); err != nil {
+		return res.LoginResponse{}, err
+	}
+
+	ok, err := uc.verifySecondFactor(ctx, trx, account, request.Code, now)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("username", account.UserName).
+			Msg("Failed to verify two-factor code")
+		return res.LoginResponse{}, errors.New("failed to process login")
+	}
+	if !ok {
+		// the failure is recorded outside the transaction holding the account lock
+		trx.Rollback()
+		uc.Log.Http.Warning.Warn().
+			Str("username", account.UserName).
+			Msg("Invalid two-factor code attempt")
+		uc.recordLoginFailure(ctx, login, account, enum.LoginBadCode, now)
+		return res.LoginResponse{}, ErrInvalidTwoFactorCode
+	}
+
+	return uc.completeLogin(ctx, trx, account, login, now)
+}
+
+// verifySecondFactor accepts a TOTP code newer than the last one used or an
+// unused recovery code, which is spent by the check.
+func (uc *AuthUsecaseImpl) verifySecondFactor(ctx context.Context, tx *gorm.DB, account *entity.Account, code string, now time.Time) (bool, error) {
+	secret, err := uc.openTOTPSecret(account)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := security.VerifyTOTP(secret, code, now, account.TOTPLastStep); ok {
+		return true, uc.AuthRepository.SetTOTPLastStep(ctx, tx, account.ID, step)
+	}
+
+	used, err := uc.RecoveryCodeRepository.Consume(ctx, tx, account.ID, security.HashRecoveryCode(code), now)
+	if err != nil || !used {
+		return false, err
+	}
+
+	remaining, err := uc.RecoveryCodeRepository.CountUnused(ctx, tx, account.ID)
+	if err != nil {
+		return false, err
+	}
+	uc.Log.Http.Warning.Warn().
+		Str("username", account.UserName).
+		Int64("remaining", remaining).
+		Msg("Recovery code used for login")
+
+	return true, nil
+}
+
+func (uc *AuthUsecaseImpl) SetupTwoFactor(ctx context.Context, userID string) (res.TwoFactorSetupResponse, error) {
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Msg("SetupTwoFactor started")
+
+	if uc.SecretBox == nil {
+		return res.TwoFactorSetupResponse{}, ErrTwoFactorUnavailable
+	}
+
+	var response res.TwoFactorSetupResponse
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := uc.findAccountForUpdate(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if account.TOTPEnabled {
+			return ErrTwoFactorEnabled
+		}
+
+		// setting up again replaces a secret that was never confirmed
+		secret, err := security.NewTOTPSecret()
+		if err != nil {
+			return err
+		}
+		sealed, err := uc.SecretBox.Seal(secret)
+		if err != nil {
+			return err
+		}
+		if err := uc.AuthRepository.SetTOTP(ctx, tx, account.ID, sealed, false, 0); err != nil {
+			return err
+		}
+
+		response = res.TwoFactorSetupResponse{
+			Secret:     secret,
+			OTPAuthURI: security.TOTPURI(uc.TwoFactorConfig.Issuer, account.UserName, secret),
+		}
+		return nil
+	})
+	if err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to set up two-factor authentication")
+		return res.TwoFactorSetupResponse{}, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Msg("Two-factor setup started, waiting for confirmation")
+
+	return response, nil
+}
+
+// ConfirmTwoFactor enables 2FA once the authenticator app produced a valid
+// code for the pending secret and hands out the recovery codes.
+func (uc *AuthUsecaseImpl) ConfirmTwoFactor(ctx context.Context, userID string, request *req.TwoFactorConfirmRequest) (res.RecoveryCodesResponse, error) {
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Msg("ConfirmTwoFactor started")
+
+	if err := uc.Validate.Struct(request); err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Msg("Validation failed for two-factor confirmation")
+		return res.RecoveryCodesResponse{}, ErrInvalidRequest
+	}
+
+	var response res.RecoveryCodesResponse
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := uc.findAccountForUpdate(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if account.TOTPEnabled {
+			return ErrTwoFactorEnabled
+		}
+		if account.TOTPSecret == "" {
+			return ErrTwoFactorNotPending
+		}
+
+		secret, err := uc.openTOTPSecret(account)
+		if err != nil {
+			return err
+		}
+		step, ok := security.VerifyTOTP(secret, request.Code, time.Now(), 0)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		response.RecoveryCodes, err = uc.replaceRecoveryCodes(ctx, tx, account.ID)
+		if err != nil {
+			return err
+		}
+		return uc.AuthRepository.SetTOTP(ctx, tx, account.ID, account.TOTPSecret, true, step)
+	})
+	if err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to confirm two-factor authentication")
+		return res.RecoveryCodesResponse{}, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Msg("Two-factor authentication enabled")
+
+	return response, nil
+}
+
+func (uc *AuthUsecaseImpl) DisableTwoFactor(ctx context.Context, userID string, request *req.PasswordConfirmRequest) error {
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Msg("DisableTwoFactor started")
+
+	if err := uc.confirmPassword(ctx, userID, request); err != nil {
+		return err
+	}
+
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := uc.findAccountForUpdate(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if !account.TOTPEnabled && account.TOTPSecret == "" {
+			return ErrTwoFactorNotEnabled
+		}
+
+		if err := uc.RecoveryCodeRepository.DeleteByAccount(ctx, tx, account.ID); err != nil {
+			return err
+		}
+		return uc.AuthRepository.SetTOTP(ctx, tx, account.ID, "", false, 0)
+	})
+	if err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to disable two-factor authentication")
+		return err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Msg("Two-factor authentication disabled")
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not.
+func (uc *AuthUsecaseImpl) RegenerateRecoveryCodes(ctx context.Context, userID string, request *req.PasswordConfirmRequest) (res.RecoveryCodesResponse, error) {
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Msg("RegenerateRecoveryCodes started")
+
+	if err := uc.confirmPassword(ctx, userID, request); err != nil {
+		return res.RecoveryCodesResponse{}, err
+	}
+
+	var response res.RecoveryCodesResponse
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := uc.findAccountForUpdate(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if !account.TOTPEnabled {
+			return ErrTwoFactorNotEnabled
+		}
+
+		response.RecoveryCodes, err = uc.replaceRecoveryCodes(ctx, tx, account.ID)
+		return err
+	})
+	if err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to regenerate recovery codes")
+		return res.RecoveryCodesResponse{}, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Int("count", len(response.RecoveryCodes)).
+		Msg("Recovery codes regenerated")
+
+	return response, nil
+}
+
+// confirmPassword re-checks the password of a signed in user. Wrong passwords
+// are throttled like failed logins, so a stolen access token cannot be used to
+// guess it.
+func (uc *AuthUsecaseImpl) confirmPassword(ctx context.Context, userID string, request *req.PasswordConfirmRequest) error {
+	if err := uc.Validate.Struct(request); err != nil {
+		return ErrInvalidRequest
+	}
+
+	account, err := uc.AuthRepository.FindByUserID(ctx, uc.DB, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	now := time.Now()
+	login := &req.LoginRequest{Username: account.UserName, UserAgent: request.UserAgent, IP: request.IP}
+	if err := uc.checkLoginAllowed(ctx, login, now); err != nil {
+		return err
+	}
+	if err := uc.checkAccountLocked(ctx, login, account, now); err != nil {
+		return err
+	}
+
+	if !auth.ComparePassword(account.Password, request.Password) {
+		uc.Log.Http.Warning.Warn().
+			Str("userId", userID).
+			Msg("Invalid password on re-confirmation")
+		uc.recordLoginFailure(ctx, login, account, enum.LoginBadPassword, now)
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+func (uc *AuthUsecaseImpl) findAccountForUpdate(ctx context.Context, tx *gorm.DB, userID string) (*entity.Account, error) {
+	account, err := uc.AuthRepository.FindByUserID(ctx, tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+func (uc *AuthUsecaseImpl) openTOTPSecret(account *entity.Account) (string, error) {
+	if uc.SecretBox == nil {
+		return "", ErrTwoFactorUnavailable
+	}
+	return uc.SecretBox.Open(account.TOTPSecret)
+}
+
+// replaceRecoveryCodes stores fresh codes and returns them in plain text, the
+// only time they are ever readable.
+func (uc *AuthUsecaseImpl) replaceRecoveryCodes(ctx context.Context, tx *gorm.DB, accountID string) ([]string, error) {
+	codes := make([]string, 0, uc.TwoFactorConfig.RecoveryCodeCount)
+	hashes := make([]string, 0, uc.TwoFactorConfig.RecoveryCodeCount)
+	for i := 0; i < uc.TwoFactorConfig.RecoveryCodeCount; i++ {
+		code, hash, err := security.NewRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hash)
+	}
+
+	if err := uc.RecoveryCodeRepository.Replace(ctx, tx, accountID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
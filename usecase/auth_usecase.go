@@ -4,9 +4,20 @@ import (
 	"context"
 	"real-time-chat-app/dto/req"
 	"real-time-chat-app/dto/res"
+	"real-time-chat-app/security"
 )
 
 type AuthUsecase interface {
 	RegisterUser(ctx context.Context, request *req.RegisterRequest) (res.RegisterResponse, error)
 	LoginUser(ctx context.Context, request *req.LoginRequest) (res.LoginResponse, error)
+	RefreshToken(ctx context.Context, request *req.RefreshRequest) (res.LoginResponse, error)
+	Logout(ctx context.Context, userID string, access security.AccessToken, request *req.LogoutRequest) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	ListSessions(ctx context.Context, userID, currentSessionID string) ([]res.SessionResponse, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	VerifyTwoFactorLogin(ctx context.Context, request *req.TwoFactorLoginRequest) (res.LoginResponse, error)
+	SetupTwoFactor(ctx context.Context, userID string) (res.TwoFactorSetupResponse, error)
+	ConfirmTwoFactor(ctx context.Context, userID string, request *req.TwoFactorConfirmRequest) (res.RecoveryCodesResponse, error)
+	DisableTwoFactor(ctx context.Context, userID string, request *req.PasswordConfirmRequest) error
+	RegenerateRecoveryCodes(ctx context.Context, userID string, request *req.PasswordConfirmRequest) (res.RecoveryCodesResponse, error)
 }
@@ -5,13 +5,16 @@ import (
 	"errors"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
+	"real-time-chat-app/config/common"
 	"real-time-chat-app/config/logger"
 	"real-time-chat-app/dto/req"
 	"real-time-chat-app/dto/res"
 	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
 	"real-time-chat-app/repository"
 	"real-time-chat-app/security"
 	auth "real-time-chat-app/util"
+	"time"
 )
 
 type AuthUsecaseImpl struct {
@@ -20,10 +23,20 @@ type AuthUsecaseImpl struct {
 	*gorm.DB
 	Log *logger.AppLogger
 	*security.JWT
+	TokenRepository        *repository.TokenRepository
+	SessionRepository      *repository.SessionRepository
+	SessionCloser          SessionCloser
+	TokenConfig            common.TokenConfig
+	LoginAttemptRepository *repository.LoginAttemptRepository
+	LoginConfig            common.LoginConfig
+	RecoveryCodeRepository *repository.RecoveryCodeRepository
+	// nil while no TOTP encryption key is configured, enrollment is refused then
+	SecretBox       *security.SecretBox
+	TwoFactorConfig common.TwoFactorConfig
 }
 
-func NewAuthUsecase(authRepository *repository.AuthRepository, validate *validator.Validate, DB *gorm.DB, logger *logger.AppLogger, JWT *security.JWT) AuthUsecase {
-	return &AuthUsecaseImpl{AuthRepository: authRepository, Validate: validate, DB: DB, Log: logger, JWT: JWT}
+func NewAuthUsecase(authRepository *repository.AuthRepository, tokenRepository *repository.TokenRepository, sessionRepository *repository.SessionRepository, loginAttemptRepository *repository.LoginAttemptRepository, recoveryCodeRepository *repository.RecoveryCodeRepository, sessionCloser SessionCloser, validate *validator.Validate, DB *gorm.DB, logger *logger.AppLogger, JWT *security.JWT, secretBox *security.SecretBox, tokenConfig common.TokenConfig, loginConfig common.LoginConfig, twoFactorConfig common.TwoFactorConfig) AuthUsecase {
+	return &AuthUsecaseImpl{AuthRepository: authRepository, TokenRepository: tokenRepository, SessionRepository: sessionRepository, LoginAttemptRepository: loginAttemptRepository, RecoveryCodeRepository: recoveryCodeRepository, SessionCloser: sessionCloser, Validate: validate, DB: DB, Log: logger, JWT: JWT, SecretBox: secretBox, TokenConfig: tokenConfig, LoginConfig: loginConfig, TwoFactorConfig: twoFactorConfig}
 }
 
 func (uc *AuthUsecaseImpl) LoginUser(ctx context.Context, req *req.LoginRequest) (res.LoginResponse, error) {
@@ -40,6 +53,26 @@ func (uc *AuthUsecaseImpl) LoginUser(ctx context.Context, req *req.LoginRequest)
 		return res.LoginResponse{}, errors.New("invalid request data")
 	}
 
+	// a throttled username or IP is turned away before bcrypt runs
+	now := time.Now()
+	if err := uc.checkLoginAllowed(ctx, req, now); err != nil {
+		var blocked *LoginBlockedError
+		if errors.As(err, &blocked) {
+			uc.Log.Http.Warning.Warn().
+				Str("username", req.Username).
+				Str("ip", req.IP).
+				Dur("retryAfter", blocked.RetryAfter).
+				Msg("Login attempt blocked")
+			return res.LoginResponse{}, err
+		}
+
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("username", req.Username).
+			Msg("Database error while checking login attempts")
+		return res.LoginResponse{}, errors.New("failed to process login")
+	}
+
 	uc.Log.Http.Trace.Trace().
 		Str("username", req.Username).
 		Msg("Validation passed, starting database transaction")
@@ -59,6 +92,7 @@ func (uc *AuthUsecaseImpl) LoginUser(ctx context.Context, req *req.LoginRequest)
 			uc.Log.Http.Warning.Warn().
 				Str("username", req.Username).
 				Msg("User not found")
+			uc.recordLoginFailure(ctx, req, nil, enum.LoginUnknownUser, now)
 			return res.LoginResponse{}, errors.New("invalid username or password")
 		}
 
@@ -74,39 +108,86 @@ func (uc *AuthUsecaseImpl) LoginUser(ctx context.Context, req *req.LoginRequest)
 		Str("userId", currentAccount.User.ID).
 		Msg("User found, verifying password")
 
+	if err := uc.checkAccountLocked(ctx, req, &currentAccount, now); err != nil {
+		return res.LoginResponse{}, err
+	}
+
 	// compare the password
 	if matchPassword := auth.ComparePassword(currentAccount.Password, req.Password); !matchPassword {
 		uc.Log.Http.Warning.Warn().
 			Str("username", req.Username).
 			Msg("Invalid password attempt")
+		uc.recordLoginFailure(ctx, req, &currentAccount, enum.LoginBadPassword, now)
 		return res.LoginResponse{}, errors.New("invalid username or password")
 	}
 
+	// with 2FA the password only earns a challenge, the session starts after the code
+	if currentAccount.TOTPEnabled {
+		return uc.twoFactorChallenge(&currentAccount)
+	}
+
 	uc.Log.Http.Trace.Trace().
 		Str("username", req.Username).
 		Str("userId", currentAccount.User.ID).
 		Msg("Password verified, generating JWT token")
 
-	// generate token
-	token, err := uc.JWT.GenerateToken(&currentAccount.User)
+	return uc.completeLogin(ctx, trx, &currentAccount, req, now)
+}
+
+// completeLogin starts the session of an authenticated account and commits tx.
+func (uc *AuthUsecaseImpl) completeLogin(ctx context.Context, trx *gorm.DB, account *entity.Account, req *req.LoginRequest, now time.Time) (res.LoginResponse, error) {
+	// every login is a new session and starts a new token family
+	session, err := uc.createSession(ctx, trx, &account.User, req)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("username", req.Username).
+			Str("userId", account.User.ID).
+			Msg("Failed to record login session")
+		return res.LoginResponse{}, errors.New("failed to process login")
+	}
+
+	// generate access and refresh token
+	response, _, err := uc.issueTokens(ctx, trx, &account.User, session.ID)
 	if err != nil {
 		uc.Log.Http.Error.Error().
 			Err(err).
 			Str("username", req.Username).
-			Str("userId", currentAccount.User.ID).
+			Str("userId", account.User.ID).
 			Msg("Failed to generate JWT token")
 		return res.LoginResponse{}, errors.New("failed to generate authentication token")
 	}
 
+	if err := uc.resetLoginFailures(ctx, trx, account); err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("username", req.Username).
+			Str("userId", account.User.ID).
+			Msg("Failed to reset failed logins")
+		return res.LoginResponse{}, errors.New("failed to process login")
+	}
+
+	if err := trx.Commit().Error; err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("username", req.Username).
+			Str("userId", account.User.ID).
+			Msg("Failed to commit transaction")
+		return res.LoginResponse{}, errors.New("failed to generate authentication token")
+	}
+
+	if err := uc.LoginAttemptRepository.PurgeStale(ctx, uc.DB, now.Add(-uc.LoginConfig.Window), now); err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Msg("Failed to purge stale login attempts")
+	}
+
 	uc.Log.Http.Info.Info().
 		Str("username", req.Username).
-		Str("userId", currentAccount.User.ID).
+		Str("userId", account.User.ID).
 		Msg("Login successful, token generated")
 
-	// mapping response
-	return res.LoginResponse{
-		Token: token,
-	}, nil
+	return response, nil
 }
 
 func (uc *AuthUsecaseImpl) RegisterUser(ctx context.Context, req *req.RegisterRequest) (res.RegisterResponse, error) {
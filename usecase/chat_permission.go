@@ -0,0 +1,44 @@
+package usecase
+
+import "real-time-chat-app/enum"
+
+type groupAction string
+
+const (
+	actionRename     groupAction = "rename"
+	actionInvite     groupAction = "invite"
+	actionKick       groupAction = "kick"
+	actionChangeRole groupAction = "change_role"
+	actionTransfer   groupAction = "transfer_ownership"
+	actionDelete     groupAction = "delete"
+)
+
+// groupPermissions lists the roles allowed to perform each group action.
+var groupPermissions = map[groupAction][]enum.ParticipantRole{
+	actionRename:     {enum.RoleOwner, enum.RoleAdmin},
+	actionInvite:     {enum.RoleOwner, enum.RoleAdmin},
+	actionKick:       {enum.RoleOwner, enum.RoleAdmin},
+	actionChangeRole: {enum.RoleOwner},
+	actionTransfer:   {enum.RoleOwner},
+	actionDelete:     {enum.RoleOwner},
+}
+
+var roleRank = map[enum.ParticipantRole]int{
+	enum.RoleMember: 1,
+	enum.RoleAdmin:  2,
+	enum.RoleOwner:  3,
+}
+
+func canPerform(role enum.ParticipantRole, action groupAction) bool {
+	for _, allowed := range groupPermissions[action] {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// outranks reports whether actor may act on target, e.g. admins can kick members but not other admins.
+func outranks(actor, target enum.ParticipantRole) bool {
+	return roleRank[actor] > roleRank[target]
+}
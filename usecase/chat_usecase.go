@@ -3,7 +3,9 @@ package usecase
 import (
 	"context"
 	"gorm.io/gorm"
+	"real-time-chat-app/dto/req"
 	"real-time-chat-app/dto/res"
+	"real-time-chat-app/dto/ws"
 	"real-time-chat-app/entity"
 )
 
@@ -12,5 +14,19 @@ type ChatUsecase interface {
 	CreateGroupChat(ctx context.Context, name string, creatorID string, memberIDs []string) (*entity.Chat, error)
 	FindChatByID(ctx context.Context, db *gorm.DB, chatID string) (*entity.Chat, error)
 	GetChatsByUser(ctx context.Context, token string) ([]res.ChatResponse, error)
-	GetMessagesByChatID(ctx context.Context, token string, chatId string) ([]res.MessageResponse, error)
+	GetMessagesByChatID(ctx context.Context, token string, chatId string, page req.MessagePageRequest) (res.MessagePageResponse, error)
+	GetReplies(ctx context.Context, userId, chatId, messageId string, page req.MessagePageRequest) (res.ThreadResponse, error)
+	SearchMessages(ctx context.Context, userID string, request req.MessageSearchRequest) (res.MessageSearchResponse, error)
+	SyncEvents(ctx context.Context, userID string, request req.SyncRequest) (res.SyncResponse, error)
+	IsParticipant(ctx context.Context, chatID, userID string) (bool, error)
+	CountUnread(ctx context.Context, userID, chatID string) (int64, error)
+	CreateGroup(ctx context.Context, creatorID string, request req.CreateGroupRequest) (res.GroupChatResponse, error)
+	RenameGroup(ctx context.Context, actorID, chatID string, request req.UpdateGroupRequest) (ws.GroupRenamedPayload, error)
+	AddParticipants(ctx context.Context, actorID, chatID string, request req.ParticipantsRequest) (ws.MemberAddedPayload, error)
+	RemoveParticipants(ctx context.Context, actorID, chatID string, request req.ParticipantsRequest) (ws.MemberRemovedPayload, error)
+	LeaveGroup(ctx context.Context, userID, chatID string) (ws.MemberRemovedPayload, error)
+	ListParticipants(ctx context.Context, userID, chatID string) ([]res.ParticipantResponse, error)
+	UpdateParticipantRole(ctx context.Context, actorID, chatID, targetID string, request req.UpdateRoleRequest) (ws.RoleChangedPayload, error)
+	TransferOwnership(ctx context.Context, actorID, chatID string, request req.TransferOwnershipRequest) (ws.RoleChangedPayload, error)
+	DeleteGroup(ctx context.Context, actorID, chatID string) (ws.GroupDeletedPayload, []string, error)
 }
@@ -2,26 +2,64 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
 	"real-time-chat-app/config/logger"
+	"real-time-chat-app/dto"
+	"real-time-chat-app/dto/req"
 	"real-time-chat-app/dto/res"
+	"real-time-chat-app/dto/ws"
 	"real-time-chat-app/entity"
 	"real-time-chat-app/enum"
 	"real-time-chat-app/repository"
 	"real-time-chat-app/security"
+	"sort"
+	"time"
+)
+
+const (
+	defaultSyncLimit        = 500
+	maxSyncLimit            = 1000
+	defaultMessagePageLimit = 50
+	maxMessagePageLimit     = 100
 )
 
 type ChatUsecaseImpl struct {
 	*repository.ChatRepository
+	EventRepository    *repository.ChatEventRepository
+	ReactionRepository *repository.ReactionRepository
+	*validator.Validate
 	Log *logger.AppLogger
 	*gorm.DB
 	*security.JWT
 }
 
-func NewChatUsecase(chatRepository *repository.ChatRepository, logger *logger.AppLogger, DB *gorm.DB, JWT *security.JWT) *ChatUsecaseImpl {
-	return &ChatUsecaseImpl{ChatRepository: chatRepository, Log: logger, DB: DB, JWT: JWT}
+func NewChatUsecase(chatRepository *repository.ChatRepository, eventRepository *repository.ChatEventRepository, reactionRepository *repository.ReactionRepository, validate *validator.Validate, logger *logger.AppLogger, DB *gorm.DB, JWT *security.JWT) *ChatUsecaseImpl {
+	return &ChatUsecaseImpl{ChatRepository: chatRepository, EventRepository: eventRepository, ReactionRepository: reactionRepository, Validate: validate, Log: logger, DB: DB, JWT: JWT}
+}
+
+func (uc *ChatUsecaseImpl) createChat(ctx context.Context, chat *entity.Chat, participants []entity.ChatParticipant) error {
+	return uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := uc.ChatRepository.CreateChatWithParticipants(ctx, tx, chat, participants); err != nil {
+			return err
+		}
+
+		participantIDs := make([]string, 0, len(participants))
+		for _, p := range participants {
+			participantIDs = append(participantIDs, p.UserID)
+		}
+
+		_, err := uc.EventRepository.Append(ctx, tx, chat.ID, ws.TypeChatCreated, ws.ChatCreatedPayload{
+			ChatID:       chat.ID,
+			ChatType:     string(chat.ChatType),
+			GroupName:    chat.GroupName,
+			Participants: participantIDs,
+		})
+		return err
+	})
 }
 
 func (uc *ChatUsecaseImpl) EnsurePersonalChat(ctx context.Context, userAID, userBID string) (*entity.Chat, error) {
@@ -68,7 +106,7 @@ func (uc *ChatUsecaseImpl) EnsurePersonalChat(ctx context.Context, userAID, user
 		{UserID: userBID},
 	}
 
-	if err := uc.ChatRepository.CreateChatWithParticipants(ctx, uc.DB, newChat, participants); err != nil {
+	if err := uc.createChat(ctx, newChat, participants); err != nil {
 		uc.Log.Http.Error.Error().
 			Err(err).
 			Str("userAID", userAID).
@@ -99,9 +137,9 @@ func (uc *ChatUsecaseImpl) CreateGroupChat(ctx context.Context, name string, cre
 	}
 
 	participants := make([]entity.ChatParticipant, 0, len(memberIDs)+1)
-	participants = append(participants, entity.ChatParticipant{UserID: creatorID})
+	participants = append(participants, entity.ChatParticipant{UserID: creatorID, Role: enum.RoleOwner})
 	for _, id := range memberIDs {
-		participants = append(participants, entity.ChatParticipant{UserID: id})
+		participants = append(participants, entity.ChatParticipant{UserID: id, Role: enum.RoleMember})
 	}
 
 	uc.Log.Http.Trace.Trace().
@@ -114,7 +152,7 @@ func (uc *ChatUsecaseImpl) CreateGroupChat(ctx context.Context, name string, cre
 		Int("totalParticipants", len(participants)).
 		Msg("Creating group chat with participants")
 
-	if err := uc.ChatRepository.CreateChatWithParticipants(ctx, uc.DB, newChat, participants); err != nil {
+	if err := uc.createChat(ctx, newChat, participants); err != nil {
 		uc.Log.Http.Error.Error().
 			Err(err).
 			Str("groupName", name).
@@ -233,6 +271,7 @@ func (uc *ChatUsecaseImpl) GetChatsByUser(ctx context.Context, token string) ([]
 		var lastMessageTime string
 
 		if err := uc.DB.Where("chat_id = ?", chat.ID).
+			Scopes(repository.VisibleTo(userId)).
 			Order("created_at DESC").
 			First(&lastMessage).Error; err == nil {
 			lastMessageContent = lastMessage.Content
@@ -251,6 +290,7 @@ func (uc *ChatUsecaseImpl) GetChatsByUser(ctx context.Context, token string) ([]
 			LastMessage:     lastMessageContent,
 			UnreadCount:     uint(unread),
 			LastMessageTime: lastMessageTime,
+			LastSeq:         chat.LastSeq,
 		})
 	}
 
@@ -262,9 +302,12 @@ func (uc *ChatUsecaseImpl) GetChatsByUser(ctx context.Context, token string) ([]
 	return chatResponses, nil
 }
 
-func (uc *ChatUsecaseImpl) GetMessagesByChatID(ctx context.Context, token string, chatId string) ([]res.MessageResponse, error) {
+func (uc *ChatUsecaseImpl) GetMessagesByChatID(ctx context.Context, token string, chatId string, page req.MessagePageRequest) (res.MessagePageResponse, error) {
 	uc.Log.Http.Info.Info().
 		Str("chatId", chatId).
+		Str("before", page.Before).
+		Str("after", page.After).
+		Int("limit", page.Limit).
 		Msg("GetMessagesByChatID started")
 
 	// Extract user ID from token
@@ -274,7 +317,29 @@ func (uc *ChatUsecaseImpl) GetMessagesByChatID(ctx context.Context, token string
 			Err(err).
 			Str("chatId", chatId).
 			Msg("Failed to parse token")
-		return nil, fmt.Errorf("failed to parse token: %w", err)
+		return res.MessagePageResponse{}, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	return uc.messagePage(ctx, userId, chatId, page)
+}
+
+// messagePage loads one keyset page of the chat's messages visible to userId,
+// narrowed further by the given scopes.
+func (uc *ChatUsecaseImpl) messagePage(ctx context.Context, userId, chatId string, page req.MessagePageRequest, scopes ...func(*gorm.DB) *gorm.DB) (res.MessagePageResponse, error) {
+	forward, cursor, err := parseMessagePage(page)
+	if err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("chatId", chatId).
+			Msg("Invalid pagination parameters")
+		return res.MessagePageResponse{}, err
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = defaultMessagePageLimit
+	} else if limit > maxMessagePageLimit {
+		limit = maxMessagePageLimit
 	}
 
 	uc.Log.Http.Trace.Trace().
@@ -290,7 +355,7 @@ func (uc *ChatUsecaseImpl) GetMessagesByChatID(ctx context.Context, token string
 			Str("userId", userId).
 			Str("chatId", chatId).
 			Msg("Failed to verify participant")
-		return nil, fmt.Errorf("failed to verify participant: %w", err)
+		return res.MessagePageResponse{}, fmt.Errorf("failed to verify participant: %w", err)
 	}
 
 	if !isParticipant {
@@ -298,63 +363,445 @@ func (uc *ChatUsecaseImpl) GetMessagesByChatID(ctx context.Context, token string
 			Str("userId", userId).
 			Str("chatId", chatId).
 			Msg("User not authorized for this chat")
-		return nil, fmt.Errorf("user not authorized for this chat")
+		return res.MessagePageResponse{}, ErrNotParticipant
 	}
 
 	uc.Log.Http.Trace.Trace().
 		Str("userId", userId).
 		Str("chatId", chatId).
+		Bool("forward", forward).
+		Int("limit", limit).
 		Msg("User verified, fetching messages")
 
-	// Get messages
-	messages, err := uc.ChatRepository.FindMessagesByChatID(ctx, uc.DB, chatId)
+	// one extra row tells whether another page exists
+	messages, err := uc.ChatRepository.FindMessagesPage(ctx, uc.DB, chatId, userId, cursor, forward, limit+1, scopes...)
 	if err != nil {
 		uc.Log.Http.Error.Error().
 			Err(err).
 			Str("chatId", chatId).
 			Msg("Failed to get messages")
-		return nil, fmt.Errorf("failed to get messages: %w", err)
+		return res.MessagePageResponse{}, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	// pages are always returned oldest first
+	if !forward {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
 	}
 
-	var responses []res.MessageResponse
+	response := res.MessagePageResponse{
+		ChatID:   chatId,
+		Messages: make([]res.MessageResponse, 0, len(messages)),
+	}
 	for _, msg := range messages {
-		responses = append(responses, res.MessageResponse{
-			MessageId:  msg.ID,
-			Content:    msg.Content,
-			SenderId:   msg.SenderId,
-			SenderName: msg.Sender.Name,
-			Status:     string(msg.Status),
-			CreatedAt:  msg.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
+		response.Messages = append(response.Messages, messageResponse(msg))
+	}
+
+	if err := uc.attachReactions(ctx, userId, response.Messages); err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatId).
+			Msg("Failed to get message reactions")
+		return res.MessagePageResponse{}, fmt.Errorf("failed to get message reactions: %w", err)
+	}
+
+	if len(messages) > 0 {
+		// a cursor in the request means the client came from the other side of it
+		hasOlder, hasNewer := hasMore, cursor != nil
+		if forward {
+			hasOlder, hasNewer = cursor != nil, hasMore
+		}
+
+		first, last := messages[0], messages[len(messages)-1]
+		if hasOlder {
+			response.Prev = repository.MessageCursor{CreatedAt: first.CreatedAt, ID: first.ID}.Encode()
+		}
+		if hasNewer {
+			response.Next = repository.MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+		}
 	}
 
 	uc.Log.Http.Info.Info().
 		Str("userId", userId).
 		Str("chatId", chatId).
-		Int("messageCount", len(responses)).
+		Int("messageCount", len(response.Messages)).
+		Bool("hasMore", hasMore).
 		Msg("Successfully retrieved messages")
 
-	return responses, nil
+	return response, nil
 }
 
-func (uc *ChatUsecaseImpl) getUnreadCount(ctx context.Context, userID string) (map[string]int, error) {
-	uc.Log.Http.Trace.Trace().
+func (uc *ChatUsecaseImpl) GetReplies(ctx context.Context, userId, chatId, messageId string, page req.MessagePageRequest) (res.ThreadResponse, error) {
+	uc.Log.Http.Info.Info().
+		Str("userId", userId).
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Msg("GetReplies started")
+
+	replies, err := uc.messagePage(ctx, userId, chatId, page, repository.RepliesTo(messageId))
+	if err != nil {
+		return res.ThreadResponse{}, err
+	}
+
+	var parent entity.Messages
+	err = uc.DB.WithContext(ctx).
+		Preload("Sender").
+		Where("id = ? AND chat_id = ?", messageId, chatId).
+		First(&parent).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			uc.Log.Http.Warning.Warn().
+				Str("chatId", chatId).
+				Str("messageId", messageId).
+				Msg("Thread parent not found")
+			return res.ThreadResponse{}, ErrMessageNotFound
+		}
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("messageId", messageId).
+			Msg("Failed to get thread parent")
+		return res.ThreadResponse{}, err
+	}
+
+	parentResponse := []res.MessageResponse{messageResponse(parent)}
+	if err := uc.attachReactions(ctx, userId, parentResponse); err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("messageId", messageId).
+			Msg("Failed to get thread parent reactions")
+		return res.ThreadResponse{}, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("chatId", chatId).
+		Str("messageId", messageId).
+		Int("replyCount", len(replies.Messages)).
+		Msg("Successfully retrieved replies")
+
+	return res.ThreadResponse{
+		ChatID:  chatId,
+		Parent:  parentResponse[0],
+		Replies: replies.Messages,
+		Next:    replies.Next,
+		Prev:    replies.Prev,
+	}, nil
+}
+
+func messageResponse(msg entity.Messages) res.MessageResponse {
+	return res.MessageResponse{
+		MessageId:   msg.ID,
+		Content:     msg.Content,
+		SenderId:    msg.SenderId,
+		SenderName:  msg.Sender.Name,
+		Status:      string(msg.Status),
+		Type:        string(msg.Type),
+		CreatedAt:   msg.CreatedAt.Format("2006-01-02 15:04:05"),
+		Seq:         msg.Seq,
+		IsEdited:    msg.IsEdited,
+		EditedAt:    formatOptionalTime(msg.EditedAt),
+		IsDeleted:   msg.IsDeleted,
+		ReplyTo:     quoteOf(msg.ReplyTo),
+		ReplyCount:  msg.ReplyCount,
+		Attachments: attachmentsOf(msg.Attachments),
+	}
+}
+
+// attachReactions fills in the reaction counts of the messages as seen by userId.
+func (uc *ChatUsecaseImpl) attachReactions(ctx context.Context, userId string, messages []res.MessageResponse) error {
+	messageIDs := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		messageIDs = append(messageIDs, msg.MessageId)
+	}
+
+	counts, err := uc.ReactionRepository.CountByMessages(ctx, uc.DB, messageIDs, userId)
+	if err != nil {
+		return err
+	}
+
+	byMessage := make(map[string][]res.ReactionResponse)
+	for _, c := range counts {
+		byMessage[c.MessageID] = append(byMessage[c.MessageID], res.ReactionResponse{
+			Emoji:       c.Emoji,
+			Count:       c.Count,
+			ReactedByMe: c.ReactedByMe,
+		})
+	}
+
+	for i := range messages {
+		if reactions, ok := byMessage[messages[i].MessageId]; ok {
+			messages[i].Reactions = reactions
+		} else {
+			messages[i].Reactions = []res.ReactionResponse{}
+		}
+	}
+	return nil
+}
+
+func attachmentsOf(attachments []entity.Attachment) []dto.Attachment {
+	result := make([]dto.Attachment, 0, len(attachments))
+	for _, a := range attachments {
+		result = append(result, attachmentOf(a))
+	}
+	return result
+}
+
+func attachmentOf(a entity.Attachment) dto.Attachment {
+	url := fmt.Sprintf("/api/v1/chats/%s/attachments/%s", a.ChatID, a.ID)
+	attachment := dto.Attachment{
+		AttachmentID: a.ID,
+		FileName:     a.FileName,
+		MimeType:     a.MimeType,
+		Size:         a.Size,
+		Checksum:     a.Checksum,
+		Width:        a.Width,
+		Height:       a.Height,
+		URL:          url,
+		BlurHash:     a.BlurHash,
+	}
+	if a.MediaStatus != "" && a.MediaStatus != enum.MediaStatusNone {
+		attachment.MediaStatus = string(a.MediaStatus)
+	}
+	if a.MediaStatus == enum.MediaStatusReady {
+		attachment.ThumbnailURL = url + "?variant=" + string(enum.MediaVariantThumbnail)
+		attachment.PreviewURL = url + "?variant=" + string(enum.MediaVariantPreview)
+	}
+	return attachment
+}
+
+const quoteSnippetLength = 100
+
+// quoteOf builds the reply snippet of a message, nil when there is nothing to quote.
+func quoteOf(msg *entity.Messages) *dto.QuotedMessage {
+	if msg == nil {
+		return nil
+	}
+
+	content := []rune(msg.Content)
+	if len(content) > quoteSnippetLength {
+		content = content[:quoteSnippetLength]
+	}
+
+	return &dto.QuotedMessage{
+		MessageID:  msg.ID,
+		SenderID:   msg.SenderId,
+		SenderName: msg.Sender.Name,
+		Content:    string(content),
+		IsDeleted:  msg.IsDeleted,
+	}
+}
+
+func parseMessagePage(page req.MessagePageRequest) (bool, *repository.MessageCursor, error) {
+	if page.Before != "" && page.After != "" {
+		return false, nil, fmt.Errorf("%w: before and after are mutually exclusive", ErrInvalidCursor)
+	}
+
+	forward := page.After != ""
+	switch page.Direction {
+	case "", "backward":
+		if page.Direction != "" && forward {
+			return false, nil, fmt.Errorf("%w: after cannot be used with backward direction", ErrInvalidCursor)
+		}
+	case "forward":
+		if page.Before != "" {
+			return false, nil, fmt.Errorf("%w: before cannot be used with forward direction", ErrInvalidCursor)
+		}
+		forward = true
+	default:
+		return false, nil, fmt.Errorf("%w: unknown direction %q", ErrInvalidCursor, page.Direction)
+	}
+
+	value := page.Before
+	if forward {
+		value = page.After
+	}
+	if value == "" {
+		return forward, nil, nil
+	}
+
+	cursor, err := repository.DecodeMessageCursor(value)
+	if err != nil {
+		return false, nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return forward, cursor, nil
+}
+
+func (uc *ChatUsecaseImpl) SyncEvents(ctx context.Context, userID string, request req.SyncRequest) (res.SyncResponse, error) {
+	uc.Log.Http.Info.Info().
 		Str("userId", userID).
-		Msg("Calculating unread counts")
+		Int("cursorCount", len(request.Cursors)).
+		Int64("globalSeq", request.GlobalSeq).
+		Msg("SyncEvents started")
+
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultSyncLimit
+	} else if limit > maxSyncLimit {
+		limit = maxSyncLimit
+	}
 
-	type result struct {
-		ChatID string
-		Count  int
+	chatIDs, err := uc.ChatRepository.FindChatIDsByUserID(ctx, uc.DB, userID)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to get chats for sync")
+		return res.SyncResponse{}, err
 	}
-	var rows []result
 
-	err := uc.DB.Table("t_message_status AS ms").
-		Select("m.chat_id, COUNT(ms.id) as count").
-		Joins("JOIN t_messages m ON m.id = ms.message_id").
-		Where("ms.user_id = ? AND ms.is_read = false", userID).
-		Group("m.chat_id").
-		Scan(&rows).Error
+	response := res.SyncResponse{
+		Events:    []res.SyncEvent{},
+		Cursors:   make(map[string]int64),
+		GlobalSeq: request.GlobalSeq,
+	}
 
+	// read before the events, so every transaction below it has ended by then
+	horizon, err := uc.EventRepository.TxHorizon(ctx, uc.DB)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to get transaction horizon")
+		return res.SyncResponse{}, err
+	}
+
+	// chats with a cursor resume from it, the rest from the global sequence
+	var chatEvents []entity.ChatEvent
+	var uncovered []string
+	for _, chatID := range chatIDs {
+		cursor, ok := request.Cursors[chatID]
+		if !ok {
+			uncovered = append(uncovered, chatID)
+			continue
+		}
+		response.Cursors[chatID] = cursor
+
+		batch, err := uc.EventRepository.FindAfterSeq(ctx, uc.DB, chatID, cursor, limit+1)
+		if err != nil {
+			uc.Log.Http.Error.Error().
+				Err(err).
+				Str("chatId", chatID).
+				Msg("Failed to get chat events")
+			return res.SyncResponse{}, err
+		}
+		chatEvents = append(chatEvents, batch...)
+	}
+
+	// ids can commit out of order across chats, so the global sequence is a
+	// transaction watermark and only ended transactions are read past it
+	upTo := horizon
+	globalEvents, err := uc.EventRepository.FindTxRange(ctx, uc.DB, uncovered, request.GlobalSeq, horizon, limit+1)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to get events after global sequence")
+		return res.SyncResponse{}, err
+	}
+	if len(globalEvents) > limit {
+		// the last transaction may be cut off, stop before it unless it is the only one
+		upTo = globalEvents[limit].TxID
+		if upTo == globalEvents[0].TxID {
+			upTo++
+			globalEvents, err = uc.EventRepository.FindTxRange(ctx, uc.DB, uncovered, request.GlobalSeq, upTo, 0)
+			if err != nil {
+				uc.Log.Http.Error.Error().
+					Err(err).
+					Str("userId", userID).
+					Msg("Failed to get events after global sequence")
+				return res.SyncResponse{}, err
+			}
+		}
+		response.HasMore = true
+	}
+
+	// chats the user was removed from while offline are no longer listed, so
+	// their removal is sent on its own once it is past the client's position
+	removals, err := uc.EventRepository.FindRemovals(ctx, uc.DB, userID)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to get chat removals")
+		return res.SyncResponse{}, err
+	}
+	for _, removal := range removals {
+		if cursor, ok := request.Cursors[removal.ChatID]; ok {
+			if removal.Seq > cursor {
+				chatEvents = append(chatEvents, removal)
+			}
+		} else if removal.TxID >= request.GlobalSeq {
+			globalEvents = append(globalEvents, removal)
+		}
+	}
+
+	events := make([]entity.ChatEvent, 0, len(globalEvents)+len(chatEvents))
+	for _, event := range globalEvents {
+		if event.TxID < upTo {
+			events = append(events, event)
+		}
+	}
+	if upTo > response.GlobalSeq {
+		response.GlobalSeq = upTo
+	}
+
+	// cursors only move over a per chat prefix, whatever budget is left
+	budget := max(limit-len(events), 0)
+	sort.Slice(chatEvents, func(i, j int) bool { return chatEvents[i].ID < chatEvents[j].ID })
+	if len(chatEvents) > budget {
+		chatEvents = chatEvents[:budget]
+		response.HasMore = true
+	}
+	for _, event := range chatEvents {
+		response.Cursors[event.ChatID] = event.Seq
+	}
+	events = append(events, chatEvents...)
+
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	for _, event := range events {
+		response.Events = append(response.Events, res.SyncEvent{
+			ChatID:    event.ChatID,
+			Seq:       event.Seq,
+			Type:      event.Type,
+			Payload:   json.RawMessage(event.Payload),
+			CreatedAt: event.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Int("eventCount", len(response.Events)).
+		Bool("hasMore", response.HasMore).
+		Msg("Successfully synced chat events")
+
+	return response, nil
+}
+
+// CountUnread counts the messages of a chat past the user's read position.
+func (uc *ChatUsecaseImpl) CountUnread(ctx context.Context, userID, chatID string) (int64, error) {
+	counts, err := uc.ChatRepository.CountUnread(ctx, uc.DB, userID, chatID)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Str("chatId", chatID).
+			Msg("Failed to count unread messages")
+		return 0, err
+	}
+	return counts[chatID], nil
+}
+
+func (uc *ChatUsecaseImpl) getUnreadCount(ctx context.Context, userID string) (map[string]int, error) {
+	uc.Log.Http.Trace.Trace().
+		Str("userId", userID).
+		Msg("Calculating unread counts")
+
+	counts, err := uc.ChatRepository.CountUnread(ctx, uc.DB, userID)
 	if err != nil {
 		uc.Log.Http.Error.Error().
 			Err(err).
@@ -363,9 +810,9 @@ func (uc *ChatUsecaseImpl) getUnreadCount(ctx context.Context, userID string) (m
 		return nil, err
 	}
 
-	unreadMap := make(map[string]int)
-	for _, r := range rows {
-		unreadMap[r.ChatID] = r.Count
+	unreadMap := make(map[string]int, len(counts))
+	for chatID, count := range counts {
+		unreadMap[chatID] = int(count)
 	}
 
 	uc.Log.Http.Trace.Trace().
@@ -375,3 +822,712 @@ func (uc *ChatUsecaseImpl) getUnreadCount(ctx context.Context, userID string) (m
 
 	return unreadMap, nil
 }
+
+func (uc *ChatUsecaseImpl) IsParticipant(ctx context.Context, chatID, userID string) (bool, error) {
+	return uc.ChatRepository.IsUserInChat(ctx, uc.DB, chatID, userID)
+}
+
+func (uc *ChatUsecaseImpl) CreateGroup(ctx context.Context, creatorID string, request req.CreateGroupRequest) (res.GroupChatResponse, error) {
+	uc.Log.Http.Info.Info().
+		Str("creatorId", creatorID).
+		Str("groupName", request.Name).
+		Msg("CreateGroup started")
+
+	if err := uc.Validate.Struct(request); err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("creatorId", creatorID).
+			Msg("Validation failed for create group request")
+		return res.GroupChatResponse{}, ErrInvalidRequest
+	}
+
+	memberIDs := uniqueIDs(request.MemberIDs, creatorID)
+	if len(memberIDs) == 0 {
+		uc.Log.Http.Warning.Warn().
+			Str("creatorId", creatorID).
+			Msg("Group has no members besides the creator")
+		return res.GroupChatResponse{}, fmt.Errorf("%w: group needs at least one other member", ErrInvalidRequest)
+	}
+
+	if err := uc.ensureUsersExist(ctx, memberIDs); err != nil {
+		return res.GroupChatResponse{}, err
+	}
+
+	chat, err := uc.CreateGroupChat(ctx, request.Name, creatorID, memberIDs)
+	if err != nil {
+		return res.GroupChatResponse{}, err
+	}
+
+	return uc.groupResponse(ctx, chat)
+}
+
+func (uc *ChatUsecaseImpl) ListParticipants(ctx context.Context, userID, chatID string) ([]res.ParticipantResponse, error) {
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Str("chatId", chatID).
+		Msg("ListParticipants started")
+
+	isParticipant, err := uc.ChatRepository.IsUserInChat(ctx, uc.DB, chatID, userID)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Str("userId", userID).
+			Msg("Failed to verify participant")
+		return nil, err
+	}
+	if !isParticipant {
+		uc.Log.Http.Warning.Warn().
+			Str("chatId", chatID).
+			Str("userId", userID).
+			Msg("User not authorized for this chat")
+		return nil, ErrNotParticipant
+	}
+
+	participants, err := uc.ChatRepository.FindParticipants(ctx, uc.DB, chatID)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Failed to get chat participants")
+		return nil, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("chatId", chatID).
+		Int("participantCount", len(participants)).
+		Msg("Successfully retrieved participants")
+
+	return participantResponses(participants), nil
+}
+
+func (uc *ChatUsecaseImpl) RenameGroup(ctx context.Context, actorID, chatID string, request req.UpdateGroupRequest) (ws.GroupRenamedPayload, error) {
+	uc.Log.Http.Info.Info().
+		Str("actorId", actorID).
+		Str("chatId", chatID).
+		Str("groupName", request.Name).
+		Msg("RenameGroup started")
+
+	if err := uc.Validate.Struct(request); err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Validation failed for rename group request")
+		return ws.GroupRenamedPayload{}, ErrInvalidRequest
+	}
+
+	if _, _, err := uc.authorizeGroupAction(ctx, chatID, actorID, actionRename); err != nil {
+		return ws.GroupRenamedPayload{}, err
+	}
+
+	payload := ws.GroupRenamedPayload{
+		ChatID:    chatID,
+		ActorID:   actorID,
+		GroupName: request.Name,
+	}
+
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := uc.ChatRepository.UpdateGroupName(ctx, tx, chatID, request.Name); err != nil {
+			return err
+		}
+		return uc.appendEvent(ctx, tx, chatID, ws.TypeGroupRenamed, &payload.Seq, &payload)
+	})
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Failed to rename group")
+		return ws.GroupRenamedPayload{}, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("chatId", chatID).
+		Str("groupName", request.Name).
+		Msg("Group renamed successfully")
+
+	return payload, nil
+}
+
+func (uc *ChatUsecaseImpl) AddParticipants(ctx context.Context, actorID, chatID string, request req.ParticipantsRequest) (ws.MemberAddedPayload, error) {
+	uc.Log.Http.Info.Info().
+		Str("actorId", actorID).
+		Str("chatId", chatID).
+		Int("userCount", len(request.UserIDs)).
+		Msg("AddParticipants started")
+
+	if err := uc.Validate.Struct(request); err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Validation failed for add participants request")
+		return ws.MemberAddedPayload{}, ErrInvalidRequest
+	}
+
+	chat, _, err := uc.authorizeGroupAction(ctx, chatID, actorID, actionInvite)
+	if err != nil {
+		return ws.MemberAddedPayload{}, err
+	}
+
+	participants, err := uc.ChatRepository.FindParticipants(ctx, uc.DB, chatID)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Failed to get chat participants")
+		return ws.MemberAddedPayload{}, err
+	}
+
+	existing := make(map[string]struct{}, len(participants))
+	for _, p := range participants {
+		existing[p.UserID] = struct{}{}
+	}
+
+	var newIDs []string
+	var members []ws.MemberRole
+	for _, id := range uniqueIDs(request.UserIDs, "") {
+		if _, ok := existing[id]; !ok {
+			newIDs = append(newIDs, id)
+			members = append(members, ws.MemberRole{UserID: id, Role: string(enum.RoleMember)})
+		}
+	}
+	if len(newIDs) == 0 {
+		uc.Log.Http.Warning.Warn().
+			Str("chatId", chatID).
+			Msg("All users are already participants")
+		return ws.MemberAddedPayload{}, fmt.Errorf("%w: users are already participants", ErrInvalidRequest)
+	}
+
+	if err := uc.ensureUsersExist(ctx, newIDs); err != nil {
+		return ws.MemberAddedPayload{}, err
+	}
+
+	payload := ws.MemberAddedPayload{
+		ChatID:    chatID,
+		GroupName: chat.GroupName,
+		ActorID:   actorID,
+		UserIDs:   newIDs,
+		Members:   members,
+	}
+
+	err = uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := uc.ChatRepository.AddParticipants(ctx, tx, chatID, newIDs); err != nil {
+			return err
+		}
+		return uc.appendEvent(ctx, tx, chatID, ws.TypeMemberAdded, &payload.Seq, &payload)
+	})
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Failed to add participants")
+		return ws.MemberAddedPayload{}, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("chatId", chatID).
+		Int("addedCount", len(newIDs)).
+		Msg("Participants added successfully")
+
+	return payload, nil
+}
+
+func (uc *ChatUsecaseImpl) RemoveParticipants(ctx context.Context, actorID, chatID string, request req.ParticipantsRequest) (ws.MemberRemovedPayload, error) {
+	uc.Log.Http.Info.Info().
+		Str("actorId", actorID).
+		Str("chatId", chatID).
+		Int("userCount", len(request.UserIDs)).
+		Msg("RemoveParticipants started")
+
+	if err := uc.Validate.Struct(request); err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Validation failed for remove participants request")
+		return ws.MemberRemovedPayload{}, ErrInvalidRequest
+	}
+
+	_, actor, err := uc.authorizeGroupAction(ctx, chatID, actorID, actionKick)
+	if err != nil {
+		return ws.MemberRemovedPayload{}, err
+	}
+
+	participants, err := uc.ChatRepository.FindParticipants(ctx, uc.DB, chatID)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Failed to get chat participants")
+		return ws.MemberRemovedPayload{}, err
+	}
+
+	existing := make(map[string]enum.ParticipantRole, len(participants))
+	for _, p := range participants {
+		existing[p.UserID] = p.Role
+	}
+
+	var members []ws.MemberRole
+	for _, id := range uniqueIDs(request.UserIDs, actorID) {
+		role, ok := existing[id]
+		if !ok {
+			continue
+		}
+		if !outranks(actor.Role, role) {
+			uc.Log.Http.Warning.Warn().
+				Str("chatId", chatID).
+				Str("actorId", actorID).
+				Str("targetId", id).
+				Str("targetRole", string(role)).
+				Msg("Actor cannot remove a participant of equal or higher role")
+			return ws.MemberRemovedPayload{}, ErrForbidden
+		}
+		members = append(members, ws.MemberRole{UserID: id, Role: string(role)})
+	}
+	if len(members) == 0 {
+		uc.Log.Http.Warning.Warn().
+			Str("chatId", chatID).
+			Msg("None of the users are participants")
+		return ws.MemberRemovedPayload{}, fmt.Errorf("%w: users are not participants", ErrInvalidRequest)
+	}
+
+	return uc.removeParticipants(ctx, actorID, chatID, members, false)
+}
+
+func (uc *ChatUsecaseImpl) LeaveGroup(ctx context.Context, userID, chatID string) (ws.MemberRemovedPayload, error) {
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Str("chatId", chatID).
+		Msg("LeaveGroup started")
+
+	_, participant, err := uc.findGroupForMember(ctx, chatID, userID)
+	if err != nil {
+		return ws.MemberRemovedPayload{}, err
+	}
+
+	return uc.removeParticipants(ctx, userID, chatID, []ws.MemberRole{{UserID: userID, Role: string(participant.Role)}}, true)
+}
+
+func (uc *ChatUsecaseImpl) UpdateParticipantRole(ctx context.Context, actorID, chatID, targetID string, request req.UpdateRoleRequest) (ws.RoleChangedPayload, error) {
+	uc.Log.Http.Info.Info().
+		Str("actorId", actorID).
+		Str("chatId", chatID).
+		Str("targetId", targetID).
+		Str("role", request.Role).
+		Msg("UpdateParticipantRole started")
+
+	if err := uc.Validate.Struct(request); err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Validation failed for update role request")
+		return ws.RoleChangedPayload{}, ErrInvalidRequest
+	}
+
+	if _, _, err := uc.authorizeGroupAction(ctx, chatID, actorID, actionChangeRole); err != nil {
+		return ws.RoleChangedPayload{}, err
+	}
+
+	target, err := uc.findTargetParticipant(ctx, chatID, targetID)
+	if err != nil {
+		return ws.RoleChangedPayload{}, err
+	}
+	if target.Role == enum.RoleOwner {
+		uc.Log.Http.Warning.Warn().
+			Str("chatId", chatID).
+			Str("targetId", targetID).
+			Msg("Owner role can only change through ownership transfer")
+		return ws.RoleChangedPayload{}, fmt.Errorf("%w: use ownership transfer to change the owner", ErrInvalidRequest)
+	}
+
+	role := enum.ParticipantRole(request.Role)
+	payload := ws.RoleChangedPayload{
+		ChatID:  chatID,
+		ActorID: actorID,
+		Changes: []ws.MemberRole{{UserID: targetID, Role: string(role)}},
+	}
+
+	err = uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := uc.ChatRepository.UpdateParticipantRole(ctx, tx, chatID, targetID, role); err != nil {
+			return err
+		}
+		return uc.appendEvent(ctx, tx, chatID, ws.TypeRoleChanged, &payload.Seq, &payload)
+	})
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Str("targetId", targetID).
+			Msg("Failed to update participant role")
+		return ws.RoleChangedPayload{}, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("chatId", chatID).
+		Str("targetId", targetID).
+		Str("role", string(role)).
+		Msg("Participant role updated successfully")
+
+	return payload, nil
+}
+
+func (uc *ChatUsecaseImpl) TransferOwnership(ctx context.Context, actorID, chatID string, request req.TransferOwnershipRequest) (ws.RoleChangedPayload, error) {
+	uc.Log.Http.Info.Info().
+		Str("actorId", actorID).
+		Str("chatId", chatID).
+		Str("newOwnerId", request.UserID).
+		Msg("TransferOwnership started")
+
+	if err := uc.Validate.Struct(request); err != nil || request.UserID == actorID {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Validation failed for transfer ownership request")
+		return ws.RoleChangedPayload{}, ErrInvalidRequest
+	}
+
+	if _, _, err := uc.authorizeGroupAction(ctx, chatID, actorID, actionTransfer); err != nil {
+		return ws.RoleChangedPayload{}, err
+	}
+
+	if _, err := uc.findTargetParticipant(ctx, chatID, request.UserID); err != nil {
+		return ws.RoleChangedPayload{}, err
+	}
+
+	// the previous owner stays on as an admin
+	payload := ws.RoleChangedPayload{
+		ChatID:  chatID,
+		ActorID: actorID,
+		Changes: []ws.MemberRole{
+			{UserID: request.UserID, Role: string(enum.RoleOwner)},
+			{UserID: actorID, Role: string(enum.RoleAdmin)},
+		},
+	}
+
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := uc.ChatRepository.UpdateParticipantRole(ctx, tx, chatID, actorID, enum.RoleAdmin); err != nil {
+			return err
+		}
+		if err := uc.ChatRepository.UpdateParticipantRole(ctx, tx, chatID, request.UserID, enum.RoleOwner); err != nil {
+			return err
+		}
+		return uc.appendEvent(ctx, tx, chatID, ws.TypeRoleChanged, &payload.Seq, &payload)
+	})
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Failed to transfer ownership")
+		return ws.RoleChangedPayload{}, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("chatId", chatID).
+		Str("newOwnerId", request.UserID).
+		Msg("Ownership transferred successfully")
+
+	return payload, nil
+}
+
+func (uc *ChatUsecaseImpl) DeleteGroup(ctx context.Context, actorID, chatID string) (ws.GroupDeletedPayload, []string, error) {
+	uc.Log.Http.Info.Info().
+		Str("actorId", actorID).
+		Str("chatId", chatID).
+		Msg("DeleteGroup started")
+
+	chat, _, err := uc.authorizeGroupAction(ctx, chatID, actorID, actionDelete)
+	if err != nil {
+		return ws.GroupDeletedPayload{}, nil, err
+	}
+
+	participants, err := uc.ChatRepository.FindParticipants(ctx, uc.DB, chatID)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Failed to get chat participants")
+		return ws.GroupDeletedPayload{}, nil, err
+	}
+
+	userIDs := make([]string, 0, len(participants))
+	for _, p := range participants {
+		userIDs = append(userIDs, p.UserID)
+	}
+
+	payload := ws.GroupDeletedPayload{
+		ChatID:  chatID,
+		ActorID: actorID,
+	}
+
+	// participants are kept so members can still sync the deletion event
+	err = uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := uc.appendEvent(ctx, tx, chatID, ws.TypeGroupDeleted, &payload.Seq, &payload); err != nil {
+			return err
+		}
+		return uc.ChatRepository.Delete(ctx, tx, chat)
+	})
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Failed to delete group")
+		return ws.GroupDeletedPayload{}, nil, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("chatId", chatID).
+		Msg("Group deleted successfully")
+
+	return payload, userIDs, nil
+}
+
+// removeParticipants deletes the memberships and, when the owner is among
+// them, hands ownership to the longest-standing admin, or member if none.
+func (uc *ChatUsecaseImpl) removeParticipants(ctx context.Context, actorID, chatID string, members []ws.MemberRole, left bool) (ws.MemberRemovedPayload, error) {
+	userIDs := make([]string, 0, len(members))
+	ownerRemoved := false
+	for _, m := range members {
+		userIDs = append(userIDs, m.UserID)
+		if m.Role == string(enum.RoleOwner) {
+			ownerRemoved = true
+		}
+	}
+
+	payload := ws.MemberRemovedPayload{
+		ChatID:  chatID,
+		ActorID: actorID,
+		UserIDs: userIDs,
+		Members: members,
+		Left:    left,
+	}
+
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := uc.ChatRepository.RemoveParticipants(ctx, tx, chatID, userIDs); err != nil {
+			return err
+		}
+
+		if ownerRemoved {
+			successor, err := uc.pickSuccessor(ctx, tx, chatID)
+			if err != nil {
+				return err
+			}
+			if successor != nil {
+				if err := uc.ChatRepository.UpdateParticipantRole(ctx, tx, chatID, successor.UserID, enum.RoleOwner); err != nil {
+					return err
+				}
+				payload.RoleChanges = []ws.MemberRole{{UserID: successor.UserID, Role: string(enum.RoleOwner)}}
+			}
+		}
+
+		return uc.appendEvent(ctx, tx, chatID, ws.TypeMemberRemoved, &payload.Seq, &payload)
+	})
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Bool("left", left).
+			Msg("Failed to remove participants")
+		return ws.MemberRemovedPayload{}, err
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("chatId", chatID).
+		Strs("userIds", userIDs).
+		Bool("left", left).
+		Int("roleChanges", len(payload.RoleChanges)).
+		Msg("Participants removed successfully")
+
+	return payload, nil
+}
+
+func (uc *ChatUsecaseImpl) pickSuccessor(ctx context.Context, tx *gorm.DB, chatID string) (*entity.ChatParticipant, error) {
+	remaining, err := uc.ChatRepository.FindParticipants(ctx, tx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	var successor *entity.ChatParticipant
+	for i := range remaining {
+		if remaining[i].Role == enum.RoleAdmin {
+			return &remaining[i], nil
+		}
+		if successor == nil {
+			successor = &remaining[i]
+		}
+	}
+	return successor, nil
+}
+
+// authorizeGroupAction loads the group and the actor's membership and checks
+// the actor's role against the permission matrix.
+func (uc *ChatUsecaseImpl) authorizeGroupAction(ctx context.Context, chatID, actorID string, action groupAction) (*entity.Chat, *entity.ChatParticipant, error) {
+	chat, actor, err := uc.findGroupForMember(ctx, chatID, actorID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !canPerform(actor.Role, action) {
+		uc.Log.Http.Warning.Warn().
+			Str("chatId", chatID).
+			Str("actorId", actorID).
+			Str("role", string(actor.Role)).
+			Str("action", string(action)).
+			Msg("Role not permitted to perform group action")
+		return nil, nil, ErrForbidden
+	}
+
+	return chat, actor, nil
+}
+
+// findGroupForMember loads a group chat and the membership of userID in it.
+func (uc *ChatUsecaseImpl) findGroupForMember(ctx context.Context, chatID, userID string) (*entity.Chat, *entity.ChatParticipant, error) {
+	chat, err := uc.ChatRepository.FindChatByID(ctx, uc.DB, chatID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			uc.Log.Http.Warning.Warn().
+				Str("chatId", chatID).
+				Msg("Chat not found")
+			return nil, nil, ErrChatNotFound
+		}
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Msg("Failed to find chat")
+		return nil, nil, err
+	}
+
+	if chat.ChatType != enum.GROUP {
+		uc.Log.Http.Warning.Warn().
+			Str("chatId", chatID).
+			Str("chatType", string(chat.ChatType)).
+			Msg("Chat is not a group chat")
+		return nil, nil, ErrNotGroupChat
+	}
+
+	participant, err := uc.ChatRepository.FindParticipant(ctx, uc.DB, chatID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			uc.Log.Http.Warning.Warn().
+				Str("chatId", chatID).
+				Str("userId", userID).
+				Msg("User not authorized for this chat")
+			return nil, nil, ErrNotParticipant
+		}
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Str("userId", userID).
+			Msg("Failed to verify participant")
+		return nil, nil, err
+	}
+
+	return chat, participant, nil
+}
+
+func (uc *ChatUsecaseImpl) findTargetParticipant(ctx context.Context, chatID, userID string) (*entity.ChatParticipant, error) {
+	target, err := uc.ChatRepository.FindParticipant(ctx, uc.DB, chatID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			uc.Log.Http.Warning.Warn().
+				Str("chatId", chatID).
+				Str("targetId", userID).
+				Msg("Target user is not a participant")
+			return nil, fmt.Errorf("%w: user is not a participant", ErrInvalidRequest)
+		}
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Str("targetId", userID).
+			Msg("Failed to find target participant")
+		return nil, err
+	}
+	return target, nil
+}
+
+// appendEvent records an event in the chat log, storing its sequence in seq
+// before the payload is serialized.
+func (uc *ChatUsecaseImpl) appendEvent(ctx context.Context, tx *gorm.DB, chatID, eventType string, seq *int64, payload interface{}) error {
+	next, err := uc.EventRepository.NextSeq(ctx, tx, chatID)
+	if err != nil {
+		return err
+	}
+	*seq = next
+
+	_, err = uc.EventRepository.AppendWithSeq(ctx, tx, chatID, next, eventType, payload)
+	return err
+}
+
+func (uc *ChatUsecaseImpl) ensureUsersExist(ctx context.Context, userIDs []string) error {
+	var count int64
+	if err := uc.DB.WithContext(ctx).Model(&entity.User{}).Where("id IN ?", userIDs).Count(&count).Error; err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Msg("Failed to verify users")
+		return err
+	}
+
+	if count != int64(len(userIDs)) {
+		uc.Log.Http.Warning.Warn().
+			Int("requested", len(userIDs)).
+			Int64("found", count).
+			Msg("Some users do not exist")
+		return fmt.Errorf("%w: unknown user", ErrInvalidRequest)
+	}
+	return nil
+}
+
+func (uc *ChatUsecaseImpl) groupResponse(ctx context.Context, chat *entity.Chat) (res.GroupChatResponse, error) {
+	participants, err := uc.ChatRepository.FindParticipants(ctx, uc.DB, chat.ID)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chat.ID).
+			Msg("Failed to get chat participants")
+		return res.GroupChatResponse{}, err
+	}
+
+	response := res.GroupChatResponse{
+		ChatID:       chat.ID,
+		GroupName:    chat.GroupName,
+		ChatType:     string(chat.ChatType),
+		Participants: participantResponses(participants),
+		CreatedAt:    chat.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	return response, nil
+}
+
+func participantResponses(participants []entity.ChatParticipant) []res.ParticipantResponse {
+	responses := make([]res.ParticipantResponse, 0, len(participants))
+	for _, p := range participants {
+		responses = append(responses, res.ParticipantResponse{
+			UserID:   p.UserID,
+			Name:     p.User.Name,
+			Avatar:   p.User.Avatar,
+			Role:     string(p.Role),
+			JoinedAt: p.JoinedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return responses
+}
+
+// uniqueIDs drops blanks, duplicates and the excluded id, keeping input order.
+func uniqueIDs(ids []string, exclude string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || id == exclude {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		result = append(result, id)
+	}
+	return result
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
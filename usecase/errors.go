@@ -0,0 +1,31 @@
+package usecase
+
+import "errors"
+
+// Errors handlers map to client-facing status codes.
+var (
+	ErrNotParticipant       = errors.New("user not authorized for this chat")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+	ErrInvalidRequest       = errors.New("invalid request data")
+	ErrChatNotFound         = errors.New("chat not found")
+	ErrNotGroupChat         = errors.New("chat is not a group chat")
+	ErrForbidden            = errors.New("insufficient permissions")
+	ErrMessageNotFound      = errors.New("message not found")
+	ErrEditWindowExpired    = errors.New("edit window has expired")
+	ErrAttachmentNotFound   = errors.New("attachment not found")
+	ErrAttachmentTooLarge   = errors.New("attachment exceeds the size limit")
+	ErrUnsupportedMediaType = errors.New("attachment type is not allowed")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrInvalidRefreshToken  = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused   = errors.New("refresh token was already used")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrTooManyAttempts      = errors.New("too many failed login attempts, try again later")
+	ErrAccountLocked        = errors.New("account is temporarily locked")
+	ErrInvalidPassword      = errors.New("invalid password")
+	ErrInvalidChallenge     = errors.New("invalid or expired two-factor challenge")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotPending  = errors.New("two-factor setup has not been started")
+	ErrTwoFactorUnavailable = errors.New("two-factor authentication is not configured")
+)
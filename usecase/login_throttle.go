@@ -0,0 +1,204 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"real-time-chat-app/dto/req"
+	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
+	"time"
+)
+
+// LoginBlockedError rejects a login without checking the password. Err is
+// ErrTooManyAttempts during backoff and ErrAccountLocked during a lockout.
+type LoginBlockedError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return e.Err
+}
+
+type loginSubject struct {
+	scope   enum.LoginAttemptScope
+	subject string
+}
+
+func loginSubjects(request *req.LoginRequest) []loginSubject {
+	subjects := []loginSubject{{scope: enum.LoginScopeUsername, subject: request.Username}}
+	if request.IP != "" {
+		subjects = append(subjects, loginSubject{scope: enum.LoginScopeIP, subject: request.IP})
+	}
+	return subjects
+}
+
+// checkLoginAllowed fails while the username or the IP of the request is
+// backing off or locked out. Rejected attempts are audited but not counted.
+func (uc *AuthUsecaseImpl) checkLoginAllowed(ctx context.Context, request *req.LoginRequest, now time.Time) error {
+	var retryAfter time.Duration
+	locked := false
+	for _, subject := range loginSubjects(request) {
+		attempt, err := uc.LoginAttemptRepository.Find(ctx, uc.DB, subject.scope, subject.subject)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return err
+		}
+		if attempt.BlockedUntil == nil || !attempt.BlockedUntil.After(now) {
+			continue
+		}
+
+		if wait := attempt.BlockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+		locked = locked || attempt.Locked
+	}
+	if retryAfter == 0 {
+		return nil
+	}
+
+	blocked := &LoginBlockedError{Err: ErrTooManyAttempts, RetryAfter: retryAfter}
+	reason := enum.LoginThrottled
+	if locked {
+		blocked.Err = ErrAccountLocked
+		reason = enum.LoginLocked
+	}
+	uc.auditLoginFailure(ctx, uc.DB, request, nil, reason)
+	return blocked
+}
+
+// checkAccountLocked fails while the account itself is locked, which also
+// covers locks set by hand.
+func (uc *AuthUsecaseImpl) checkAccountLocked(ctx context.Context, request *req.LoginRequest, account *entity.Account, now time.Time) error {
+	if account.LockedUntil == nil || !account.LockedUntil.After(now) {
+		return nil
+	}
+
+	uc.Log.Http.Warning.Warn().
+		Str("username", request.Username).
+		Str("userId", account.User.ID).
+		Time("lockedUntil", *account.LockedUntil).
+		Msg("Login attempt on locked account")
+	uc.auditLoginFailure(ctx, uc.DB, request, account, enum.LoginLocked)
+	return &LoginBlockedError{Err: ErrAccountLocked, RetryAfter: account.LockedUntil.Sub(now)}
+}
+
+// recordLoginFailure counts a failed login against the username and the IP and
+// locks the account once the username reaches the lockout threshold.
+func (uc *AuthUsecaseImpl) recordLoginFailure(ctx context.Context, request *req.LoginRequest, account *entity.Account, reason enum.LoginFailureReason, now time.Time) {
+	err := uc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		uc.auditLoginFailure(ctx, tx, request, account, reason)
+
+		for _, subject := range loginSubjects(request) {
+			attempt, err := uc.LoginAttemptRepository.Lock(ctx, tx, subject.scope, subject.subject)
+			if err != nil {
+				return err
+			}
+
+			wasLocked := attempt.Locked
+			uc.applyLoginFailure(attempt, now)
+			if err := uc.LoginAttemptRepository.Update(ctx, tx, attempt); err != nil {
+				return err
+			}
+			if !attempt.Locked || wasLocked || account == nil {
+				continue
+			}
+
+			if err := uc.AuthRepository.SetLockedUntil(ctx, tx, account.ID, attempt.BlockedUntil); err != nil {
+				return err
+			}
+			uc.Log.Http.Warning.Warn().
+				Str("username", request.Username).
+				Str("userId", account.User.ID).
+				Int("failures", attempt.Failures).
+				Time("lockedUntil", *attempt.BlockedUntil).
+				Msg("Account locked after repeated failed logins")
+		}
+		return nil
+	})
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("username", request.Username).
+			Str("ip", request.IP).
+			Msg("Failed to record failed login")
+	}
+}
+
+// applyLoginFailure counts one failure. Past the free attempts every failure
+// doubles the wait, a username reaching the threshold is locked out instead.
+func (uc *AuthUsecaseImpl) applyLoginFailure(attempt *entity.LoginAttempt, now time.Time) {
+	if now.Sub(attempt.LastFailedAt) > uc.LoginConfig.Window {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailedAt = now
+	attempt.Locked = false
+
+	freeAttempts := uc.LoginConfig.FreeAttempts
+	if attempt.Scope == enum.LoginScopeIP {
+		freeAttempts = uc.LoginConfig.IPFreeAttempts
+	}
+
+	switch {
+	case attempt.Scope == enum.LoginScopeUsername && attempt.Failures >= uc.LoginConfig.LockoutThreshold:
+		lockedUntil := now.Add(uc.LoginConfig.LockoutDuration)
+		attempt.BlockedUntil = &lockedUntil
+		attempt.Locked = true
+	case attempt.Failures > freeAttempts:
+		delay := uc.LoginConfig.BackoffBase
+		for i := freeAttempts + 1; i < attempt.Failures && delay < uc.LoginConfig.BackoffMax; i++ {
+			delay *= 2
+		}
+		if delay > uc.LoginConfig.BackoffMax {
+			delay = uc.LoginConfig.BackoffMax
+		}
+		blockedUntil := now.Add(delay)
+		attempt.BlockedUntil = &blockedUntil
+	default:
+		attempt.BlockedUntil = nil
+	}
+}
+
+// resetLoginFailures forgets the failures of a username after it logged in.
+// The IP counter is left to expire so one valid account cannot clear it.
+func (uc *AuthUsecaseImpl) resetLoginFailures(ctx context.Context, tx *gorm.DB, account *entity.Account) error {
+	if err := uc.LoginAttemptRepository.Reset(ctx, tx, enum.LoginScopeUsername, account.UserName); err != nil {
+		return err
+	}
+	if account.LockedUntil == nil {
+		return nil
+	}
+	return uc.AuthRepository.SetLockedUntil(ctx, tx, account.ID, nil)
+}
+
+func (uc *AuthUsecaseImpl) auditLoginFailure(ctx context.Context, db *gorm.DB, request *req.LoginRequest, account *entity.Account, reason enum.LoginFailureReason) {
+	userAgent := request.UserAgent
+	if runes := []rune(userAgent); len(runes) > maxUserAgentLength {
+		userAgent = string(runes[:maxUserAgentLength])
+	}
+	failure := &entity.FailedLogin{
+		Username:  request.Username,
+		IP:        request.IP,
+		UserAgent: userAgent,
+		Reason:    reason,
+	}
+	if account != nil {
+		failure.UserID = &account.User.ID
+	}
+
+	if err := uc.LoginAttemptRepository.Audit(ctx, db, failure); err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("username", request.Username).
+			Str("reason", string(reason)).
+			Msg("Failed to audit failed login")
+	}
+}
@@ -0,0 +1,263 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"real-time-chat-app/config/common"
+	"real-time-chat-app/config/logger"
+	"real-time-chat-app/dto/ws"
+	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
+	"real-time-chat-app/media"
+	"real-time-chat-app/repository"
+	"real-time-chat-app/storage"
+	"time"
+)
+
+const (
+	previewJPEGQuality  = 82
+	blurHashSampleSize  = 32
+	blurHashXComponents = 4
+	blurHashYComponents = 3
+	// a claim older than this belongs to a node that died while rendering
+	mediaClaimTimeout = 10 * time.Minute
+)
+
+// RoomNotifier pushes an event to everyone in a chat, the websocket handler
+// implements it.
+type RoomNotifier interface {
+	NotifyRoom(chatID string, event ws.Event)
+}
+
+// MediaProcessor generates thumbnails, previews and blurhash placeholders for
+// uploaded images on a pool of background workers.
+type MediaProcessor struct {
+	db                   *gorm.DB
+	attachmentRepository *repository.AttachmentRepository
+	eventRepository      *repository.ChatEventRepository
+	store                storage.BlobStore
+	notifier             RoomNotifier
+	config               common.MediaConfig
+	log                  *logger.AppLogger
+	queue                chan string
+}
+
+func NewMediaProcessor(db *gorm.DB, attachmentRepository *repository.AttachmentRepository, eventRepository *repository.ChatEventRepository, store storage.BlobStore, notifier RoomNotifier, config common.MediaConfig, logger *logger.AppLogger) *MediaProcessor {
+	return &MediaProcessor{
+		db:                   db,
+		attachmentRepository: attachmentRepository,
+		eventRepository:      eventRepository,
+		store:                store,
+		notifier:             notifier,
+		config:               config,
+		log:                  logger,
+		queue:                make(chan string, config.QueueSize),
+	}
+}
+
+// Start launches the workers and requeues images a previous run left unfinished.
+func (p *MediaProcessor) Start() {
+	for i := 0; i < p.config.Workers; i++ {
+		go p.work()
+	}
+
+	pending, err := p.attachmentRepository.FindByMediaStatus(context.Background(), p.db, enum.MediaStatusProcessing)
+	if err != nil {
+		p.log.Http.Error.Error().
+			Err(err).
+			Msg("Failed to load unprocessed images")
+		return
+	}
+	for _, attachment := range pending {
+		go func(id string) { p.queue <- id }(attachment.ID)
+	}
+
+	p.log.Http.Info.Info().
+		Int("workers", p.config.Workers).
+		Int("requeued", len(pending)).
+		Msg("Media processor started")
+}
+
+// Enqueue schedules an image, it reports false when the queue is full.
+func (p *MediaProcessor) Enqueue(attachmentID string) bool {
+	select {
+	case p.queue <- attachmentID:
+		return true
+	default:
+		p.log.Http.Warning.Warn().
+			Str("attachmentId", attachmentID).
+			Int("queueSize", cap(p.queue)).
+			Msg("Media queue is full")
+		return false
+	}
+}
+
+func (p *MediaProcessor) work() {
+	for attachmentID := range p.queue {
+		p.process(attachmentID)
+	}
+}
+
+func (p *MediaProcessor) process(attachmentID string) {
+	ctx := context.Background()
+
+	p.log.Http.Trace.Trace().
+		Str("attachmentId", attachmentID).
+		Msg("Processing image")
+
+	// every node requeues unfinished images at start, only one renders each
+	now := time.Now()
+	claimed, err := p.attachmentRepository.ClaimMedia(ctx, p.db, attachmentID, now, now.Add(-mediaClaimTimeout))
+	if err != nil {
+		p.log.Http.Error.Error().
+			Err(err).
+			Str("attachmentId", attachmentID).
+			Msg("Failed to claim image")
+		return
+	}
+	if !claimed {
+		p.log.Http.Trace.Trace().
+			Str("attachmentId", attachmentID).
+			Msg("Image is processed elsewhere or already done")
+		return
+	}
+
+	var attachment entity.Attachment
+	if err := p.attachmentRepository.FindById(ctx, p.db, &attachment, attachmentID); err != nil {
+		// deleted before its turn came
+		p.log.Http.Warning.Warn().
+			Err(err).
+			Str("attachmentId", attachmentID).
+			Msg("Image to process not found")
+		return
+	}
+
+	status := enum.MediaStatusReady
+	if err := p.render(ctx, &attachment); err != nil {
+		p.log.Http.Error.Error().
+			Err(err).
+			Str("attachmentId", attachmentID).
+			Msg("Failed to process image")
+		status = enum.MediaStatusFailed
+		attachment.ThumbnailKey, attachment.PreviewKey, attachment.BlurHash = "", "", ""
+	}
+	attachment.MediaStatus = status
+
+	if err := p.finish(ctx, &attachment); err != nil {
+		p.log.Http.Error.Error().
+			Err(err).
+			Str("attachmentId", attachmentID).
+			Msg("Failed to record processed image")
+	}
+}
+
+// render writes the thumbnail and preview variants and computes the blurhash.
+func (p *MediaProcessor) render(ctx context.Context, attachment *entity.Attachment) error {
+	// dimensions come from the header at upload, huge images are never decoded
+	if attachment.Width*attachment.Height > p.config.MaxPixels {
+		return fmt.Errorf("image of %dx%d exceeds %d pixels", attachment.Width, attachment.Height, p.config.MaxPixels)
+	}
+
+	blob, err := p.store.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	// the pixel check only saw the first frame, so frames past it stay undecoded
+	var img image.Image
+	if attachment.MimeType == "image/gif" {
+		img, err = gif.Decode(blob)
+	} else {
+		img, _, err = image.Decode(blob)
+	}
+	if err != nil {
+		return err
+	}
+
+	thumbnail := media.Fit(img, p.config.ThumbnailSize)
+	attachment.ThumbnailKey = attachment.StorageKey + "_" + string(enum.MediaVariantThumbnail)
+	if err := p.putJPEG(ctx, attachment.ThumbnailKey, thumbnail); err != nil {
+		return err
+	}
+
+	attachment.PreviewKey = attachment.StorageKey + "_" + string(enum.MediaVariantPreview)
+	if err := p.putJPEG(ctx, attachment.PreviewKey, media.Fit(img, p.config.PreviewSize)); err != nil {
+		return err
+	}
+
+	attachment.BlurHash, err = media.BlurHash(media.Fit(thumbnail, blurHashSampleSize), blurHashXComponents, blurHashYComponents)
+	return err
+}
+
+func (p *MediaProcessor) putJPEG(ctx context.Context, key string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: previewJPEGQuality}); err != nil {
+		return err
+	}
+	return p.store.Put(ctx, key, &buf)
+}
+
+// finish stores the result and, once a message carries the image, logs and
+// broadcasts image_ready. Uploads not sent yet pick the result up when sent.
+func (p *MediaProcessor) finish(ctx context.Context, attachment *entity.Attachment) error {
+	var payload *ws.ImageReadyPayload
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the lock orders this against linking the upload to a message
+		var current entity.Attachment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", attachment.ID).
+			First(&current).Error
+		if err != nil {
+			return err
+		}
+
+		if err := p.attachmentRepository.UpdateMedia(ctx, tx, attachment); err != nil {
+			return err
+		}
+		if current.MessageID == nil {
+			return nil
+		}
+
+		seq, err := p.eventRepository.NextSeq(ctx, tx, current.ChatID)
+		if err != nil {
+			return err
+		}
+
+		attachment.MessageID = current.MessageID
+		payload = &ws.ImageReadyPayload{
+			ChatID:     current.ChatID,
+			MessageID:  *current.MessageID,
+			Attachment: attachmentOf(*attachment),
+			Seq:        seq,
+		}
+		_, err = p.eventRepository.AppendWithSeq(ctx, tx, current.ChatID, seq, ws.TypeImageReady, payload)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// deleted while rendering, its blobs were removed before the variants existed
+			deleteAttachmentBlobs(ctx, p.store, p.log, *attachment)
+			return nil
+		}
+		return err
+	}
+
+	p.log.Http.Info.Info().
+		Str("attachmentId", attachment.ID).
+		Str("mediaStatus", string(attachment.MediaStatus)).
+		Bool("sent", payload != nil).
+		Msg("Image processed")
+
+	if payload != nil {
+		p.notifier.NotifyRoom(payload.ChatID, ws.Event{Type: ws.TypeImageReady, Payload: payload})
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"real-time-chat-app/dto/req"
+	"real-time-chat-app/dto/res"
+	"real-time-chat-app/repository"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultSearchLimit  = 20
+	maxSearchLimit      = 50
+	maxSearchQueryRunes = 256
+)
+
+func (uc *ChatUsecaseImpl) SearchMessages(ctx context.Context, userID string, request req.MessageSearchRequest) (res.MessageSearchResponse, error) {
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Str("chatId", request.ChatID).
+		Str("senderId", request.SenderID).
+		Int("queryLength", len(request.Query)).
+		Msg("SearchMessages started")
+
+	filter, err := parseMessageSearch(userID, request)
+	if err != nil {
+		uc.Log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Msg("Invalid search parameters")
+		return res.MessageSearchResponse{}, err
+	}
+
+	if filter.ChatID != "" {
+		isParticipant, err := uc.IsParticipant(ctx, filter.ChatID, userID)
+		if err != nil {
+			return res.MessageSearchResponse{}, err
+		}
+		if !isParticipant {
+			return res.MessageSearchResponse{}, ErrNotParticipant
+		}
+	}
+
+	limit := filter.Limit
+	// one extra row tells whether another page exists
+	filter.Limit++
+
+	hits, err := uc.ChatRepository.SearchMessages(ctx, uc.DB, filter)
+	if err != nil {
+		uc.Log.Http.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to search messages")
+		return res.MessageSearchResponse{}, fmt.Errorf("failed to search messages: %w", err)
+	}
+
+	response := res.MessageSearchResponse{Results: make([]res.MessageSearchResult, 0, min(len(hits), limit))}
+	if len(hits) > limit {
+		last := hits[limit-1]
+		response.Next = repository.MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+		hits = hits[:limit]
+	}
+
+	for _, hit := range hits {
+		response.Results = append(response.Results, res.MessageSearchResult{
+			MessageId:  hit.ID,
+			ChatId:     hit.ChatID,
+			ChatType:   hit.ChatType,
+			GroupName:  hit.GroupName,
+			SenderId:   hit.SenderID,
+			SenderName: hit.SenderName,
+			Highlight:  markHighlight(hit.Highlight),
+			Seq:        hit.Seq,
+			CreatedAt:  hit.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	uc.Log.Http.Info.Info().
+		Str("userId", userID).
+		Int("resultCount", len(response.Results)).
+		Bool("hasMore", response.Next != "").
+		Msg("Successfully searched messages")
+
+	return response, nil
+}
+
+var highlightMarks = strings.NewReplacer(repository.HighlightStart, "<mark>", repository.HighlightStop, "</mark>")
+
+// markHighlight escapes the message text and only then turns the repository
+// sentinels into <mark> tags, so the tags are the only markup.
+func markHighlight(highlight string) string {
+	return highlightMarks.Replace(html.EscapeString(highlight))
+}
+
+func parseMessageSearch(userID string, request req.MessageSearchRequest) (repository.MessageSearchFilter, error) {
+	filter := repository.MessageSearchFilter{
+		UserID:   userID,
+		Query:    strings.TrimSpace(request.Query),
+		ChatID:   request.ChatID,
+		SenderID: request.SenderID,
+		Limit:    request.Limit,
+	}
+
+	if filter.Query == "" {
+		return filter, fmt.Errorf("%w: q is required", ErrInvalidRequest)
+	}
+	if utf8.RuneCountInString(filter.Query) > maxSearchQueryRunes {
+		return filter, fmt.Errorf("%w: q is longer than %d characters", ErrInvalidRequest, maxSearchQueryRunes)
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultSearchLimit
+	} else if filter.Limit > maxSearchLimit {
+		filter.Limit = maxSearchLimit
+	}
+
+	if request.From != "" {
+		from, _, err := parseSearchTime(request.From)
+		if err != nil {
+			return filter, fmt.Errorf("%w: invalid from: %v", ErrInvalidRequest, err)
+		}
+		filter.From = &from
+	}
+	if request.To != "" {
+		to, dateOnly, err := parseSearchTime(request.To)
+		if err != nil {
+			return filter, fmt.Errorf("%w: invalid to: %v", ErrInvalidRequest, err)
+		}
+		// a bare date covers that whole day
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+
+	if request.Cursor != "" {
+		cursor, err := repository.DecodeMessageCursor(request.Cursor)
+		if err != nil {
+			return filter, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		filter.Cursor = cursor
+	}
+
+	return filter, nil
+}
+
+func parseSearchTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
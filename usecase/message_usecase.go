@@ -4,10 +4,27 @@ import (
 	"context"
 	"real-time-chat-app/dto"
 	"real-time-chat-app/dto/req"
+	"real-time-chat-app/dto/res"
+	"real-time-chat-app/dto/ws"
+	"real-time-chat-app/enum"
 )
 
 type MessageUsecase interface {
 	EnsureChat(ctx context.Context, chatID, senderID, receiverID string) (string, error)
 	ProcessIncomingMessage(ctx context.Context, payload req.MessageRequest) (dto.BroadcastMessage, error)
-	MarkMessagesAsRead(ctx context.Context, chatID, userID string) error
+	MarkRead(ctx context.Context, userID, chatID, upToMessageID string) (ws.ReadReceiptPayload, error)
+	GetSeenBy(ctx context.Context, userID, chatID, messageID string) (res.SeenByResponse, error)
+	EditMessage(ctx context.Context, userID, chatID, messageID, content string) (ws.MessageEditedPayload, error)
+	DeleteMessage(ctx context.Context, userID, chatID, messageID string, scope enum.DeleteScope) (ws.MessageDeletedPayload, error)
+	GetMessageEdits(ctx context.Context, userID, chatID, messageID string) ([]res.MessageEditResponse, error)
+	AddReaction(ctx context.Context, userID, chatID, messageID, emoji string) (ws.ReactionUpdatedPayload, error)
+	RemoveReaction(ctx context.Context, userID, chatID, messageID, emoji string) (ws.ReactionUpdatedPayload, error)
+	MarkDelivered(ctx context.Context, userID string, messageIDs []string) ([]StatusChange, error)
+	GetMessageReceipts(ctx context.Context, userID, chatID, messageID string) (res.MessageReceiptsResponse, error)
+}
+
+// StatusChange is a receipt update to push to the sender of the message.
+type StatusChange struct {
+	SenderID string
+	Payload  ws.MessageStatusPayload
 }
@@ -2,28 +2,57 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"real-time-chat-app/config/common"
 	"real-time-chat-app/config/logger"
 	"real-time-chat-app/dto"
 	"real-time-chat-app/dto/req"
+	"real-time-chat-app/dto/res"
+	"real-time-chat-app/dto/ws"
 	"real-time-chat-app/entity"
 	"real-time-chat-app/enum"
+	"real-time-chat-app/repository"
+	"real-time-chat-app/storage"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxAttachmentsPerMessage = 10
+	maxAckedMessages         = 200
 )
 
 type messageUsecase struct {
-	db          *gorm.DB
-	chatUsecase ChatUsecase
-	log         *logger.AppLogger
+	db                   *gorm.DB
+	chatUsecase          ChatUsecase
+	chatRepository       *repository.ChatRepository
+	eventRepository      *repository.ChatEventRepository
+	messageRepository    *repository.MessageRepository
+	reactionRepository   *repository.ReactionRepository
+	attachmentRepository *repository.AttachmentRepository
+	store                storage.BlobStore
+	config               common.MessageConfig
+	log                  *logger.AppLogger
 }
 
-func NewMessageUsecase(db *gorm.DB, chatUC ChatUsecase, logger *logger.AppLogger) MessageUsecase {
+func NewMessageUsecase(db *gorm.DB, chatUC ChatUsecase, chatRepository *repository.ChatRepository, eventRepository *repository.ChatEventRepository, messageRepository *repository.MessageRepository, reactionRepository *repository.ReactionRepository, attachmentRepository *repository.AttachmentRepository, store storage.BlobStore, config common.MessageConfig, logger *logger.AppLogger) MessageUsecase {
 	logger.Http.Info.Info().Msg("Message usecase initialized")
 	return &messageUsecase{
-		db:          db,
-		chatUsecase: chatUC,
-		log:         logger,
+		db:                   db,
+		chatUsecase:          chatUC,
+		chatRepository:       chatRepository,
+		eventRepository:      eventRepository,
+		messageRepository:    messageRepository,
+		reactionRepository:   reactionRepository,
+		attachmentRepository: attachmentRepository,
+		store:                store,
+		config:               config,
+		log:                  logger,
 	}
 }
 
@@ -97,6 +126,23 @@ func (uc *messageUsecase) ProcessIncomingMessage(ctx context.Context, payload re
 		Int("contentLength", len(payload.Content)).
 		Msg("ProcessIncomingMessage started")
 
+	isParticipant, err := uc.chatUsecase.IsParticipant(ctx, payload.ChatID, payload.SenderID)
+	if err != nil {
+		uc.log.Http.Error.Error().
+			Err(err).
+			Str("senderId", payload.SenderID).
+			Str("chatId", payload.ChatID).
+			Msg("Failed to verify participant")
+		return dto.BroadcastMessage{}, fmt.Errorf("failed to verify participant: %w", err)
+	}
+	if !isParticipant {
+		uc.log.Http.Warning.Warn().
+			Str("senderId", payload.SenderID).
+			Str("chatId", payload.ChatID).
+			Msg("Sender is not a participant of the chat")
+		return dto.BroadcastMessage{}, ErrNotParticipant
+	}
+
 	uc.log.Http.Trace.Trace().
 		Str("senderId", payload.SenderID).
 		Msg("Fetching sender information")
@@ -116,15 +162,106 @@ func (uc *messageUsecase) ProcessIncomingMessage(ctx context.Context, payload re
 		Str("chatId", payload.ChatID).
 		Msg("Creating message entity")
 
+	attachmentIDs := uniqueIDs(payload.AttachmentIDs, "")
+	if len(attachmentIDs) > maxAttachmentsPerMessage {
+		uc.log.Http.Warning.Warn().
+			Str("chatId", payload.ChatID).
+			Int("attachmentCount", len(attachmentIDs)).
+			Msg("Too many attachments on message")
+		return dto.BroadcastMessage{}, fmt.Errorf("%w: at most %d attachments per message", ErrInvalidRequest, maxAttachmentsPerMessage)
+	}
+
+	// Resolve the quoted message, it has to live in the same chat
+	var replyTo *entity.Messages
+	if payload.ReplyToID != "" {
+		quoted, err := uc.messageRepository.FindInChat(ctx, uc.db.Preload("Sender"), payload.ChatID, payload.ReplyToID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				uc.log.Http.Warning.Warn().
+					Str("chatId", payload.ChatID).
+					Str("replyToId", payload.ReplyToID).
+					Msg("Quoted message not found in chat")
+				return dto.BroadcastMessage{}, fmt.Errorf("%w: quoted message not found", ErrInvalidRequest)
+			}
+			uc.log.Http.Error.Error().
+				Err(err).
+				Str("replyToId", payload.ReplyToID).
+				Msg("Failed to find quoted message")
+			return dto.BroadcastMessage{}, err
+		}
+		replyTo = quoted
+	}
+
 	// Create message
 	message := entity.Messages{
 		Content:  payload.Content,
 		ChatId:   payload.ChatID,
 		SenderId: payload.SenderID,
 		Status:   enum.MessageStatusSent,
+		Type:     enum.MessageTypeText,
+	}
+	if replyTo != nil {
+		message.ReplyToID = &replyTo.ID
 	}
 
-	if err := uc.db.Create(&message).Error; err != nil {
+	var broadcastMsg dto.BroadcastMessage
+	err = uc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		seq, err := uc.eventRepository.NextSeq(ctx, tx, payload.ChatID)
+		if err != nil {
+			return err
+		}
+
+		var attachments []entity.Attachment
+		if len(attachmentIDs) > 0 {
+			// the lock keeps the media worker from finishing between read and link
+			attachments, err = uc.attachmentRepository.FindUnsent(ctx, tx.Clauses(clause.Locking{Strength: "UPDATE"}), payload.ChatID, payload.SenderID, attachmentIDs)
+			if err != nil {
+				return err
+			}
+			if len(attachments) != len(attachmentIDs) {
+				return fmt.Errorf("%w: attachment not found or already sent", ErrInvalidRequest)
+			}
+			message.Type = messageTypeOf(attachments)
+		}
+
+		message.Seq = seq
+		if err := tx.Omit("ReplyTo", "Attachments").Create(&message).Error; err != nil {
+			return err
+		}
+
+		if replyTo != nil {
+			if err := uc.messageRepository.IncrementReplyCount(ctx, tx, replyTo.ID); err != nil {
+				return err
+			}
+		}
+
+		if len(attachments) > 0 {
+			if _, err := uc.attachmentRepository.LinkToMessage(ctx, tx, payload.ChatID, payload.SenderID, message.ID, attachmentIDs); err != nil {
+				return err
+			}
+		}
+
+		broadcastMsg = dto.BroadcastMessage{
+			MessageID:    message.ID,
+			ChatID:       payload.ChatID,
+			SenderID:     payload.SenderID,
+			SenderName:   sender.Name,
+			SenderAvatar: sender.Avatar,
+			Status:       string(message.Status),
+			Type:         string(message.Type),
+			Content:      payload.Content,
+			CreatedAt:    message.CreatedAt.Format("2006-01-02 15:04:05"),
+			Seq:          message.Seq,
+			ReplyTo:      quoteOf(replyTo),
+		}
+		if len(attachments) > 0 {
+			broadcastMsg.Attachments = attachmentsOf(attachments)
+		}
+
+		_, err = uc.eventRepository.AppendWithSeq(ctx, tx, payload.ChatID, seq, ws.TypeNewMessage, broadcastMsg)
+		return err
+	})
+	if err != nil {
 		uc.log.Http.Error.Error().
 			Err(err).
 			Str("senderId", payload.SenderID).
@@ -137,6 +274,7 @@ func (uc *messageUsecase) ProcessIncomingMessage(ctx context.Context, payload re
 		Str("messageId", message.ID).
 		Str("chatId", payload.ChatID).
 		Str("senderId", payload.SenderID).
+		Int64("seq", message.Seq).
 		Msg("Message created successfully")
 
 	uc.log.Http.Trace.Trace().
@@ -166,6 +304,7 @@ func (uc *messageUsecase) ProcessIncomingMessage(ctx context.Context, payload re
 				IsRead:    false,
 				MessageID: message.ID,
 				UserID:    p.UserID,
+				Status:    enum.MessageStatusSent,
 			}
 			if err := uc.db.Create(&status).Error; err != nil {
 				uc.log.Http.Warning.Warn().
@@ -184,18 +323,6 @@ func (uc *messageUsecase) ProcessIncomingMessage(ctx context.Context, payload re
 		Int("statusCreated", statusCount).
 		Msg("Message status created for participants")
 
-	// Prepare broadcast message
-	broadcastMsg := dto.BroadcastMessage{
-		MessageID:    message.ID,
-		ChatID:       payload.ChatID,
-		SenderID:     payload.SenderID,
-		SenderName:   sender.Name,
-		SenderAvatar: sender.Avatar,
-		Status:       string(message.Status),
-		Content:      payload.Content,
-		CreatedAt:    message.CreatedAt.Format("2006-01-02 15:04:05"),
-	}
-
 	uc.log.Http.Info.Info().
 		Str("messageId", message.ID).
 		Str("chatId", payload.ChatID).
@@ -205,104 +332,689 @@ func (uc *messageUsecase) ProcessIncomingMessage(ctx context.Context, payload re
 	return broadcastMsg, nil
 }
 
-func (uc *messageUsecase) MarkMessagesAsRead(ctx context.Context, chatID, userID string) error {
+func (uc *messageUsecase) MarkRead(ctx context.Context, userID, chatID, upToMessageID string) (ws.ReadReceiptPayload, error) {
+	uc.log.Http.Info.Info().
+		Str("userId", userID).
+		Str("chatId", chatID).
+		Str("upToMessageId", upToMessageID).
+		Msg("MarkRead started")
+
+	if err := uc.requireParticipant(ctx, chatID, userID); err != nil {
+		return ws.ReadReceiptPayload{}, err
+	}
+
+	readAt := time.Now()
+	payload := ws.ReadReceiptPayload{
+		ChatID: chatID,
+		UserID: userID,
+	}
+
+	err := uc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// without a message id everything currently in the chat is read
+		var target *entity.Messages
+		var err error
+		if upToMessageID != "" {
+			target, err = uc.messageRepository.FindInChat(ctx, tx, chatID, upToMessageID)
+		} else {
+			target, err = uc.messageRepository.FindLatestInChat(ctx, tx, chatID)
+		}
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if upToMessageID == "" {
+					return nil
+				}
+				return ErrMessageNotFound
+			}
+			return err
+		}
+
+		participant, err := uc.chatRepository.FindParticipant(ctx, tx.Clauses(clause.Locking{Strength: "UPDATE"}), chatID, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotParticipant
+			}
+			return err
+		}
+
+		// the watermark only moves forward, older receipts arriving late change nothing
+		payload.MessageID = target.ID
+		payload.ReadSeq = target.Seq
+		if target.Seq <= participant.LastReadSeq {
+			payload.ReadSeq = participant.LastReadSeq
+			if participant.LastReadMessageID != nil {
+				payload.MessageID = *participant.LastReadMessageID
+			}
+			payload.ReadAt = formatOptionalTime(participant.LastReadAt)
+			return nil
+		}
+
+		if err := uc.chatRepository.UpdateReadPosition(ctx, tx, chatID, userID, target, readAt); err != nil {
+			return err
+		}
+		payload.ReadAt = readAt.Format("2006-01-02 15:04:05")
+
+		messageIDs, err := uc.messageRepository.FindIDsInSeqRange(ctx, tx, chatID, userID, participant.LastReadSeq, target.Seq)
+		if err != nil {
+			return err
+		}
+		if len(messageIDs) > 0 {
+			if err := uc.messageRepository.RefreshStatus(ctx, tx, messageIDs); err != nil {
+				return err
+			}
+		}
+
+		seq, err := uc.eventRepository.NextSeq(ctx, tx, chatID)
+		if err != nil {
+			return err
+		}
+		payload.Seq = seq
+
+		_, err = uc.eventRepository.AppendWithSeq(ctx, tx, chatID, seq, ws.TypeReadReceipt, payload)
+		return err
+	})
+	if err != nil {
+		uc.log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Str("chatId", chatID).
+			Msg("Failed to mark messages as read")
+		return ws.ReadReceiptPayload{}, err
+	}
+
 	uc.log.Http.Info.Info().
 		Str("chatId", chatID).
 		Str("userId", userID).
-		Msg("MarkMessagesAsRead started")
+		Int64("readSeq", payload.ReadSeq).
+		Bool("advanced", payload.Seq != 0).
+		Msg("Successfully marked messages as read")
 
-	uc.log.Http.Trace.Trace().
+	return payload, nil
+}
+
+func (uc *messageUsecase) GetSeenBy(ctx context.Context, userID, chatID, messageID string) (res.SeenByResponse, error) {
+	uc.log.Http.Info.Info().
+		Str("userId", userID).
 		Str("chatId", chatID).
-		Msg("Fetching messages in chat")
+		Str("messageId", messageID).
+		Msg("GetSeenBy started")
 
-	// Get all messages in chat
-	var messages []entity.Messages
-	if err := uc.db.Where("chat_id = ?", chatID).Find(&messages).Error; err != nil {
+	if err := uc.requireParticipant(ctx, chatID, userID); err != nil {
+		return res.SeenByResponse{}, err
+	}
+
+	message, err := uc.messageRepository.FindInChat(ctx, uc.db, chatID, messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res.SeenByResponse{}, ErrMessageNotFound
+		}
+		return res.SeenByResponse{}, err
+	}
+
+	participants, err := uc.chatRepository.FindParticipants(ctx, uc.db, chatID)
+	if err != nil {
 		uc.log.Http.Error.Error().
 			Err(err).
 			Str("chatId", chatID).
-			Msg("Failed to fetch messages")
+			Msg("Failed to get participants")
+		return res.SeenByResponse{}, err
+	}
+
+	response := res.SeenByResponse{
+		MessageID: messageID,
+		SeenBy:    []res.SeenByEntry{},
+		Pending:   []string{},
+	}
+	for _, p := range participants {
+		if p.UserID == message.SenderId {
+			continue
+		}
+		if p.LastReadSeq < message.Seq {
+			response.Pending = append(response.Pending, p.UserID)
+			continue
+		}
+		response.SeenBy = append(response.SeenBy, res.SeenByEntry{
+			UserID: p.UserID,
+			Name:   p.User.Name,
+			Avatar: p.User.Avatar,
+			ReadAt: formatOptionalTime(p.LastReadAt),
+		})
+	}
+
+	uc.log.Http.Info.Info().
+		Str("messageId", messageID).
+		Int("seenCount", len(response.SeenBy)).
+		Int("pendingCount", len(response.Pending)).
+		Msg("Successfully retrieved seen by list")
+
+	return response, nil
+}
+
+func (uc *messageUsecase) EditMessage(ctx context.Context, userID, chatID, messageID, content string) (ws.MessageEditedPayload, error) {
+	uc.log.Http.Info.Info().
+		Str("userId", userID).
+		Str("chatId", chatID).
+		Str("messageId", messageID).
+		Int("contentLength", len(content)).
+		Msg("EditMessage started")
+
+	if strings.TrimSpace(content) == "" {
+		uc.log.Http.Warning.Warn().
+			Str("messageId", messageID).
+			Msg("Edit rejected: content is empty")
+		return ws.MessageEditedPayload{}, fmt.Errorf("%w: message content cannot be empty", ErrInvalidRequest)
+	}
+
+	if err := uc.requireParticipant(ctx, chatID, userID); err != nil {
+		return ws.MessageEditedPayload{}, err
+	}
+
+	editedAt := time.Now()
+	payload := ws.MessageEditedPayload{
+		ChatID:    chatID,
+		MessageID: messageID,
+		Content:   content,
+		EditedBy:  userID,
+		EditedAt:  editedAt.Format("2006-01-02 15:04:05"),
+	}
+
+	err := uc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// lock the row so concurrent edits are recorded one after another
+		message, err := uc.messageRepository.FindInChat(ctx, tx.Clauses(clause.Locking{Strength: "UPDATE"}), chatID, messageID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMessageNotFound
+			}
+			return err
+		}
+
+		if message.SenderId != userID {
+			return fmt.Errorf("%w: only the sender can edit a message", ErrForbidden)
+		}
+		if message.IsDeleted {
+			return fmt.Errorf("%w: message was deleted", ErrInvalidRequest)
+		}
+		if editedAt.Sub(message.CreatedAt) > uc.config.EditWindow {
+			return ErrEditWindowExpired
+		}
+		if message.Content == content {
+			return fmt.Errorf("%w: content is unchanged", ErrInvalidRequest)
+		}
+
+		if err := uc.messageRepository.SaveEdit(ctx, tx, &entity.MessageEdit{
+			MessageID:       messageID,
+			PreviousContent: message.Content,
+			EditedBy:        userID,
+		}); err != nil {
+			return err
+		}
+
+		if err := uc.messageRepository.UpdateContent(ctx, tx, messageID, content, editedAt); err != nil {
+			return err
+		}
+
+		seq, err := uc.eventRepository.NextSeq(ctx, tx, chatID)
+		if err != nil {
+			return err
+		}
+		payload.Seq = seq
+
+		_, err = uc.eventRepository.AppendWithSeq(ctx, tx, chatID, seq, ws.TypeMessageEdited, payload)
 		return err
+	})
+	if err != nil {
+		uc.log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Str("messageId", messageID).
+			Msg("Failed to edit message")
+		return ws.MessageEditedPayload{}, err
 	}
 
-	if len(messages) == 0 {
-		uc.log.Http.Trace.Trace().
-			Str("chatId", chatID).
-			Msg("No messages found in chat")
-		return nil
+	uc.log.Http.Info.Info().
+		Str("chatId", chatID).
+		Str("messageId", messageID).
+		Int64("seq", payload.Seq).
+		Msg("Message edited successfully")
+
+	return payload, nil
+}
+
+func (uc *messageUsecase) DeleteMessage(ctx context.Context, userID, chatID, messageID string, scope enum.DeleteScope) (ws.MessageDeletedPayload, error) {
+	uc.log.Http.Info.Info().
+		Str("userId", userID).
+		Str("chatId", chatID).
+		Str("messageId", messageID).
+		Str("scope", string(scope)).
+		Msg("DeleteMessage started")
+
+	if scope != enum.DeleteScopeMe && scope != enum.DeleteScopeEveryone {
+		uc.log.Http.Warning.Warn().
+			Str("scope", string(scope)).
+			Msg("Delete rejected: unknown scope")
+		return ws.MessageDeletedPayload{}, fmt.Errorf("%w: scope must be me or everyone", ErrInvalidRequest)
 	}
 
-	messageIDs := make([]string, 0, len(messages))
-	for _, m := range messages {
-		messageIDs = append(messageIDs, m.ID)
+	if err := uc.requireParticipant(ctx, chatID, userID); err != nil {
+		return ws.MessageDeletedPayload{}, err
 	}
 
-	uc.log.Http.Trace.Trace().
+	deletedAt := time.Now()
+	payload := ws.MessageDeletedPayload{
+		ChatID:    chatID,
+		MessageID: messageID,
+		Scope:     string(scope),
+		DeletedBy: userID,
+		DeletedAt: deletedAt.Format("2006-01-02 15:04:05"),
+	}
+
+	var attachments []entity.Attachment
+	err := uc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		message, err := uc.messageRepository.FindInChat(ctx, tx.Clauses(clause.Locking{Strength: "UPDATE"}), chatID, messageID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMessageNotFound
+			}
+			return err
+		}
+
+		if scope == enum.DeleteScopeMe {
+			return uc.messageRepository.Hide(ctx, tx, messageID, userID)
+		}
+
+		if message.SenderId != userID {
+			return fmt.Errorf("%w: only the sender can delete a message for everyone", ErrForbidden)
+		}
+		if message.IsDeleted {
+			return fmt.Errorf("%w: message was already deleted", ErrInvalidRequest)
+		}
+
+		if err := uc.messageRepository.Tombstone(ctx, tx, chatID, messageID, deletedAt); err != nil {
+			return err
+		}
+		if err := uc.reactionRepository.DeleteByMessage(ctx, tx, messageID); err != nil {
+			return err
+		}
+		if attachments, err = uc.attachmentRepository.FindByMessageID(ctx, tx, messageID); err != nil {
+			return err
+		}
+		if err := uc.attachmentRepository.DeleteByMessageID(ctx, tx, messageID); err != nil {
+			return err
+		}
+
+		seq, err := uc.eventRepository.NextSeq(ctx, tx, chatID)
+		if err != nil {
+			return err
+		}
+		payload.Seq = seq
+
+		_, err = uc.eventRepository.AppendWithSeq(ctx, tx, chatID, seq, ws.TypeMessageDeleted, payload)
+		return err
+	})
+	if err != nil {
+		uc.log.Http.Warning.Warn().
+			Err(err).
+			Str("userId", userID).
+			Str("messageId", messageID).
+			Str("scope", string(scope)).
+			Msg("Failed to delete message")
+		return ws.MessageDeletedPayload{}, err
+	}
+
+	// blobs go only once the rows are gone for good
+	for _, attachment := range attachments {
+		deleteAttachmentBlobs(ctx, uc.store, uc.log, attachment)
+	}
+
+	uc.log.Http.Info.Info().
 		Str("chatId", chatID).
+		Str("messageId", messageID).
+		Str("scope", string(scope)).
+		Msg("Message deleted successfully")
+
+	return payload, nil
+}
+
+func (uc *messageUsecase) GetMessageEdits(ctx context.Context, userID, chatID, messageID string) ([]res.MessageEditResponse, error) {
+	uc.log.Http.Info.Info().
 		Str("userId", userID).
-		Int("messageCount", len(messageIDs)).
-		Msg("Marking message status as read")
-
-	// Update message status for user
-	result := uc.db.Model(&entity.MessageStatus{}).
-		Where("message_id IN ? AND user_id = ? AND is_read = false", messageIDs, userID).
-		Updates(map[string]interface{}{
-			"is_read": true,
-			"read_at": time.Now(),
-		})
+		Str("chatId", chatID).
+		Str("messageId", messageID).
+		Msg("GetMessageEdits started")
+
+	if err := uc.requireParticipant(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
 
-	if result.Error != nil {
+	if _, err := uc.messageRepository.FindInChat(ctx, uc.db, chatID, messageID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
 		uc.log.Http.Error.Error().
-			Err(result.Error).
-			Str("chatId", chatID).
+			Err(err).
+			Str("messageId", messageID).
+			Msg("Failed to find message")
+		return nil, err
+	}
+
+	edits, err := uc.messageRepository.FindEdits(ctx, uc.db, messageID)
+	if err != nil {
+		uc.log.Http.Error.Error().
+			Err(err).
+			Str("messageId", messageID).
+			Msg("Failed to get message edits")
+		return nil, err
+	}
+
+	responses := make([]res.MessageEditResponse, 0, len(edits))
+	for _, e := range edits {
+		responses = append(responses, res.MessageEditResponse{
+			PreviousContent: e.PreviousContent,
+			EditedBy:        e.EditedBy,
+			EditedAt:        e.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	uc.log.Http.Info.Info().
+		Str("messageId", messageID).
+		Int("editCount", len(responses)).
+		Msg("Successfully retrieved message edits")
+
+	return responses, nil
+}
+
+func (uc *messageUsecase) AddReaction(ctx context.Context, userID, chatID, messageID, emoji string) (ws.ReactionUpdatedPayload, error) {
+	return uc.updateReaction(ctx, userID, chatID, messageID, emoji, true)
+}
+
+func (uc *messageUsecase) RemoveReaction(ctx context.Context, userID, chatID, messageID, emoji string) (ws.ReactionUpdatedPayload, error) {
+	return uc.updateReaction(ctx, userID, chatID, messageID, emoji, false)
+}
+
+func (uc *messageUsecase) updateReaction(ctx context.Context, userID, chatID, messageID, emoji string, add bool) (ws.ReactionUpdatedPayload, error) {
+	uc.log.Http.Info.Info().
+		Str("userId", userID).
+		Str("chatId", chatID).
+		Str("messageId", messageID).
+		Str("emoji", emoji).
+		Bool("add", add).
+		Msg("UpdateReaction started")
+
+	emoji = strings.TrimSpace(emoji)
+	if !isEmoji(emoji) {
+		uc.log.Http.Warning.Warn().
+			Str("messageId", messageID).
+			Str("emoji", emoji).
+			Msg("Reaction rejected: not an emoji")
+		return ws.ReactionUpdatedPayload{}, fmt.Errorf("%w: reaction must be a single emoji", ErrInvalidRequest)
+	}
+
+	if err := uc.requireParticipant(ctx, chatID, userID); err != nil {
+		return ws.ReactionUpdatedPayload{}, err
+	}
+
+	payload := ws.ReactionUpdatedPayload{
+		ChatID:    chatID,
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+		Added:     add,
+	}
+
+	err := uc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// lock the message so the emoji count matches the order of events
+		message, err := uc.messageRepository.FindInChat(ctx, tx.Clauses(clause.Locking{Strength: "UPDATE"}), chatID, messageID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMessageNotFound
+			}
+			return err
+		}
+		if message.IsDeleted {
+			return fmt.Errorf("%w: message was deleted", ErrInvalidRequest)
+		}
+
+		var changed bool
+		if add {
+			changed, err = uc.reactionRepository.Add(ctx, tx, messageID, userID, emoji)
+		} else {
+			changed, err = uc.reactionRepository.Remove(ctx, tx, messageID, userID, emoji)
+		}
+		if err != nil {
+			return err
+		}
+
+		payload.Count, err = uc.reactionRepository.CountEmoji(ctx, tx, messageID, emoji)
+		if err != nil || !changed {
+			return err
+		}
+
+		seq, err := uc.eventRepository.NextSeq(ctx, tx, chatID)
+		if err != nil {
+			return err
+		}
+		payload.Seq = seq
+
+		_, err = uc.eventRepository.AppendWithSeq(ctx, tx, chatID, seq, ws.TypeReactionUpdated, payload)
+		return err
+	})
+	if err != nil {
+		uc.log.Http.Warning.Warn().
+			Err(err).
 			Str("userId", userID).
-			Msg("Failed to update message status")
-		return result.Error
+			Str("messageId", messageID).
+			Str("emoji", emoji).
+			Msg("Failed to update reaction")
+		return ws.ReactionUpdatedPayload{}, err
 	}
 
 	uc.log.Http.Info.Info().
 		Str("chatId", chatID).
+		Str("messageId", messageID).
+		Str("emoji", emoji).
+		Bool("changed", payload.Seq != 0).
+		Int64("count", payload.Count).
+		Msg("Reaction updated successfully")
+
+	return payload, nil
+}
+
+func (uc *messageUsecase) MarkDelivered(ctx context.Context, userID string, messageIDs []string) ([]StatusChange, error) {
+	messageIDs = uniqueIDs(messageIDs, "")
+
+	uc.log.Http.Info.Info().
+		Str("userId", userID).
+		Int("messageCount", len(messageIDs)).
+		Msg("MarkDelivered started")
+
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	if len(messageIDs) > maxAckedMessages {
+		return nil, fmt.Errorf("%w: at most %d messages per ack", ErrInvalidRequest, maxAckedMessages)
+	}
+
+	deliveredAt := time.Now()
+	var summaries []repository.StatusSummary
+	err := uc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// only the caller's own receipts move, so no participant check is needed
+		updated, err := uc.messageRepository.MarkDelivered(ctx, tx, userID, messageIDs, deliveredAt)
+		if err != nil || len(updated) == 0 {
+			return err
+		}
+
+		if err := uc.messageRepository.RefreshStatus(ctx, tx, updated); err != nil {
+			return err
+		}
+
+		summaries, err = uc.messageRepository.SummarizeStatus(ctx, tx, updated)
+		return err
+	})
+	if err != nil {
+		uc.log.Http.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to mark messages as delivered")
+		return nil, err
+	}
+
+	changes := make([]StatusChange, 0, len(summaries))
+	for _, s := range summaries {
+		changes = append(changes, StatusChange{
+			SenderID: s.SenderID,
+			Payload: ws.MessageStatusPayload{
+				ChatID:    s.ChatID,
+				MessageID: s.MessageID,
+				Status:    string(s.Status),
+				UserID:    userID,
+				Delivered: s.Delivered,
+				Read:      s.Read,
+				Total:     s.Total,
+				UpdatedAt: deliveredAt.Format("2006-01-02 15:04:05"),
+			},
+		})
+	}
+
+	uc.log.Http.Info.Info().
 		Str("userId", userID).
-		Int64("updatedCount", result.RowsAffected).
-		Msg("Message status updated")
+		Int("deliveredCount", len(changes)).
+		Msg("Messages marked as delivered")
 
-	uc.log.Http.Trace.Trace().
+	return changes, nil
+}
+
+func (uc *messageUsecase) GetMessageReceipts(ctx context.Context, userID, chatID, messageID string) (res.MessageReceiptsResponse, error) {
+	uc.log.Http.Info.Info().
+		Str("userId", userID).
 		Str("chatId", chatID).
-		Msg("Checking if all recipients have read messages")
-
-	// Update message status to "read" if all recipients have read
-	updatedMessages := 0
-	for _, msgID := range messageIDs {
-		var unreadCount int64
-		uc.db.Model(&entity.MessageStatus{}).
-			Where("message_id = ? AND is_read = false", msgID).
-			Count(&unreadCount)
-
-		if unreadCount == 0 {
-			if err := uc.db.Model(&entity.Messages{}).
-				Where("id = ?", msgID).
-				Update("status", enum.MessageStatusRead).Error; err != nil {
-				uc.log.Http.Warning.Warn().
-					Err(err).
-					Str("messageId", msgID).
-					Msg("Failed to update message status to read")
-			} else {
-				updatedMessages++
-			}
+		Str("messageId", messageID).
+		Msg("GetMessageReceipts started")
+
+	if err := uc.requireParticipant(ctx, chatID, userID); err != nil {
+		return res.MessageReceiptsResponse{}, err
+	}
+
+	message, err := uc.messageRepository.FindInChat(ctx, uc.db, chatID, messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res.MessageReceiptsResponse{}, ErrMessageNotFound
 		}
+		return res.MessageReceiptsResponse{}, err
+	}
+	if message.SenderId != userID {
+		return res.MessageReceiptsResponse{}, fmt.Errorf("%w: only the sender can see receipts", ErrForbidden)
 	}
 
-	if updatedMessages > 0 {
-		uc.log.Http.Trace.Trace().
+	receipts, err := uc.messageRepository.FindReceipts(ctx, uc.db, messageID)
+	if err != nil {
+		uc.log.Http.Error.Error().
+			Err(err).
+			Str("messageId", messageID).
+			Msg("Failed to get message receipts")
+		return res.MessageReceiptsResponse{}, err
+	}
+
+	participants, err := uc.chatRepository.FindParticipants(ctx, uc.db, chatID)
+	if err != nil {
+		uc.log.Http.Error.Error().
+			Err(err).
 			Str("chatId", chatID).
-			Int("updatedMessages", updatedMessages).
-			Msg("Updated message status to 'read'")
+			Msg("Failed to get participants")
+		return res.MessageReceiptsResponse{}, err
+	}
+	readPositions := make(map[string]entity.ChatParticipant, len(participants))
+	for _, p := range participants {
+		readPositions[p.UserID] = p
+	}
+
+	response := res.MessageReceiptsResponse{
+		MessageID: messageID,
+		Status:    string(message.Status),
+		Total:     int64(len(receipts)),
+		Receipts:  make([]res.MessageReceiptResponse, 0, len(receipts)),
+	}
+	for _, r := range receipts {
+		receipt := res.MessageReceiptResponse{
+			UserID:      r.UserID,
+			Status:      string(r.Status),
+			DeliveredAt: formatOptionalTime(r.DeliveredAt),
+		}
+		if p, ok := readPositions[r.UserID]; ok && p.LastReadSeq >= message.Seq {
+			receipt.Status = string(enum.MessageStatusRead)
+			receipt.ReadAt = formatOptionalTime(p.LastReadAt)
+			if receipt.DeliveredAt == "" {
+				receipt.DeliveredAt = receipt.ReadAt
+			}
+		} else if r.Status == enum.MessageStatusRead {
+			receipt.ReadAt = r.ReadAt.Format("2006-01-02 15:04:05")
+		}
+		if receipt.Status == string(enum.MessageStatusRead) || receipt.Status == string(enum.MessageStatusDelivered) {
+			response.Delivered++
+		}
+		if receipt.Status == string(enum.MessageStatusRead) {
+			response.Read++
+		}
+		response.Receipts = append(response.Receipts, receipt)
 	}
 
 	uc.log.Http.Info.Info().
-		Str("chatId", chatID).
-		Str("userId", userID).
-		Msg("Successfully marked messages as read")
+		Str("messageId", messageID).
+		Int64("recipientCount", response.Total).
+		Msg("Successfully retrieved message receipts")
+
+	return response, nil
+}
+
+// messageTypeOf names a message after what it carries, a message made only of
+// images is an image message.
+func messageTypeOf(attachments []entity.Attachment) enum.MessageType {
+	if len(attachments) == 0 {
+		return enum.MessageTypeText
+	}
+	for _, a := range attachments {
+		if !strings.HasPrefix(a.MimeType, "image/") {
+			return enum.MessageTypeFile
+		}
+	}
+	return enum.MessageTypeImage
+}
+
+const maxEmojiRunes = 16
+
+// isEmoji accepts a short run of symbols with at least one non-ASCII rune, which
+// covers skin tones, ZWJ sequences and flags without a full emoji table.
+func isEmoji(s string) bool {
+	if s == "" || utf8.RuneCountInString(s) > maxEmojiRunes {
+		return false
+	}
+
+	hasSymbol := false
+	for _, r := range s {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || unicode.IsLetter(r) {
+			return false
+		}
+		if r >= utf8.RuneSelf {
+			hasSymbol = true
+		}
+	}
+	return hasSymbol
+}
 
+func (uc *messageUsecase) requireParticipant(ctx context.Context, chatID, userID string) error {
+	isParticipant, err := uc.chatUsecase.IsParticipant(ctx, chatID, userID)
+	if err != nil {
+		uc.log.Http.Error.Error().
+			Err(err).
+			Str("chatId", chatID).
+			Str("userId", userID).
+			Msg("Failed to verify participant")
+		return err
+	}
+	if !isParticipant {
+		uc.log.Http.Warning.Warn().
+			Str("chatId", chatID).
+			Str("userId", userID).
+			Msg("User not authorized for this chat")
+		return ErrNotParticipant
+	}
 	return nil
 }
@@ -0,0 +1,456 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"real-time-chat-app/broker"
+	"real-time-chat-app/config/common"
+	"real-time-chat-app/config/logger"
+	"real-time-chat-app/dto/ws"
+	"real-time-chat-app/entity"
+	"real-time-chat-app/enum"
+	"real-time-chat-app/repository"
+	"strings"
+	"sync"
+	"time"
+)
+
+const presenceTopic = "presence"
+
+// Kinds of presence updates exchanged between nodes.
+const (
+	presenceKindSession  = "session"
+	presenceKindSnapshot = "snapshot"
+)
+
+// PresenceNotifier pushes a presence change to the sessions watching the user,
+// the websocket handler implements it.
+type PresenceNotifier interface {
+	PresenceChanged(payload ws.PresencePayload)
+}
+
+type presenceSession struct {
+	UserID    string              `json:"userId"`
+	SessionID string              `json:"sessionId"`
+	Status    enum.PresenceStatus `json:"status"`
+}
+
+// presenceUpdate is what a node publishes, either one session changing or,
+// on every heartbeat, the full list of its sessions.
+type presenceUpdate struct {
+	NodeID   string            `json:"nodeId"`
+	Kind     string            `json:"kind"`
+	Session  *presenceSession  `json:"session,omitempty"`
+	Sessions []presenceSession `json:"sessions,omitempty"`
+}
+
+// PresenceTracker keeps the online, away or offline state of every user across
+// all nodes. Each node owns its own sessions and shares them through the broker,
+// sessions of a node that stops sending heartbeats expire after the TTL.
+type PresenceTracker struct {
+	db             *gorm.DB
+	userRepository *repository.UserRepository
+	chatRepository *repository.ChatRepository
+	broker         broker.Broker
+	notifier       PresenceNotifier
+	config         common.PresenceConfig
+	log            *logger.AppLogger
+	nodeID         string
+
+	mutex     sync.Mutex
+	sessions  map[string]map[string]presenceSession // userId -> "nodeId/sessionId" -> session
+	nodes     map[string]time.Time                  // remote nodeId -> last heard from
+	local     map[string]presenceSession            // sessionId -> session owned by this node
+	published map[string]enum.PresenceStatus        // last status announced per user, offline users are absent
+	lastSeen  map[string]time.Time                  // when the user's last session went away
+	pending   map[string]*time.Timer                // debounced announcements
+}
+
+func NewPresenceTracker(db *gorm.DB, userRepository *repository.UserRepository, chatRepository *repository.ChatRepository, broker broker.Broker, config common.PresenceConfig, logger *logger.AppLogger) *PresenceTracker {
+	return &PresenceTracker{
+		db:             db,
+		userRepository: userRepository,
+		chatRepository: chatRepository,
+		broker:         broker,
+		config:         config,
+		log:            logger,
+		nodeID:         uuid.New().String(),
+		sessions:       make(map[string]map[string]presenceSession),
+		nodes:          make(map[string]time.Time),
+		local:          make(map[string]presenceSession),
+		published:      make(map[string]enum.PresenceStatus),
+		lastSeen:       make(map[string]time.Time),
+		pending:        make(map[string]*time.Timer),
+	}
+}
+
+// Start subscribes to the other nodes and begins sending heartbeats. Sessions
+// on other nodes become known with their next heartbeat.
+func (t *PresenceTracker) Start(notifier PresenceNotifier) {
+	t.notifier = notifier
+
+	if err := t.broker.Subscribe(presenceTopic, t.handleUpdate); err != nil {
+		t.log.WS.Error.Error().
+			Err(err).
+			Str("topic", presenceTopic).
+			Msg("Failed to subscribe to broker topic")
+	}
+	go t.heartbeat()
+
+	t.log.WS.Info.Info().
+		Str("nodeId", t.nodeID).
+		Dur("debounce", t.config.Debounce).
+		Dur("heartbeatInterval", t.config.HeartbeatInterval).
+		Msg("Presence tracker started")
+}
+
+func (t *PresenceTracker) Connect(userID, sessionID string) {
+	t.setLocal(presenceSession{UserID: userID, SessionID: sessionID, Status: enum.PresenceOnline})
+}
+
+func (t *PresenceTracker) Disconnect(userID, sessionID string) {
+	t.setLocal(presenceSession{UserID: userID, SessionID: sessionID, Status: enum.PresenceOffline})
+}
+
+// SetStatus applies the state a client reports for one of its sessions.
+func (t *PresenceTracker) SetStatus(userID, sessionID string, status enum.PresenceStatus) error {
+	if status != enum.PresenceOnline && status != enum.PresenceAway {
+		return fmt.Errorf("%w: status must be online or away", ErrInvalidRequest)
+	}
+
+	t.mutex.Lock()
+	_, connected := t.local[sessionID]
+	t.mutex.Unlock()
+	if !connected {
+		return fmt.Errorf("%w: session is not connected", ErrInvalidRequest)
+	}
+
+	t.setLocal(presenceSession{UserID: userID, SessionID: sessionID, Status: status})
+	return nil
+}
+
+// GetPresence returns the presence of userID as seen by viewerID. Like
+// Subscribe it is limited to contacts, anyone else looks like an unknown user.
+func (t *PresenceTracker) GetPresence(ctx context.Context, viewerID, userID string) (ws.PresencePayload, error) {
+	if viewerID != userID {
+		contact, err := t.chatRepository.SharesChat(ctx, t.db, viewerID, userID)
+		if err != nil {
+			return ws.PresencePayload{}, err
+		}
+		if !contact {
+			return ws.PresencePayload{}, ErrUserNotFound
+		}
+	}
+
+	var user entity.User
+	if err := t.userRepository.FindById(ctx, t.db, &user, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ws.PresencePayload{}, ErrUserNotFound
+		}
+		return ws.PresencePayload{}, err
+	}
+
+	return t.presenceOf(user.ID, user.LastSeenAt), nil
+}
+
+// Subscribe resolves which of the requested users the caller may watch, only
+// users sharing a chat with them qualify, and returns their current presence.
+func (t *PresenceTracker) Subscribe(ctx context.Context, userID string, userIDs []string) ([]ws.PresencePayload, error) {
+	contactIDs, err := t.chatRepository.FindContactIDs(ctx, t.db, userID)
+	if err != nil {
+		t.log.WS.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to get contacts")
+		return nil, err
+	}
+
+	allowed := contactIDs
+	if len(userIDs) > 0 {
+		contacts := make(map[string]struct{}, len(contactIDs))
+		for _, id := range contactIDs {
+			contacts[id] = struct{}{}
+		}
+		allowed = make([]string, 0, len(userIDs))
+		for _, id := range uniqueIDs(userIDs, userID) {
+			if _, ok := contacts[id]; ok {
+				allowed = append(allowed, id)
+			}
+		}
+	}
+	if len(allowed) == 0 {
+		return []ws.PresencePayload{}, nil
+	}
+
+	var users []entity.User
+	if err := t.db.WithContext(ctx).Select("id", "last_seen_at").Where("id IN ?", allowed).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	presences := make([]ws.PresencePayload, 0, len(users))
+	for _, user := range users {
+		presences = append(presences, t.presenceOf(user.ID, user.LastSeenAt))
+	}
+	return presences, nil
+}
+
+// presenceOf reports what watchers were last told, so a snapshot never runs
+// ahead of the debounced events.
+func (t *PresenceTracker) presenceOf(userID string, storedLastSeen *time.Time) ws.PresencePayload {
+	t.mutex.Lock()
+	status, ok := t.published[userID]
+	lastSeen, seen := t.lastSeen[userID]
+	t.mutex.Unlock()
+
+	if ok {
+		return ws.PresencePayload{UserID: userID, Status: string(status)}
+	}
+
+	payload := ws.PresencePayload{UserID: userID, Status: string(enum.PresenceOffline)}
+	if storedLastSeen != nil && (!seen || storedLastSeen.After(lastSeen)) {
+		lastSeen, seen = *storedLastSeen, true
+	}
+	if seen {
+		payload.LastSeenAt = lastSeen.Format("2006-01-02 15:04:05")
+	}
+	return payload
+}
+
+func (t *PresenceTracker) setLocal(session presenceSession) {
+	t.mutex.Lock()
+	if session.Status == enum.PresenceOffline {
+		delete(t.local, session.SessionID)
+	} else {
+		t.local[session.SessionID] = session
+	}
+	t.apply(t.nodeID, session)
+	t.mutex.Unlock()
+
+	t.publish(presenceUpdate{NodeID: t.nodeID, Kind: presenceKindSession, Session: &session})
+}
+
+func (t *PresenceTracker) publish(update presenceUpdate) {
+	data, err := json.Marshal(update)
+	if err != nil {
+		t.log.WS.Error.Error().
+			Err(err).
+			Msg("Failed to encode presence update")
+		return
+	}
+
+	if err := t.broker.Publish(context.Background(), presenceTopic, data); err != nil {
+		t.log.WS.Error.Error().
+			Err(err).
+			Str("kind", update.Kind).
+			Msg("Failed to publish presence update")
+	}
+}
+
+func (t *PresenceTracker) handleUpdate(data []byte) {
+	var update presenceUpdate
+	if err := json.Unmarshal(data, &update); err != nil {
+		t.log.WS.Error.Error().
+			Err(err).
+			Msg("Failed to decode presence update")
+		return
+	}
+
+	// local sessions are applied before they are published
+	if update.NodeID == t.nodeID {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.nodes[update.NodeID] = time.Now()
+	switch update.Kind {
+	case presenceKindSession:
+		if update.Session != nil {
+			t.apply(update.NodeID, *update.Session)
+		}
+	case presenceKindSnapshot:
+		t.replaceNode(update.NodeID, update.Sessions)
+	}
+}
+
+// apply records one session of a node, the caller holds the mutex.
+func (t *PresenceTracker) apply(nodeID string, session presenceSession) {
+	key := nodeID + "/" + session.SessionID
+	sessions := t.sessions[session.UserID]
+
+	if session.Status == enum.PresenceOffline {
+		if _, ok := sessions[key]; !ok {
+			return
+		}
+		delete(sessions, key)
+		if len(sessions) == 0 {
+			seenAt := time.Now()
+			delete(t.sessions, session.UserID)
+			t.lastSeen[session.UserID] = seenAt
+			// the node that closed the last session stores it, outside the mutex
+			if nodeID == t.nodeID {
+				go t.persistLastSeen(session.UserID, seenAt)
+			}
+		}
+	} else {
+		if existing, ok := sessions[key]; ok && existing.Status == session.Status {
+			return
+		}
+		if sessions == nil {
+			sessions = make(map[string]presenceSession)
+			t.sessions[session.UserID] = sessions
+		}
+		sessions[key] = session
+	}
+
+	t.schedule(session.UserID)
+}
+
+func (t *PresenceTracker) persistLastSeen(userID string, seenAt time.Time) {
+	if err := t.userRepository.UpdateLastSeen(context.Background(), t.db, userID, seenAt); err != nil {
+		t.log.WS.Error.Error().
+			Err(err).
+			Str("userId", userID).
+			Msg("Failed to persist last seen")
+	}
+}
+
+// replaceNode swaps every session known for a node with the given list, the
+// caller holds the mutex.
+func (t *PresenceTracker) replaceNode(nodeID string, current []presenceSession) {
+	kept := make(map[string]struct{}, len(current))
+	for _, session := range current {
+		kept[session.SessionID] = struct{}{}
+		t.apply(nodeID, session)
+	}
+
+	prefix := nodeID + "/"
+	var gone []presenceSession
+	for _, sessions := range t.sessions {
+		for key, session := range sessions {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			if _, ok := kept[session.SessionID]; !ok {
+				gone = append(gone, session)
+			}
+		}
+	}
+	for _, session := range gone {
+		session.Status = enum.PresenceOffline
+		t.apply(nodeID, session)
+	}
+}
+
+// statusOf folds all sessions of a user, one active session is enough to be
+// online. The caller holds the mutex.
+func (t *PresenceTracker) statusOf(userID string) enum.PresenceStatus {
+	sessions, ok := t.sessions[userID]
+	if !ok {
+		return enum.PresenceOffline
+	}
+	for _, session := range sessions {
+		if session.Status == enum.PresenceOnline {
+			return enum.PresenceOnline
+		}
+	}
+	return enum.PresenceAway
+}
+
+// schedule announces coming online right away and holds back going away or
+// offline for the debounce window, so reconnects and tab switches do not flap.
+// The caller holds the mutex.
+func (t *PresenceTracker) schedule(userID string) {
+	status := t.statusOf(userID)
+	if status == enum.PresenceOnline {
+		if timer, ok := t.pending[userID]; ok {
+			timer.Stop()
+			delete(t.pending, userID)
+		}
+		go t.announce(userID)
+		return
+	}
+
+	if _, ok := t.pending[userID]; ok {
+		return
+	}
+	t.pending[userID] = time.AfterFunc(t.config.Debounce, func() {
+		t.mutex.Lock()
+		delete(t.pending, userID)
+		t.mutex.Unlock()
+		t.announce(userID)
+	})
+}
+
+func (t *PresenceTracker) announce(userID string) {
+	t.mutex.Lock()
+	status := t.statusOf(userID)
+	previous, ok := t.published[userID]
+	if !ok {
+		previous = enum.PresenceOffline
+	}
+	if status == previous {
+		t.mutex.Unlock()
+		return
+	}
+
+	payload := ws.PresencePayload{UserID: userID, Status: string(status)}
+	if status == enum.PresenceOffline {
+		delete(t.published, userID)
+		if lastSeen, seen := t.lastSeen[userID]; seen {
+			payload.LastSeenAt = lastSeen.Format("2006-01-02 15:04:05")
+		}
+	} else {
+		t.published[userID] = status
+		delete(t.lastSeen, userID)
+	}
+	t.mutex.Unlock()
+
+	t.log.WS.Trace.Trace().
+		Str("userId", userID).
+		Str("from", string(previous)).
+		Str("to", string(status)).
+		Msg("Presence changed")
+
+	if t.notifier != nil {
+		t.notifier.PresenceChanged(payload)
+	}
+}
+
+// heartbeat shares this node's sessions and forgets nodes that went silent.
+func (t *PresenceTracker) heartbeat() {
+	ticker := time.NewTicker(t.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		t.mutex.Lock()
+		sessions := make([]presenceSession, 0, len(t.local))
+		for _, session := range t.local {
+			sessions = append(sessions, session)
+		}
+
+		deadline := time.Now().Add(-t.config.TTL)
+		var expired []string
+		for nodeID, heardAt := range t.nodes {
+			if heardAt.Before(deadline) {
+				expired = append(expired, nodeID)
+				delete(t.nodes, nodeID)
+				t.replaceNode(nodeID, nil)
+			}
+		}
+		t.mutex.Unlock()
+
+		for _, nodeID := range expired {
+			t.log.WS.Warning.Warn().
+				Str("nodeId", nodeID).
+				Msg("Presence node expired, dropping its sessions")
+		}
+
+		t.publish(presenceUpdate{NodeID: t.nodeID, Kind: presenceKindSnapshot, Sessions: sessions})
+	}
+}